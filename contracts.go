@@ -0,0 +1,12 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+
+// Package ion embeds the Ion Solidity contract sources so tools can compile
+// them without the contracts directory being present on disk.
+package ion
+
+import "embed"
+
+// Contracts holds the Solidity sources of the contracts directory
+//
+//go:embed contracts/*.sol contracts/libraries/*.sol
+var Contracts embed.FS
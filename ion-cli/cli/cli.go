@@ -9,8 +9,10 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/compiler"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 
@@ -99,6 +101,37 @@ func Launch(
 		},
 	})
 
+	//---------------------------------------------------------------------------------------------
+	// 	Ion Specific Commands
+	//---------------------------------------------------------------------------------------------
+	shell.AddCmd(&ishell.Cmd{
+		Name: "deployIon",
+		Help: "use: 	deployIon \n\t\t\t\tdescription: Deploys the PatriciaTrie library and the Ion contract linked to it on chain TO",
+		Func: func(c *ishell.Context) {
+			c.Println("Connecting to: " + setup.AddrTo)
+
+			// Get the chainId
+			bytesChainId := common.HexToHash(setup.ChainId)
+
+			opts := &contract.TxOptions{
+				OnSubmit: func(tx *types.Transaction) {
+					c.Printf("Transaction Hash:\n0x%x\n", tx.Hash())
+				},
+			}
+			contractChan, errChan := contract.CompileAndDeployIon(ctx, ethclientTo, keyTo.PrivateKey, bytesChainId, opts, nil)
+
+			for _, name := range []string{"PatriciaTrie", "Ion"} {
+				instance, ok := <-contractChan
+				if !ok {
+					c.Printf("Error: %s\n", <-errChan)
+					return
+				}
+				c.Printf("%s deployed at:\n%s\n", name, instance.Address.Hex())
+			}
+			c.Println("===============================================================")
+		},
+	})
+
 	//---------------------------------------------------------------------------------------------
 	// 	Validation Specific Commands
 	//---------------------------------------------------------------------------------------------
@@ -129,7 +162,7 @@ func Launch(
 			bytesGenesis := common.HexToHash(genesis)
 			// bytesGenesis := common.HexToHash("0x100dc525cdcb7933e09f10d4019c38d342253a0aa32889fbbdbc5f2406c7546c")
 
-			tx := contract.RegisterChain(
+			tx, err := contract.RegisterChain(
 				ctx,
 				ethclientTo,
 				keyTo.PrivateKey,
@@ -139,6 +172,10 @@ func Launch(
 				validators,
 				bytesGenesis,
 			)
+			if err != nil {
+				c.Printf("Error: %s", err)
+				return
+			}
 
 			c.Printf("Transaction Hash:\n0x%x\n", tx.Hash())
 			c.Println("===============================================================")
@@ -167,7 +204,7 @@ func Launch(
 			c.Printf("RLP encode block:\nNumber:\t\t%s", blockNum)
 
 			signedBlock, unsignedBlock := calculateRlpEncoding(ethclientFrom, blockNum)
-			tx := contract.SubmitBlock(
+			tx, err := contract.SubmitBlockRLP(
 				ctx,
 				ethclientTo,
 				keyTo.PrivateKey,
@@ -177,6 +214,10 @@ func Launch(
 				unsignedBlock,
 				signedBlock,
 			)
+			if err != nil {
+				c.Printf("Error: %s", err)
+				return
+			}
 
 			c.Printf("Transaction Hash:\n0x%x\n", tx.Hash())
 			c.Println("===============================================================")
@@ -207,7 +248,7 @@ func Launch(
 				return
 			}
 
-			result := contract.ValidBlock(
+			result, err := contract.ValidBlock(
 				ctx,
 				ethclientTo,
 				Validation,
@@ -216,6 +257,10 @@ func Launch(
 				bytesChainId,
 				bytesBlockHash,
 			)
+			if err != nil {
+				c.Printf("Error: %s", err)
+				return
+			}
 
 			c.Println("Checking for valid block:")
 			c.Printf("ChainId:\t%x\nBlockHash:\t%x\nValid:\t\t%v\n", bytesChainId, bytesBlockHash, result)
@@ -231,7 +276,7 @@ func Launch(
 			// Get the chainId
 			bytesChainId := common.HexToHash(setup.ChainId)
 
-			result := contract.LatestValidBlock(
+			result, err := contract.LatestValidBlock(
 				ctx,
 				ethclientTo,
 				Validation,
@@ -239,6 +284,10 @@ func Launch(
 				common.HexToAddress(setup.Validation),
 				bytesChainId,
 			)
+			if err != nil {
+				c.Printf("Error: %s", err)
+				return
+			}
 
 			c.Println("Checking for latest valid block:")
 			c.Printf("\nBlockHash:\t0x%x\nChainId:\t%s\n", result, setup.ChainId)
@@ -255,13 +304,17 @@ func Launch(
 		Func: func(c *ishell.Context) {
 			c.Println("Connecting to: " + setup.AddrFrom)
 
-			tx := contract.Fire(
+			tx, err := contract.Fire(
 				ctx,
 				ethclientFrom,
 				keyFrom.PrivateKey,
 				Trigger,
 				common.HexToAddress(setup.Trigger),
 			)
+			if err != nil {
+				c.Printf("Error: %s", err)
+				return
+			}
 
 			c.Printf("Transaction Hash:\n0x%x\n", tx.Hash())
 			c.Println("===============================================================")
@@ -295,36 +348,101 @@ func Launch(
 			// bytesBlockHash := common.HexToHash("0x74d37aa3c96bc98903451d0baf051b87550191aa0d92032f7406a4984610b046")
 
 			// Generate the proof
-			txPath, txValue, txNodes, receiptValue, receiptNodes := utils.GenerateProof(
+			proof, err := utils.GenerateProof(
 				ctx,
 				clientFrom,
 				bytesTxHash,
+				nil,
 			)
+			if err != nil {
+				c.Printf("Error: %s", err)
+				return
+			}
 
 			// Execute
-			tx := contract.VerifyExecute(
+			tx, err := contract.VerifyExecuteWithParams(
 				ctx,
 				ethclientTo,
 				keyFrom.PrivateKey,
 				Function,
 				common.HexToAddress(setup.Function),
-				bytesChainId,
-				bytesBlockHash,
-				common.HexToAddress(setup.Trigger), // TRIG_DEPLOYED_RINKEBY_ADDR,
-				txPath,                                 // TEST_PATH,
-				txValue,                                // TEST_TX_VALUE,
-				txNodes,                                // TEST_TX_NODES,
-				receiptValue,                           // TEST_RECEIPT_VALUE,
-				receiptNodes,                           // TEST_RECEIPT_NODES,
-				common.HexToAddress(setup.AccountFrom), // TRIG_CALLED_BY,
-				nil,
+				contract.VerifyExecuteParams{
+					ChainID:      bytesChainId,
+					BlockHash:    bytesBlockHash,
+					TxTo:         common.HexToAddress(setup.Trigger),
+					TxPath:       proof.TxPath,
+					TxRLP:        proof.TxValue,
+					TxProof:      proof.TxNodes,
+					Receipt:      proof.ReceiptValue,
+					ReceiptProof: proof.ReceiptNodes,
+					CalledBy:     common.HexToAddress(setup.AccountFrom),
+				},
 			)
+			if err != nil {
+				c.Printf("Error: %s", err)
+				return
+			}
 
 			c.Printf("Transaction Hash:\n0x%x\n", tx.Hash())
 			c.Println("===============================================================")
 		},
 	})
 
+	//---------------------------------------------------------------------------------------------
+	// 	Relay Commands
+	//---------------------------------------------------------------------------------------------
+	shell.AddCmd(&ishell.Cmd{
+		Name: "relay",
+		Help: "use: \trelay\n \t\t\t\t\tEnter From Block: [INTEGER]\n\t\t\t\tdescription: Relays the trigger events of chain FROM to the function contract of chain TO, submitting their blocks to the validation contract",
+		Func: func(c *ishell.Context) {
+			c.Println("Connecting to: " + setup.AddrTo + " and " + setup.AddrFrom)
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			_, triggerABIStr, err := contract.ContractBytecodeAndABI(Trigger)
+			if err != nil {
+				c.Printf("Error: %s\n", err)
+				return
+			}
+			triggerABI, err := abi.JSON(strings.NewReader(triggerABIStr))
+			if err != nil {
+				c.Printf("Error: %s\n", err)
+				return
+			}
+
+			// Get the block to resume from, none to relay new events only
+			c.Print("Enter From Block: ")
+			var fromBlock *big.Int
+			if blockNum := strings.TrimSpace(c.ReadLine()); blockNum != "" {
+				var ok bool
+				if fromBlock, ok = new(big.Int).SetString(blockNum, 10); !ok {
+					c.Printf("Error: invalid block number %s\n", blockNum)
+					return
+				}
+			}
+
+			err = contract.RelayTriggers(ctx, clientFrom, ethclientTo, contract.RelayConfig{
+				Key:            keyTo.PrivateKey,
+				ChainID:        common.HexToHash(setup.ChainId),
+				Trigger:        common.HexToAddress(setup.Trigger),
+				TriggerABI:     triggerABI,
+				Validation:     Validation,
+				ValidationAddr: common.HexToAddress(setup.Validation),
+				Engine:         utils.Clique,
+				Function:       Function,
+				FunctionAddr:   common.HexToAddress(setup.Function),
+				FromBlock:      fromBlock,
+				OnRelay: func(event contract.TriggerEvent, tx *types.Transaction) {
+					c.Printf("Relayed trigger 0x%x of block %d:\n0x%x\n", event.TxHash, event.BlockNumber, tx.Hash())
+				},
+			})
+			if err != nil {
+				c.Printf("Error: %s\n", err)
+			}
+			c.Println("===============================================================")
+		},
+	})
+
 	// run shell
 	shell.Run()
 }
@@ -0,0 +1,162 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+
+package config
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+)
+
+// ChainConfig holds the endpoint, account and Ion contracts of a chain
+type ChainConfig struct {
+	// RPC URL of a node of the chain
+	RPC string `json:"rpc" toml:"rpc"`
+	// ChainID the chain is registered with in Ion
+	ChainID common.Hash `json:"chain-id" toml:"chain-id"`
+	// Key is the hex private key of the account, in place of a keystore
+	Key string `json:"key" toml:"key"`
+	// Keystore file of the account, relative to the config file
+	Keystore string `json:"keystore" toml:"keystore"`
+	// Password of the keystore
+	Password string `json:"password" toml:"password"`
+	// Ion contract address
+	Ion common.Address `json:"ion-addr" toml:"ion-addr"`
+	// Validation contract address
+	Validation common.Address `json:"validation-addr" toml:"validation-addr"`
+	// Trigger contract address
+	Trigger common.Address `json:"trigger-addr" toml:"trigger-addr"`
+	// Function contract address
+	Function common.Address `json:"function-addr" toml:"function-addr"`
+}
+
+// Names of the chains of a relay, see DialClients
+const (
+	SourceChain      = "source"
+	DestinationChain = "destination"
+)
+
+// Config holds the chains of a multi-chain setup by name, e.g. the source and
+// destination chains of a relay
+type Config struct {
+	Chains map[string]ChainConfig `json:"chains" toml:"chains"`
+}
+
+// LoadConfig reads the config file at path, in TOML when it has the .toml
+// extension and JSON otherwise. Keystore paths are made relative to it.
+func LoadConfig(path string) (*Config, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %q: %v", path, err)
+	}
+
+	var config Config
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = toml.Unmarshal(raw, &config)
+	} else {
+		err = json.Unmarshal(raw, &config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %q: %v", path, err)
+	}
+
+	for name, chain := range config.Chains {
+		if chain.RPC == "" {
+			return nil, fmt.Errorf("chain %s has no rpc endpoint", name)
+		}
+		if chain.Keystore != "" && !filepath.IsAbs(chain.Keystore) {
+			chain.Keystore = filepath.Join(filepath.Dir(path), chain.Keystore)
+			config.Chains[name] = chain
+		}
+	}
+	return &config, nil
+}
+
+// Chain returns the config of the named chain
+func (c *Config) Chain(chain string) (ChainConfig, error) {
+	chainConfig, ok := c.Chains[chain]
+	if !ok {
+		return ChainConfig{}, fmt.Errorf("chain %s not configured", chain)
+	}
+	return chainConfig, nil
+}
+
+// Backend dials the RPC endpoint of the named chain
+func (c *Config) Backend(chain string) (bind.ContractBackend, error) {
+	client, err := c.dial(context.Background(), chain)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// DialClients dials the RPC endpoints of the source and destination chains,
+// configured as SourceChain and DestinationChain. The caller closes both
+// clients once done.
+func (c *Config) DialClients(ctx context.Context) (source, dest *ethclient.Client, err error) {
+	source, err = c.dial(ctx, SourceChain)
+	if err != nil {
+		return nil, nil, err
+	}
+	dest, err = c.dial(ctx, DestinationChain)
+	if err != nil {
+		source.Close()
+		return nil, nil, err
+	}
+	return source, dest, nil
+}
+
+// dial dials the RPC endpoint of the named chain
+func (c *Config) dial(ctx context.Context, chain string) (*ethclient.Client, error) {
+	chainConfig, err := c.Chain(chain)
+	if err != nil {
+		return nil, err
+	}
+	client, err := utils.Dial(ctx, chainConfig.RPC)
+	if err != nil {
+		return nil, fmt.Errorf("chain %s: %v", chain, err)
+	}
+	return client, nil
+}
+
+// PrivateKey returns the key of the account of the named chain, decrypting
+// its keystore when no raw key is given
+func (c *Config) PrivateKey(chain string) (*ecdsa.PrivateKey, error) {
+	chainConfig, err := c.Chain(chain)
+	if err != nil {
+		return nil, err
+	}
+	if chainConfig.Key != "" {
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(chainConfig.Key, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid key of chain %s: %v", chain, err)
+		}
+		return key, nil
+	}
+	if chainConfig.Keystore == "" {
+		return nil, fmt.Errorf("chain %s has no key or keystore", chain)
+	}
+
+	keyJSON, err := ioutil.ReadFile(chainConfig.Keystore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore of chain %s: %v", chain, err)
+	}
+	key, err := keystore.DecryptKey(keyJSON, chainConfig.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore of chain %s: %v", chain, err)
+	}
+	return key.PrivateKey, nil
+}
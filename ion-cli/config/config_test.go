@@ -3,6 +3,7 @@
 package config_test
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"strings"
@@ -53,3 +54,45 @@ func findPath() string {
 	pathSlice := strings.Split(path, "/")
 	return strings.Trim(path, pathSlice[len(pathSlice)-1])
 }
+
+func Test_LoadConfig(t *testing.T) {
+	expectedFrom := common.HexToAddress("2be5ab0e43b6dc2908d5321cf318f35b80d0c10d")
+
+	for _, file := range []string{"chains.json", "chains.toml"} {
+		cfg, err := config.LoadConfig(findPath() + file)
+		assert.Nil(t, err, file)
+
+		source, err := cfg.Chain("source")
+		assert.Nil(t, err, file)
+		assert.Equal(t, "http://127.0.0.1:8501", source.RPC, file)
+		assert.Equal(t, common.HexToHash("0xab830ae0774cb20180c8b463202659184033a9f30a21550b89a2b406c3ac8075"), source.ChainID, file)
+		assert.Equal(t, common.HexToAddress("0x61621bcf02914668f8404c1f860e92fc1893f74c"), source.Trigger, file)
+
+		destination, err := cfg.Chain("destination")
+		assert.Nil(t, err, file)
+		assert.Equal(t, common.HexToAddress("0xb9fd43a71c076f02d1dbbf473c389f0eacec559f"), destination.Ion, file)
+
+		// the keystore is found relative to the config file
+		sourceKey, err := cfg.PrivateKey("source")
+		assert.Nil(t, err, file)
+		assert.Equal(t, expectedFrom, crypto.PubkeyToAddress(sourceKey.PublicKey), file)
+
+		destinationKey, err := cfg.PrivateKey("destination")
+		assert.Nil(t, err, file)
+		assert.Equal(t, expectedFrom, crypto.PubkeyToAddress(destinationKey.PublicKey), file)
+
+		_, err = cfg.Backend("unknown")
+		assert.NotNil(t, err, file)
+
+		sourceClient, destClient, err := cfg.DialClients(context.Background())
+		assert.Nil(t, err, file)
+		assert.NotNil(t, sourceClient, file)
+		assert.NotNil(t, destClient, file)
+		sourceClient.Close()
+		destClient.Close()
+
+		delete(cfg.Chains, config.DestinationChain)
+		_, _, err = cfg.DialClients(context.Background())
+		assert.NotNil(t, err, file)
+	}
+}
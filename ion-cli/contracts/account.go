@@ -0,0 +1,67 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// AccountSigner is an account signing transactions, without exposing its key
+type AccountSigner interface {
+	// Address of the account
+	Address() common.Address
+	// SignTx signs tx with EIP-155 replay protection for chainID, or without a
+	// chain id when nil
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// keySigner signs with a raw private key
+type keySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+// PrivateKeySigner returns the AccountSigner of a raw private key
+func PrivateKeySigner(key *ecdsa.PrivateKey) AccountSigner {
+	return keySigner{key: key}
+}
+
+func (s keySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.key.PublicKey)
+}
+
+func (s keySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, chainSigner(chainID), s.key)
+}
+
+// fnSigner signs with an external signing function
+type fnSigner struct {
+	from common.Address
+	fn   bind.SignerFn
+}
+
+// SignerFnSigner returns the AccountSigner of the account from signing with
+// fn, e.g. in an HSM or remote KMS, so its key never enters the process
+func SignerFnSigner(from common.Address, fn bind.SignerFn) AccountSigner {
+	return fnSigner{from: from, fn: fn}
+}
+
+func (s fnSigner) Address() common.Address {
+	return s.from
+}
+
+func (s fnSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return s.fn(chainSigner(chainID), s.from, tx)
+}
+
+// chainSigner returns the signer of transactions for chainID
+func chainSigner(chainID *big.Int) types.Signer {
+	if chainID != nil {
+		return types.NewEIP155Signer(chainID)
+	}
+	return types.HomesteadSigner{}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// rpcClientBackend is implemented by backends keeping the RPC client they
+// wrap, for the calls ethclient lacks, e.g. finding the block of a transaction
+type rpcClientBackend interface {
+	RPCClient() *rpc.Client
+}
+
+// dialedBackend is the ethclient of DialBackend along with its RPC client
+type dialedBackend struct {
+	*ethclient.Client
+	rpc *rpc.Client
+}
+
+// RPCClient returns the RPC client of the backend
+func (b *dialedBackend) RPCClient() *rpc.Client {
+	return b.rpc
+}
+
+// DialBackend connects to the node at rawurl over HTTP, websockets or IPC
+// depending on its scheme, see utils.Dial, and returns a backend for the
+// deployments, transactions and watchers of this package. Watchers relying on
+// SubscribeFilterLogs need the persistent connection of a ws:// or IPC URL.
+// Reverts are replayed against the block the transaction was mined in, and
+// confirmations counted from it, which the receipts of a plain ethclient
+// can't tell.
+func DialBackend(ctx context.Context, rawurl string) (bind.ContractBackend, error) {
+	client, err := utils.DialRPC(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return &dialedBackend{Client: ethclient.NewClient(client), rpc: client}, nil
+}
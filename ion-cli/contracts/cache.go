@@ -0,0 +1,206 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/compiler"
+)
+
+// cacheDir overrides the directory compiled contracts are cached in
+var cacheDir string
+
+// cacheDisabled makes every compilation run solc
+var cacheDisabled bool
+
+// importRegexp matches the path of Solidity import directives, e.g.
+// import "./RLP.sol"; and import {RLP} from "./RLP.sol";
+var importRegexp = regexp.MustCompile(`import\s+(?:[^"';]*\s+from\s+)?["']([^"']+)["']`)
+
+// SetCacheDir sets the directory compiled contracts are cached in.
+// Passing an empty path restores the default location in the temp dir.
+func SetCacheDir(path string) {
+	cacheDir = path
+}
+
+// CacheDir returns the directory compiled contracts are cached in
+func CacheDir() string {
+	if cacheDir != "" {
+		return cacheDir
+	}
+	return filepath.Join(os.TempDir(), "ion-solc-cache")
+}
+
+// SetCacheEnabled turns the cache of compiled contracts on or off. It is on
+// by default.
+func SetCacheEnabled(enabled bool) {
+	cacheDisabled = !enabled
+}
+
+// ClearCache removes all the compiled contracts from the cache directory.
+// Only the *.json cache entries are removed, so a cache directory set to one
+// holding other files keeps them.
+func ClearCache() error {
+	entries, err := filepath.Glob(filepath.Join(CacheDir(), "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to clear compile cache: %v", err)
+	}
+	for _, entry := range entries {
+		if err := os.Remove(entry); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear compile cache: %v", err)
+		}
+	}
+	return nil
+}
+
+// CompileWithCache compiles the Solidity files with the solc binary at solcPath,
+// or the one on PATH when empty. The output is cached by solc version and the
+// content of the files and everything they import, so unchanged sources are
+// not compiled again.
+func CompileWithCache(solcPath string, files ...string) (map[string]*compiler.Contract, error) {
+	solc, err := solidityVersion(solcPath)
+	if err != nil {
+		return nil, err
+	}
+	contracts, _, err := compileWithCache(solc, nil, files...)
+	return contracts, err
+}
+
+// Compile compiles the Solidity files with the solc binary and settings of
+// opts, caching the output like CompileWithCache along with the settings. The detected solc version is kept
+// in the Info.CompilerVersion of every contract returned.
+func Compile(opts *CompileOptions, files ...string) (map[string]*compiler.Contract, error) {
+	contracts, _, err := CompileWithWarnings(opts, files...)
+	return contracts, err
+}
+
+// CompileWithWarnings compiles the Solidity files like Compile, also returning
+// the warnings solc reported, e.g. shadowed declarations. The warnings are
+// cached along with the contracts.
+func CompileWithWarnings(opts *CompileOptions, files ...string) (map[string]*compiler.Contract, []string, error) {
+	if opts != nil && opts.StandardJSON {
+		standardContracts, warnings, err := compileStandardJSON(opts, files...)
+		if err != nil {
+			return nil, nil, err
+		}
+		contracts := make(map[string]*compiler.Contract, len(standardContracts))
+		for name, c := range standardContracts {
+			contracts[name] = c.Contract
+		}
+		return contracts, warnings, nil
+	}
+	solc, err := opts.solc()
+	if err != nil {
+		return nil, nil, err
+	}
+	return compileWithCache(solc, opts, files...)
+}
+
+func solidityVersion(solcPath string) (*compiler.Solidity, error) {
+	if solcPath == "" {
+		solcPath = "solc"
+	}
+	if _, err := exec.LookPath(solcPath); err != nil {
+		return nil, fmt.Errorf("solc binary %q not found: %v", solcPath, err)
+	}
+	solc, err := compiler.SolidityVersion(solcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get solc version: %v", err)
+	}
+	return solc, nil
+}
+
+// cachedCompilation is the cached output of a compilation
+type cachedCompilation struct {
+	Contracts map[string]*compiler.Contract `json:"contracts"`
+	Warnings  []string                      `json:"warnings,omitempty"`
+}
+
+func compileWithCache(solc *compiler.Solidity, opts *CompileOptions, files ...string) (map[string]*compiler.Contract, []string, error) {
+	args := opts.solcArgs(solc)
+	if cacheDisabled {
+		return runSolc(solc, args, files...)
+	}
+
+	key, err := compileCacheKey(solc.FullVersion+" "+strings.Join(args, " "), files)
+	if err != nil {
+		return nil, nil, err
+	}
+	cachePath := filepath.Join(CacheDir(), key+".json")
+
+	if data, err := ioutil.ReadFile(cachePath); err == nil {
+		// entries cached without warnings have no contracts field and are compiled again
+		var cached cachedCompilation
+		if err := json.Unmarshal(data, &cached); err == nil && len(cached.Contracts) > 0 {
+			return cached.Contracts, cached.Warnings, nil
+		}
+	}
+
+	contracts, warnings, err := runSolc(solc, args, files...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// a cache that can't be written only costs a recompilation next time
+	if data, err := json.Marshal(cachedCompilation{Contracts: contracts, Warnings: warnings}); err == nil {
+		if err := os.MkdirAll(CacheDir(), 0755); err == nil {
+			ioutil.WriteFile(cachePath, data, 0644)
+		}
+	}
+	return contracts, warnings, nil
+}
+
+// compileCacheKey hashes the solc version with the path and content of every
+// file, following imports so a change in any dependency invalidates the key
+func compileCacheKey(solcVersion string, files []string) (string, error) {
+	h := sha256.New()
+	io.WriteString(h, solcVersion)
+
+	visited := make(map[string]bool)
+	var hashFile func(path string) error
+	hashFile = func(path string) error {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %q: %v", path, err)
+		}
+		if visited[absPath] {
+			return nil
+		}
+		visited[absPath] = true
+
+		source, err := ioutil.ReadFile(absPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %v", absPath, err)
+		}
+		io.WriteString(h, "\x00"+absPath+"\x00")
+		h.Write(source)
+
+		for _, match := range importRegexp.FindAllSubmatch(source, -1) {
+			importPath := string(match[1])
+			if !filepath.IsAbs(importPath) {
+				importPath = filepath.Join(filepath.Dir(absPath), importPath)
+			}
+			if err := hashFile(importPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, file := range files {
+		if err := hashFile(file); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/compiler"
+)
+
+func Test_CompileCacheKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ion-cache-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	main := filepath.Join(dir, "Main.sol")
+	lib := filepath.Join(dir, "Lib.sol")
+	ioutil.WriteFile(main, []byte(`import "./Lib.sol"; contract Main {}`), 0644)
+	ioutil.WriteFile(lib, []byte(`library Lib {}`), 0644)
+
+	key, err := compileCacheKey("0.4.24", []string{main})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, _ := compileCacheKey("0.4.25", []string{main}); other == key {
+		t.Fatal("ERROR expected the solc version to change the key")
+	}
+
+	// a change in an import invalidates the key
+	ioutil.WriteFile(lib, []byte(`library Lib { }`), 0644)
+	if changed, _ := compileCacheKey("0.4.24", []string{main}); changed == key {
+		t.Fatal("ERROR expected a change in an import to change the key")
+	}
+}
+
+func Test_ClearCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ion-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	SetCacheDir(filepath.Join(dir, "cache"))
+	defer SetCacheDir("")
+
+	os.MkdirAll(CacheDir(), 0755)
+	ioutil.WriteFile(filepath.Join(CacheDir(), "entry.json"), []byte("{}"), 0644)
+	ioutil.WriteFile(filepath.Join(CacheDir(), "notes.txt"), []byte("keep"), 0644)
+	if err := ClearCache(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(CacheDir(), "entry.json")); !os.IsNotExist(err) {
+		t.Fatal("ERROR expected the cache entry to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(CacheDir(), "notes.txt")); err != nil {
+		t.Fatal("ERROR expected the other files of the cache directory to be kept")
+	}
+
+	// clearing a cache never written is a no-op
+	SetCacheDir(filepath.Join(dir, "missing"))
+	if err := ClearCache(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_SolcWarnings(t *testing.T) {
+	stderr := `Ion.sol:12:5: Warning: This declaration shadows an existing declaration.
+    bytes32 chainId;
+    ^-------------^
+Ion.sol:20:9: Warning: Unused local variable.
+        uint i;
+        ^----^
+`
+	warnings := solcWarnings(stderr)
+	if len(warnings) != 2 {
+		t.Fatalf("ERROR expected 2 warnings, got %v", warnings)
+	}
+	if !strings.HasPrefix(warnings[0], "Ion.sol:12:5: Warning: This declaration shadows") || !strings.Contains(warnings[0], "bytes32 chainId;") {
+		t.Fatalf("ERROR unexpected first warning %q", warnings[0])
+	}
+	if len(solcWarnings("")) != 0 {
+		t.Fatal("ERROR expected no warnings")
+	}
+}
+
+func Test_SolcArgs(t *testing.T) {
+	solc := &compiler.Solidity{Major: 0, Minor: 4, Patch: 24}
+
+	args := strings.Join((*CompileOptions)(nil).solcArgs(solc), " ")
+	if !strings.Contains(args, "--optimize") || !strings.Contains(args, ",metadata") {
+		t.Fatalf("ERROR unexpected default solc arguments %q", args)
+	}
+
+	opts := &CompileOptions{OptimizeRuns: 1000, EVMVersion: "byzantium"}
+	args = strings.Join(opts.solcArgs(solc), " ")
+	if !strings.Contains(args, "--optimize-runs 1000") || !strings.Contains(args, "--evm-version byzantium") {
+		t.Fatalf("ERROR expected the optimizer runs and EVM version in %q", args)
+	}
+
+	opts = &CompileOptions{DisableOptimizer: true}
+	if args = strings.Join(opts.solcArgs(solc), " "); strings.Contains(args, "--optimize") {
+		t.Fatalf("ERROR expected no optimizer in %q", args)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/clearmatics/ion"
+)
+
+// ContractsDirEnv is the environment variable overriding the location of the Ion Solidity sources
+const ContractsDirEnv = "ION_CONTRACTS_DIR"
+
+// contractsDir overrides the location of the Ion Solidity sources
+var contractsDir string
+
+// SetContractsDir sets the directory the Ion Solidity sources are compiled from.
+// Passing an empty path restores the default lookup.
+func SetContractsDir(path string) {
+	contractsDir = path
+}
+
+// ContractsDir returns the directory the Ion Solidity sources are compiled from.
+// It is, in order, the directory given to SetContractsDir, the ION_CONTRACTS_DIR
+// environment variable, the contracts directory of the source tree this package
+// was built from, a contracts directory next to the running binary, or else the
+// sources embedded in the binary written out to a temporary directory.
+func ContractsDir() (string, error) {
+	dir := contractsDir
+	if dir == "" {
+		dir = os.Getenv(ContractsDirEnv)
+	}
+	if dir == "" {
+		dir = defaultContractsDir()
+	}
+	if dir == "" {
+		embeddedDir, err := embeddedContractsDir()
+		if err != nil {
+			return "", fmt.Errorf("contracts directory not found, set %s or use SetContractsDir: %v", ContractsDirEnv, err)
+		}
+		dir = embeddedDir
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve contracts directory %q: %v", dir, err)
+	}
+	return absDir, nil
+}
+
+// defaultContractsDir looks for the contracts directory in the source tree and
+// next to the running binary, returning an empty string when neither exists
+func defaultContractsDir() string {
+	var candidates []string
+	if _, file, _, ok := runtime.Caller(0); ok {
+		// ion-cli/contracts/compile.go -> contracts
+		candidates = append(candidates, filepath.Join(filepath.Dir(file), "..", "..", "contracts"))
+	}
+	if exe, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Join(filepath.Dir(exe), "contracts"))
+	}
+
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// contractsBasePath returns the contracts directory with a trailing separator,
+// checking the given source files are present in it
+func contractsBasePath(files ...string) (string, error) {
+	dir, err := ContractsDir()
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("contracts directory %q not found: %v", dir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("contracts path %q is not a directory", dir)
+	}
+
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			return "", fmt.Errorf("contract source %q not found in %q", file, dir)
+		}
+	}
+
+	return dir + string(filepath.Separator), nil
+}
+
+var (
+	extractOnce  sync.Once
+	extractedDir string
+	extractErr   error
+)
+
+// embeddedContractsDir writes the embedded Solidity sources to a temporary
+// directory once per process and returns its contracts directory
+func embeddedContractsDir() (string, error) {
+	extractOnce.Do(func() {
+		tmpDir, err := ioutil.TempDir("", "ion-contracts")
+		if err != nil {
+			extractErr = fmt.Errorf("failed to create directory for embedded contracts: %v", err)
+			return
+		}
+
+		extractErr = fs.WalkDir(ion.Contracts, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(tmpDir, filepath.FromSlash(path))
+			if d.IsDir() {
+				return os.MkdirAll(target, 0755)
+			}
+			data, err := ion.Contracts.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(target, data, 0644)
+		})
+		if extractErr != nil {
+			extractErr = fmt.Errorf("failed to write embedded contracts: %v", extractErr)
+			return
+		}
+		extractedDir = filepath.Join(tmpDir, "contracts")
+	})
+	return extractedDir, extractErr
+}
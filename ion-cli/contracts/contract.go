@@ -2,13 +2,16 @@
 package contract
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"encoding/json"
-	"log"
+	"fmt"
 	"math/big"
-	"os"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -23,33 +26,228 @@ import (
 type ContractInstance struct {
 	Contract *compiler.Contract
 	Address  common.Address
+	// TxHash of the deployment transaction, zero when not deployed by this package
+	TxHash common.Hash
+	// DeployTx is the signed deployment transaction, nil when not deployed by
+	// this package. In dry-run mode its gas limit reports the estimated gas.
+	DeployTx *types.Transaction
+	// Receipt of the deployment transaction, with the gas used and the logs of
+	// the constructor. Nil when not deployed by this package or in dry-run mode.
+	Receipt *types.Receipt
+
+	parsed  *parsedABI
+	backend bind.ContractBackend
+}
+
+// parsedABI caches the ABI of a contract instance, shared between its copies
+type parsedABI struct {
+	once sync.Once
+	abi  abi.ABI
+	err  error
+}
+
+// NewContractInstance returns the instance of a contract deployed at address
+func NewContractInstance(contract *compiler.Contract, address common.Address) ContractInstance {
+	return ContractInstance{
+		Contract: contract,
+		Address:  address,
+		parsed:   new(parsedABI),
+	}
+}
+
+// deployedInstance returns the instance of a contract deployed by tx on backend
+func deployedInstance(backend bind.ContractBackend, contract *compiler.Contract, address common.Address, tx *types.Transaction, receipt *types.Receipt) ContractInstance {
+	ci := NewContractInstance(contract, address).WithBackend(backend)
+	ci.TxHash = tx.Hash()
+	ci.DeployTx = tx
+	ci.Receipt = receipt
+	return ci
+}
+
+// WithBackend returns a copy of the instance using backend for Call and Transact.
+// Instances sent by the deploy functions use the backend they were deployed with.
+func (ci ContractInstance) WithBackend(backend bind.ContractBackend) ContractInstance {
+	ci.backend = backend
+	return ci
+}
+
+// ABI returns the parsed ABI of the contract. It is parsed once for instances
+// created with NewContractInstance.
+func (ci ContractInstance) ABI() (abi.ABI, error) {
+	if ci.parsed == nil {
+		return parseContractABI(ci.Contract)
+	}
+	ci.parsed.once.Do(func() {
+		ci.parsed.abi, ci.parsed.err = parseContractABI(ci.Contract)
+	})
+	return ci.parsed.abi, ci.parsed.err
+}
+
+// Bound returns the contract bound to its address on backend
+func (ci ContractInstance) Bound(backend bind.ContractBackend) (*bind.BoundContract, error) {
+	parsed, err := ci.ABI()
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(ci.Address, parsed, backend, backend, backend), nil
+}
+
+// Call calls the constant method of the contract and stores its outputs in
+// out, which holds a pointer to a value of the matching Go type per output
+func (ci ContractInstance) Call(ctx context.Context, method string, out []interface{}, args ...interface{}) error {
+	if ci.backend == nil {
+		return fmt.Errorf("contract instance has no backend, use WithBackend")
+	}
+	parsed, err := ci.ABI()
+	if err != nil {
+		return err
+	}
+	abiMethod, ok := parsed.Methods[method]
+	if !ok {
+		return fmt.Errorf("method %s not found in contract ABI", method)
+	}
+	if len(out) != len(abiMethod.Outputs) {
+		return fmt.Errorf("method %s has %d outputs, got %d", method, len(abiMethod.Outputs), len(out))
+	}
+
+	input, err := parsed.Pack(method, args...)
+	if err != nil {
+		return fmt.Errorf("failed to pack arguments of %s: %v", method, err)
+	}
+	output, err := ci.backend.CallContract(ctx, ethereum.CallMsg{To: &ci.Address, Data: input}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %v", method, err)
+	}
+	values, err := abiMethod.Outputs.UnpackValues(output)
+	if err != nil {
+		return fmt.Errorf("failed to unpack outputs of %s: %v", method, err)
+	}
+
+	for i, value := range values {
+		dst := reflect.ValueOf(out[i])
+		if dst.Kind() != reflect.Ptr || dst.IsNil() {
+			return fmt.Errorf("output %d of %s must be a non nil pointer", i, method)
+		}
+		src := reflect.ValueOf(value)
+		if !src.Type().AssignableTo(dst.Elem().Type()) {
+			return fmt.Errorf("output %d of %s is %v, can't store it in %v", i, method, src.Type(), dst.Elem().Type())
+		}
+		dst.Elem().Set(src)
+	}
+	return nil
+}
+
+// Transact sends a transaction calling method of the contract from userKey
+func (ci ContractInstance) Transact(
+	ctx context.Context,
+	userKey *ecdsa.PrivateKey,
+	amount *big.Int,
+	opts *TxOptions,
+	method string,
+	args ...interface{},
+) (*types.Transaction, error) {
+	if ci.backend == nil {
+		return nil, fmt.Errorf("contract instance has no backend, use WithBackend")
+	}
+	return transactContract(ctx, ci.backend, userKey, ci.Contract, ci.Address, amount, opts, method, args...)
+}
+
+func parseContractABI(c *compiler.Contract) (abi.ABI, error) {
+	if c == nil {
+		return abi.ABI{}, fmt.Errorf("contract not compiled")
+	}
+	abiBytes, err := json.Marshal(c.Info.AbiDefinition)
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("failed to marshal contract ABI: %v", err)
+	}
+	parsed, err := abi.JSON(bytes.NewReader(abiBytes))
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("failed to read contract ABI: %v", err)
+	}
+	return parsed, nil
+}
+
+// methodPayable reports whether the method of contract accepts ether, from the
+// payable flag or the stateMutability of older and newer ABIs
+func methodPayable(c *compiler.Contract, method string) (bool, error) {
+	if c == nil {
+		return false, fmt.Errorf("contract not compiled")
+	}
+	abiBytes, err := json.Marshal(c.Info.AbiDefinition)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal contract ABI: %v", err)
+	}
+	var entries []struct {
+		Type            string `json:"type"`
+		Name            string `json:"name"`
+		Payable         bool   `json:"payable"`
+		StateMutability string `json:"stateMutability"`
+	}
+	if err := json.Unmarshal(abiBytes, &entries); err != nil {
+		return false, fmt.Errorf("failed to read contract ABI: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Type == "function" && entry.Name == method {
+			return entry.Payable || entry.StateMutability == "payable", nil
+		}
+	}
+	return false, fmt.Errorf("method %s not found in contract ABI", method)
 }
 
 // GENERIC UTIL FUNCTIONS
 
-func getContractBytecodeAndABI(c *compiler.Contract) (string, string) {
+// ContractBytecodeAndABI returns the hex bytecode, without 0x prefix, and the
+// JSON ABI of a compiled contract, as the deploy functions take them
+func ContractBytecodeAndABI(c *compiler.Contract) (bin string, abi string, err error) {
+	if c == nil {
+		return "", "", fmt.Errorf("contract not compiled")
+	}
+	if c.Info.AbiDefinition == nil {
+		return "", "", fmt.Errorf("contract has no ABI")
+	}
+	bin = strings.TrimPrefix(c.Code, "0x")
+	if bin == "" {
+		return "", "", fmt.Errorf("contract has no bytecode")
+	}
+
 	cABIBytes, err := json.Marshal(c.Info.AbiDefinition)
 	if err != nil {
-		log.Fatal("ERROR marshalling contract ABI:", err)
+		return "", "", fmt.Errorf("failed to marshal contract ABI: %v", err)
 	}
+	return bin, string(cABIBytes), nil
+}
 
-	contractBinStr := c.Code[2:]
-	contractABIStr := string(cABIBytes)
-	return contractBinStr, contractABIStr
+func getContractBytecodeAndABI(c *compiler.Contract) (string, string, error) {
+	return ContractBytecodeAndABI(c)
 }
 
-func generateContractPayload(contractBinStr string, contractABIStr string, constructorArgs ...interface{}) []byte {
+// compiledContract returns the contract key, as path:Name, of the compiled
+// contracts, naming the contracts compiled when it is missing, e.g. after the
+// contract was renamed
+func compiledContract(contracts map[string]*compiler.Contract, key string) (*compiler.Contract, error) {
+	if contract, ok := contracts[key]; ok && contract != nil {
+		return contract, nil
+	}
+	compiled := make([]string, 0, len(contracts))
+	for name := range contracts {
+		compiled = append(compiled, name)
+	}
+	sort.Strings(compiled)
+	return nil, fmt.Errorf("contract %s not compiled, got [%s]", key, strings.Join(compiled, ", "))
+}
+
+func generateContractPayload(contractBinStr string, contractABIStr string, constructorArgs ...interface{}) ([]byte, error) {
 	bytecode := common.Hex2Bytes(contractBinStr)
 	abiContract, err := abi.JSON(strings.NewReader(contractABIStr))
 	if err != nil {
-		log.Fatal("ERROR reading contract ABI ", err)
+		return nil, fmt.Errorf("failed to read contract ABI: %v", err)
 	}
 	packedABI, err := abiContract.Pack("", constructorArgs...)
 	if err != nil {
-		log.Fatal("ERROR packing ABI ", err)
+		return nil, fmt.Errorf("failed to pack constructor arguments: %v", err)
 	}
 	payloadBytecode := append(bytecode, packedABI...)
-	return payloadBytecode
+	return payloadBytecode, nil
 }
 
 func newTx(
@@ -57,18 +255,24 @@ func newTx(
 	backend bind.ContractBackend,
 	from, to *common.Address,
 	amount *big.Int,
-	gasLimit uint64,
+	opts *TxOptions,
 	payloadBytecode []byte,
-) *types.Transaction {
+) (*types.Transaction, error) {
 
-	nonce, err := backend.PendingNonceAt(ctx, *from) // uint64(0)
+	nonce, err := opts.nonce(ctx, backend, *from)
 	if err != nil {
-		log.Fatal("Error getting pending nonce ", err)
+		return nil, err
 	}
-	gasPrice, err := backend.SuggestGasPrice(ctx) //new(big.Int)
+	gasPrice, err := opts.gasPrice(ctx, backend)
 	if err != nil {
-		log.Fatal("Error suggesting gas price ", err)
+		return nil, err
 	}
+	gasLimit := opts.gasLimit(ctx, backend, ethereum.CallMsg{
+		From:  *from,
+		To:    to,
+		Value: amount,
+		Data:  payloadBytecode,
+	})
 
 	// create contract transaction NewContractCreation is the same has NewTransaction with `to` == nil
 	// tx := types.NewTransaction(nonce, nil, amount, gasLimit, gasPrice, payloadBytecode)
@@ -78,17 +282,88 @@ func newTx(
 	} else {
 		tx = types.NewTransaction(nonce, *to, amount, gasLimit, gasPrice, payloadBytecode)
 	}
-	return tx
+	return tx, nil
+}
+
+// waitDeployed waits for the deployment tx of the named contract to be mined.
+// A cancelled ctx is reported with errCancelled. In dry-run mode it returns the
+// address the contract would be created at without waiting.
+func waitDeployed(ctx context.Context, backend bind.DeployBackend, tx *types.Transaction, name string, opts *TxOptions) (common.Address, *types.Receipt, error) {
+	if opts.dryRun() {
+		// nothing was sent, the contract gets the address it would be created at
+		from, err := types.Sender(opts.signer(), tx)
+		if err != nil {
+			return common.Address{}, nil, fmt.Errorf("failed to recover %s deployment sender: %v", name, err)
+		}
+		return crypto.CreateAddress(from, tx.Nonce()), nil, nil
+	}
+
+	retry := opts.retry()
+	for attempt := 1; ; attempt++ {
+		addr, receipt, err := WaitDeployedWithReceipt(ctx, backend, tx)
+		if err == nil && opts.confirmations() > 0 {
+			// the deployment is mined, failing to confirm it isn't retried
+			if receipt, err = WaitConfirmations(ctx, backend, tx, opts.confirmations()); err != nil {
+				if ctx.Err() != nil {
+					return common.Address{}, nil, errCancelled(ctx.Err())
+				}
+				return common.Address{}, nil, fmt.Errorf("failed waiting for %s deployment: %w", name, err)
+			}
+		}
+		if err == nil {
+			opts.logger().Debug("Contract deployed", "contract", name, "address", addr.Hex(), "tx", tx.Hash().Hex(), "gasUsed", receipt.GasUsed)
+			return addr, receipt, nil
+		}
+		if ctx.Err() != nil {
+			return common.Address{}, nil, errCancelled(ctx.Err())
+		}
+		// only network failures may succeed by asking again
+		if !isTransientError(err) || attempt >= retry.attempts {
+			return common.Address{}, receipt, fmt.Errorf("failed waiting for %s deployment: %w", name, err)
+		}
+
+		opts.logger().Warn("Waiting for deployment failed, retrying", "contract", name, "delay", retry.delay, "err", err)
+		if err := retry.wait(ctx); err != nil {
+			return common.Address{}, nil, err
+		}
+	}
+}
+
+// sendInstance sends ci unless ctx is cancelled first, in which case the
+// cancellation is sent on errChan. It reports whether ci was sent.
+func sendInstance(ctx context.Context, resChan chan<- ContractInstance, errChan chan<- error, ci ContractInstance) bool {
+	select {
+	case resChan <- ci:
+		return true
+	case <-ctx.Done():
+		errChan <- stageError(ctx, StageWait, errCancelled(ctx.Err()))
+		return false
+	}
+}
+
+// failedDeployment returns the channels of a deployment failed with err
+// before starting
+func failedDeployment(err error) (<-chan ContractInstance, <-chan error) {
+	resChan := make(chan ContractInstance)
+	errChan := make(chan error, 1)
+	errChan <- err
+	close(errChan)
+	close(resChan)
+	return resChan, errChan
+}
+
+// errCancelled wraps the context error of a cancelled deployment
+func errCancelled(err error) error {
+	return fmt.Errorf("deployment cancelled: %w", err)
 }
 
 // method created just to easily sign a tranasaction
-func signTx(tx *types.Transaction, userKey *ecdsa.PrivateKey) *types.Transaction {
-	signer := types.HomesteadSigner{} // this functions makes it easier to change signer if needed
-	signedTx, err := types.SignTx(tx, signer, userKey)
+func signTx(tx *types.Transaction, account AccountSigner, opts *TxOptions) (*types.Transaction, error) {
+	signedTx, err := account.SignTx(tx, opts.chainID())
 	if err != nil {
-		log.Fatal("Error signing tx: ", err)
+		return nil, fmt.Errorf("failed to sign transaction: %v", err)
 	}
-	return signedTx
+	return signedTx, nil
 }
 
 func compileAndDeployContract(
@@ -98,22 +373,71 @@ func compileAndDeployContract(
 	binStr string,
 	abiStr string,
 	amount *big.Int,
-	gasLimit uint64,
+	opts *TxOptions,
 	constructorArgs ...interface{},
-) *types.Transaction {
-	payload := generateContractPayload(binStr, abiStr, constructorArgs...)
-	userAddr := crypto.PubkeyToAddress(userKey.PublicKey)
-	tx := newTx(ctx, backend, &userAddr, nil, amount, gasLimit, payload)
-	signedTx := signTx(tx, userKey)
+) (*types.Transaction, error) {
+	account, err := opts.account(userKey)
+	if err != nil {
+		return nil, err
+	}
+	return deployContract(ctx, backend, account, binStr, abiStr, amount, opts, constructorArgs...)
+}
 
-	err := backend.SendTransaction(ctx, signedTx)
+func deployContract(
+	ctx context.Context,
+	backend bind.ContractBackend,
+	account AccountSigner,
+	binStr string,
+	abiStr string,
+	amount *big.Int,
+	opts *TxOptions,
+	constructorArgs ...interface{},
+) (*types.Transaction, error) {
+	binStr, err := opts.link(binStr)
 	if err != nil {
-		log.Fatal("ERROR sending contract deployment transaction")
+		return nil, err
 	}
-	return signedTx
+	payload, err := generateContractPayload(binStr, abiStr, constructorArgs...)
+	if err != nil {
+		return nil, err
+	}
+	signedTx, err := signAndSend(ctx, backend, account, nil, amount, opts, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send contract deployment transaction: %w", err)
+	}
+	return signedTx, nil
 }
 
-// CallContract without changing the state
+// sendTx broadcasts signedTx from the from account, or only logs it in dry-run mode
+func sendTx(ctx context.Context, backend bind.ContractTransactor, from common.Address, signedTx *types.Transaction, opts *TxOptions) error {
+	if opts.dryRun() {
+		to := "contract creation"
+		if signedTx.To() != nil {
+			to = signedTx.To().Hex()
+		}
+		opts.logger().Info(
+			"Dry run transaction",
+			"hash", signedTx.Hash().Hex(),
+			"from", from.Hex(),
+			"to", to,
+			"value", signedTx.Value(),
+			"gas", signedTx.Gas(),
+			"gasPrice", signedTx.GasPrice(),
+			"nonce", signedTx.Nonce(),
+			"data", fmt.Sprintf("0x%x", signedTx.Data()),
+		)
+		return nil
+	}
+
+	if err := backend.SendTransaction(ctx, signedTx); err != nil {
+		return err
+	}
+	opts.logger().Debug("Transaction sent", "hash", signedTx.Hash().Hex(), "from", from.Hex(), "nonce", signedTx.Nonce())
+	opts.submitted(signedTx)
+	return nil
+}
+
+// CallContract without changing the state, unpacking the result into out
 func CallContract(
 	ctx context.Context,
 	client bind.ContractCaller,
@@ -122,33 +446,50 @@ func CallContract(
 	methodName string,
 	out interface{},
 	args ...interface{},
-) {
+) error {
+	err := callContract(ctx, client, contract, from, to, methodName, out, args...)
+	if err != nil {
+		packageLogger.Error("Contract call failed", "method", methodName, "to", to.Hex(), "err", err)
+	}
+	return err
+}
+
+func callContract(
+	ctx context.Context,
+	client bind.ContractCaller,
+	contract *compiler.Contract,
+	from, to common.Address,
+	methodName string,
+	out interface{},
+	args ...interface{},
+) error {
 	abiStr, err := json.Marshal(contract.Info.AbiDefinition)
 	if err != nil {
-		log.Fatal("ERROR marshalling abi to string", err)
+		return fmt.Errorf("failed to marshal abi to string: %v", err)
 	}
 
 	abiContract, err := abi.JSON(strings.NewReader(string(abiStr)))
 	if err != nil {
-		log.Fatal("ERROR reading contract ABI ", err)
+		return fmt.Errorf("failed to read contract ABI: %v", err)
 	}
 
 	input, err := abiContract.Pack(methodName, args...)
 	if err != nil {
-		log.Fatal("ERROR packing the method name for the contract call: ", err)
+		return fmt.Errorf("failed to pack the call of %s: %v", methodName, err)
 	}
 	msg := ethereum.CallMsg{From: from, To: &to, Data: input}
 	output, err := client.CallContract(ctx, msg, nil)
 	if err != nil {
-		log.Fatal("ERROR calling the Ion Contract", err)
+		return fmt.Errorf("failed to call %s: %v", methodName, err)
 	}
-	err = abiContract.Unpack(out, methodName, output)
-	if err != nil {
-		log.Fatal("ERROR upacking the call: ", err)
+	if err := abiContract.Unpack(out, methodName, output); err != nil {
+		return fmt.Errorf("failed to unpack the result of %s: %v", methodName, err)
 	}
+	return nil
 }
 
 // TransactionContract execute function in contract
+// A nil amount sends no ether, like a zero amount.
 func TransactionContract(
 	ctx context.Context,
 	backend bind.ContractBackend,
@@ -156,46 +497,192 @@ func TransactionContract(
 	contract *compiler.Contract,
 	to common.Address,
 	amount *big.Int,
-	gasLimit uint64,
+	opts *TxOptions,
 	methodName string,
 	args ...interface{},
-) *types.Transaction {
-	abiStr, err := json.Marshal(contract.Info.AbiDefinition)
+) (*types.Transaction, error) {
+	signedTx, err := transactContract(ctx, backend, userKey, contract, to, amount, opts, methodName, args...)
 	if err != nil {
-		log.Fatal("ERROR marshalling abi to string", err)
+		opts.logger().Error("Transaction failed", "method", methodName, "to", to.Hex(), "err", err)
+		return nil, err
 	}
+	return signedTx, nil
+}
 
-	abiContract, err := abi.JSON(strings.NewReader(string(abiStr)))
+// transactContract sends a transaction calling methodName, returning any failure
+func transactContract(
+	ctx context.Context,
+	backend bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	contract *compiler.Contract,
+	to common.Address,
+	amount *big.Int,
+	opts *TxOptions,
+	methodName string,
+	args ...interface{},
+) (*types.Transaction, error) {
+	account, err := opts.account(userKey)
 	if err != nil {
-		log.Fatal("ERROR reading contract ABI ", err)
+		return nil, err
 	}
+	return TransactWithSigner(ctx, backend, account, contract, to, amount, opts, methodName, args...)
+}
 
-	payload, err := abiContract.Pack(methodName, args...)
+// TransactWithSigner sends a transaction calling methodName of the contract
+// at to, signed by account
+func TransactWithSigner(
+	ctx context.Context,
+	backend bind.ContractBackend,
+	account AccountSigner,
+	contract *compiler.Contract,
+	to common.Address,
+	amount *big.Int,
+	opts *TxOptions,
+	methodName string,
+	args ...interface{},
+) (*types.Transaction, error) {
+	abiContract, err := parseContractABI(contract)
 	if err != nil {
-		log.Fatal("ERROR packing the method name for the contract call: ", err)
+		return nil, err
 	}
+	return transactABI(ctx, backend, account, abiContract, to, amount, opts, methodName, args...)
+}
 
-	from := crypto.PubkeyToAddress(userKey.PublicKey)
-	tx := newTx(ctx, backend, &from, &to, amount, gasLimit, payload)
-	signedTx := signTx(tx, userKey)
+// TransactionContractABI sends a transaction calling methodName of the contract
+// at to like TransactionContract, packing the arguments with the parsed ABI
+// rather than parsing the ABI of a compiled contract on every call, e.g. in a
+// relay loop
+func TransactionContractABI(
+	ctx context.Context,
+	backend bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	parsedABI abi.ABI,
+	to common.Address,
+	amount *big.Int,
+	opts *TxOptions,
+	methodName string,
+	args ...interface{},
+) (*types.Transaction, error) {
+	account, err := opts.account(userKey)
+	if err != nil {
+		return nil, err
+	}
+	return transactABI(ctx, backend, account, parsedABI, to, amount, opts, methodName, args...)
+}
 
-	err = backend.SendTransaction(ctx, signedTx)
+// transactABI sends a transaction calling methodName, packed with abiContract,
+// signed by account. The arguments are checked against the method inputs
+// first, see checkArgs. A nil amount is sent as zero.
+func transactABI(
+	ctx context.Context,
+	backend bind.ContractBackend,
+	account AccountSigner,
+	abiContract abi.ABI,
+	to common.Address,
+	amount *big.Int,
+	opts *TxOptions,
+	methodName string,
+	args ...interface{},
+) (*types.Transaction, error) {
+	if method, ok := abiContract.Methods[methodName]; ok {
+		if err := checkArgs(method, args); err != nil {
+			return nil, fmt.Errorf("invalid arguments of %s: %v", methodName, err)
+		}
+	}
+	payload, err := abiContract.Pack(methodName, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack arguments of %s: %v", methodName, err)
+	}
+	if amount == nil {
+		amount = big.NewInt(0)
+	}
+
+	signedTx, err := signAndSend(ctx, backend, account, &to, amount, opts, payload)
 	if err != nil {
-		log.Fatal("ERROR sending transaction: ", err)
+		return nil, fmt.Errorf("failed to send transaction: %w", err)
 	}
-	return signedTx
+	return signedTx, nil
 }
 
-func CompileContract(contract string) (compiledContract *compiler.Contract) {
-	basePath := os.Getenv("GOPATH") + "/src/github.com/clearmatics/ion/contracts/"
-	contractPath := basePath + contract + ".sol"
+// checkArgs checks the number and Go types of args match the inputs of
+// method, so a mistake is reported with the argument at fault rather than by
+// the packing deep in go-ethereum
+func checkArgs(method abi.Method, args []interface{}) error {
+	if len(args) != len(method.Inputs) {
+		return fmt.Errorf("expected %d arguments, got %d", len(method.Inputs), len(args))
+	}
+	for i, input := range method.Inputs {
+		if err := checkArg(input.Type, reflect.ValueOf(args[i])); err != nil {
+			return fmt.Errorf("arg %d (%s): %v", i, input.Name, err)
+		}
+	}
+	return nil
+}
+
+// checkArg checks v can be packed as an ABI value of type t
+func checkArg(t abi.Type, v reflect.Value) error {
+	mismatch := func() error {
+		if !v.IsValid() {
+			return fmt.Errorf("expected %v (%v), got nil", t, t.Type)
+		}
+		switch v.Kind() {
+		case reflect.Ptr:
+			if v.IsNil() {
+				return fmt.Errorf("expected %v (%v), got nil %v", t, t.Type, v.Type())
+			}
+		case reflect.Slice, reflect.Array, reflect.String:
+			return fmt.Errorf("expected %v (%v), got %v of len %d", t, t.Type, v.Type(), v.Len())
+		}
+		return fmt.Errorf("expected %v (%v), got %v", t, t.Type, v.Type())
+	}
+
+	// elements of []interface{} are unwrapped and pointers are packed as the
+	// value they point to, except big integers
+	for v.IsValid() && (v.Kind() == reflect.Interface || (v.Kind() == reflect.Ptr && t.Type.Kind() != reflect.Ptr)) && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		return mismatch()
+	}
+
+	switch t.T {
+	case abi.SliceTy, abi.ArrayTy:
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return mismatch()
+		}
+		if t.T == abi.ArrayTy && v.Len() != t.Size {
+			return mismatch()
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := checkArg(*t.Elem, v.Index(i)); err != nil {
+				return fmt.Errorf("element %d: %v", i, err)
+			}
+		}
+		return nil
+	default:
+		// named types like common.Hash pack as their underlying type
+		if v.Kind() != t.Type.Kind() || !v.Type().ConvertibleTo(t.Type) {
+			return mismatch()
+		}
+		return nil
+	}
+}
 
-	contracts, err := compiler.CompileSolidity("", contractPath)
+// CompileContract compiles the named contract from the contracts directory,
+// e.g. "Trigger" for contracts/Trigger.sol
+func CompileContract(contract string, compileOpts *CompileOptions) (*compiler.Contract, error) {
+	basePath, err := contractsBasePath(contract + ".sol")
 	if err != nil {
-		log.Fatal("ERROR failed to compile contract:", err)
+		packageLogger.Error("Failed to locate contract source", "contract", contract, "err", err)
+		return nil, fmt.Errorf("failed to locate contract source: %v", err)
 	}
+	contractPath := basePath + contract + ".sol"
 
-	compiledContract = contracts[basePath+contract+".sol:"+contract]
+	contracts, err := Compile(compileOpts, contractPath)
+	if err != nil {
+		packageLogger.Error("Failed to compile contract", "contract", contract, "err", err)
+		return nil, fmt.Errorf("failed to compile contract: %v", err)
+	}
 
-	return
+	return compiledContract(contracts, contractPath+":"+contract)
 }
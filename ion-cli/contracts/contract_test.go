@@ -4,15 +4,27 @@ package contract
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
+	"errors"
 	"log"
 	"math/big"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/clearmatics/ion/ion-cli/utils"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 func Test_CompileAndDeployIon(t *testing.T) {
@@ -37,7 +49,7 @@ func Test_CompileAndDeployIon(t *testing.T) {
 	chainID := crypto.Keccak256Hash([]byte("test argument")) // Ion argument
 
 	// start compile and deploy ion
-	contractChan := CompileAndDeployIon(ctx, blockchain, userAKey, chainID)
+	contractChan, errChan := CompileAndDeployIon(ctx, blockchain, userAKey, chainID, nil, nil)
 
 	// commit first block after sent transaction for deployment of patricia trie lib
 	blockchain.Commit()
@@ -46,16 +58,86 @@ func Test_CompileAndDeployIon(t *testing.T) {
 
 	// commit after transaction for deployment of Ion (with reference to Patricia Trie lib) as been sent
 	blockchain.Commit()
-	ionContractInstance := <-contractChan
+	ionContractInstance, ok := <-contractChan
+	if !ok {
+		t.Fatal("ERROR deploying Ion", <-errChan)
+	}
 
 	// call contract variable
 	methodName := "chainId"
 	out := new([32]byte)
-	CallContract(ctx, blockchain, ionContractInstance.Contract, userAddr, ionContractInstance.Address, methodName, out)
+	if err := CallContract(ctx, blockchain, ionContractInstance.Contract, userAddr, ionContractInstance.Address, methodName, out); err != nil {
+		t.Fatal("ERROR calling Ion", err)
+	}
 
 	if !bytes.Equal((*out)[:], chainID.Bytes()) {
 		t.Fatal("ERROR chainID result from contract call, and sent to contract constructor differ")
 	}
+
+	// same call through the bound contract
+	boundIon, err := ionContractInstance.Bound(blockchain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	boundOut := new([32]byte)
+	if err := boundIon.Call(&bind.CallOpts{From: userAddr}, boundOut, methodName); err != nil {
+		t.Fatal(err)
+	}
+	if *boundOut != *out {
+		t.Fatal("ERROR chainID result from bound contract call differs")
+	}
+
+	// same call through the instance backend
+	var instanceOut [32]byte
+	if err := ionContractInstance.Call(ctx, methodName, []interface{}{&instanceOut}); err != nil {
+		t.Fatal(err)
+	}
+	if instanceOut != *out {
+		t.Fatal("ERROR chainID result from instance call differs")
+	}
+}
+
+func Test_CompileAndDeployTriggerVerifierAndConsumerFunction(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ionAddr := common.HexToAddress("0x01")
+
+	contractChan, errChan := CompileAndDeployTriggerVerifierAndConsumerFunction(ctx, blockchain, userKey, ionAddr, nil, nil)
+
+	// the consumer function is sent once the trigger event verifier is mined
+	var instances []ContractInstance
+	for i := 0; i < 2; i++ {
+		blockchain.Commit()
+		instance, ok := <-contractChan
+		if !ok {
+			t.Fatal("ERROR deploying trigger verifier and consumer function", <-errChan)
+		}
+		instances = append(instances, instance)
+	}
+	if _, ok := <-contractChan; ok {
+		t.Fatal("ERROR expected only two contract instances")
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal("ERROR unexpected deployment error", err)
+	}
+
+	for i, instance := range instances {
+		code, err := blockchain.CodeAt(ctx, instance.Address, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) == 0 {
+			t.Fatalf("ERROR no code at the address of contract %d", i)
+		}
+		if instance.Receipt == nil || instance.Receipt.ContractAddress != instance.Address {
+			t.Fatalf("ERROR expected the deployment receipt of contract %d", i)
+		}
+	}
 }
 
 func Test_RegisterChain(t *testing.T) {
@@ -84,25 +166,31 @@ func Test_RegisterChain(t *testing.T) {
 	blockchain := backends.NewSimulatedBackend(alloc)
 	var chainID [32]byte
 	copy(chainID[:], crypto.Keccak256Hash([]byte("DEPLOYEDCHAINID")).Bytes())
-	contractChan := CompileAndDeployIon(ctx, blockchain, userAKey, chainID)
+	ionChan, ionErrChan := CompileAndDeployIon(ctx, blockchain, userAKey, chainID, nil, nil)
 	blockchain.Commit()
-	<-contractChan
+	<-ionChan
 	blockchain.Commit()
-	ionContractInstance := <-contractChan
+	ionContractInstance, ok := <-ionChan
+	if !ok {
+		t.Fatal("ERROR deploying Ion", <-ionErrChan)
+	}
 
 	// deploy validation contract
 	var ionContractAddr [20]byte
 	copy(ionContractAddr[:], ionContractInstance.Address.Bytes())
-	contractChan = CompileAndDeployValidation(ctx, blockchain, userAKey, chainID, ionContractAddr)
+	validationChan, validationErrChan := CompileAndDeployValidation(ctx, blockchain, userAKey, chainID, ionContractAddr, nil, nil)
 	blockchain.Commit()
-	validationContractInstance := <-contractChan
+	validationContractInstance, ok := <-validationChan
+	if !ok {
+		t.Fatal("ERROR deploying Validation", <-validationErrChan)
+	}
 
 	var chainIDA [32]byte
 	var validationAddress [20]byte
 	copy(validationAddress[:], validationContractInstance.Address.Bytes())
 	copy(chainIDA[:], crypto.Keccak256Hash([]byte("TESTCHAINID")).Bytes())
 	deployedChainID := common.HexToHash("0xab830ae0774cb20180c8b463202659184033a9f30a21550b89a2b406c3ac8075")
-	txRegisterChain := RegisterChain(
+	txRegisterChain, err := RegisterChain(
 		ctx,
 		blockchain,
 		userAKey,
@@ -112,6 +200,9 @@ func Test_RegisterChain(t *testing.T) {
 		testValidators,
 		deployedChainID,
 	)
+	if err != nil {
+		t.Fatal("ERROR registering chain", err)
+	}
 	blockchain.Commit()
 
 	registerChainReceipt, err := bind.WaitMined(ctx, blockchain, txRegisterChain)
@@ -124,7 +215,7 @@ func Test_RegisterChain(t *testing.T) {
 
 	methodName := "chains"
 	var isChainRegistered bool
-	CallContract(
+	err = CallContract(
 		ctx,
 		blockchain,
 		validationContractInstance.Contract,
@@ -134,8 +225,877 @@ func Test_RegisterChain(t *testing.T) {
 		&isChainRegistered,
 		chainID,
 	)
+	if err != nil {
+		t.Fatal("ERROR calling Validation", err)
+	}
 
 	if !isChainRegistered {
 		t.Log("ERROR expecting value of chains(validation.address) to be true, but it was ", isChainRegistered)
 	}
 }
+
+// compileTrigger compiles contracts/Trigger.sol, skipping the test when solc
+// is not installed
+func compileTrigger(t *testing.T) (binStr string, abiStr string) {
+	if _, err := exec.LookPath("solc"); err != nil {
+		t.Skip("solc not installed")
+	}
+	dir, err := ContractsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	binStr, abiStr, err = CompileContractFile(filepath.Join(dir, "Trigger.sol"), "Trigger", nil)
+	if err != nil {
+		t.Fatal("ERROR compiling Trigger", err)
+	}
+	return binStr, abiStr
+}
+
+func Test_EstimateDeployGas(t *testing.T) {
+	ctx := context.Background()
+	blockchain := backends.NewSimulatedBackend(make(core.GenesisAlloc))
+
+	binStr, abiStr := compileTrigger(t)
+
+	gas, err := EstimateDeployGas(ctx, blockchain, binStr, abiStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gas == 0 || gas > DefaultGasLimit {
+		t.Fatalf("ERROR unexpected deployment gas estimate %d", gas)
+	}
+
+	// Trigger has no constructor, so extra arguments must fail to pack
+	if _, err := EstimateDeployGas(ctx, blockchain, binStr, abiStr, big.NewInt(1)); err == nil {
+		t.Fatal("ERROR expected constructor argument packing to fail")
+	}
+}
+
+func Test_DeployCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	userKey, _ := crypto.GenerateKey()
+	alloc := make(core.GenesisAlloc)
+	alloc[crypto.PubkeyToAddress(userKey.PublicKey)] = core.GenesisAccount{
+		Balance: big.NewInt(1000000000),
+	}
+	blockchain := backends.NewSimulatedBackend(alloc)
+
+	contractChan, errChan := CompileAndDeployTriggerVerifierAndConsumerFunction(
+		ctx,
+		blockchain,
+		userKey,
+		common.Address{},
+		nil,
+		nil,
+	)
+
+	// the first deployment is sent but never committed, cancel while waiting for it
+	cancel()
+
+	if _, ok := <-contractChan; ok {
+		t.Fatal("ERROR expected no contract instance after cancellation")
+	}
+	if err := <-errChan; !errors.Is(err, context.Canceled) {
+		t.Fatalf("ERROR expected cancellation error, got %v", err)
+	}
+}
+
+func Test_DeployAbandonedAfterFirstInstance(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contractChan, errChan := CompileAndDeployTriggerVerifierAndConsumerFunction(ctx, blockchain, userKey, common.Address{}, nil, nil)
+
+	blockchain.Commit()
+	if _, ok := <-contractChan; !ok {
+		t.Fatal("ERROR deploying trigger verifier", <-errChan)
+	}
+
+	// the consumer function is mined but never received, the goroutine must
+	// give up sending it once cancelled
+	blockchain.Commit()
+	cancel()
+
+	select {
+	case err := <-errChan:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("ERROR expected cancellation error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ERROR deployment goroutine still blocked after cancellation")
+	}
+	// the channels are closed as the goroutine returns
+	if _, ok := <-errChan; ok {
+		t.Fatal("ERROR expected the error channel to be closed")
+	}
+}
+
+func Test_DeployProgress(t *testing.T) {
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the callback runs on the pipeline goroutine, the channel orders it
+	progress := make(chan string, 5)
+	opts := &TxOptions{OnProgress: func(stage string, detail interface{}) {
+		if detail == nil {
+			t.Errorf("ERROR expected a detail for stage %s", stage)
+		}
+		progress <- stage
+	}}
+
+	contractChan, errChan := CompileAndDeployTriggerVerifierAndConsumerFunction(context.Background(), blockchain, userKey, common.Address{}, opts, nil)
+	for i := 0; i < 2; i++ {
+		blockchain.Commit()
+		if _, ok := <-contractChan; !ok {
+			t.Fatal("ERROR deploying trigger verifier and consumer function", <-errChan)
+		}
+	}
+	close(progress)
+
+	var stages []string
+	for stage := range progress {
+		stages = append(stages, stage)
+	}
+	expected := []string{ProgressCompiled, ProgressTriggerSubmitted, ProgressTriggerMined, ProgressFunctionSubmitted, ProgressFunctionMined}
+	if strings.Join(stages, ",") != strings.Join(expected, ",") {
+		t.Fatalf("ERROR expected progress %v, got %v", expected, stages)
+	}
+}
+
+// flakyDeployBackend fails to return the deployed code a number of times,
+// with err or else a connection reset
+type flakyDeployBackend struct {
+	failures int
+	calls    int
+	err      error
+}
+
+func (b *flakyDeployBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return &types.Receipt{Status: types.ReceiptStatusSuccessful, ContractAddress: common.HexToAddress("0x01")}, nil
+}
+
+func (b *flakyDeployBackend) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	b.calls++
+	if b.calls <= b.failures {
+		if b.err != nil {
+			return nil, b.err
+		}
+		return nil, errors.New("connection reset by peer")
+	}
+	return []byte{0x60}, nil
+}
+
+func Test_WaitDeployedRetry(t *testing.T) {
+	ctx := context.Background()
+	tx := types.NewContractCreation(0, big.NewInt(0), 0, big.NewInt(0), nil)
+	opts := &TxOptions{Retry: &RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+
+	backend := &flakyDeployBackend{failures: 2}
+	addr, _, err := waitDeployed(ctx, backend, tx, "test", opts)
+	if err != nil {
+		t.Fatal("ERROR expected deployment after retries: ", err)
+	}
+	if addr != common.HexToAddress("0x01") {
+		t.Fatalf("ERROR unexpected contract address %x", addr)
+	}
+
+	backend = &flakyDeployBackend{failures: 3}
+	if _, _, err := waitDeployed(ctx, backend, tx, "test", opts); err == nil {
+		t.Fatal("ERROR expected failure once attempts are exhausted")
+	}
+
+	backend = &flakyDeployBackend{failures: 1}
+	if _, _, err := waitDeployed(ctx, backend, tx, "test", nil); err == nil {
+		t.Fatal("ERROR expected no retry without a retry policy")
+	}
+
+	backend = &flakyDeployBackend{failures: 1, err: errors.New("missing trie node")}
+	if _, _, err := waitDeployed(ctx, backend, tx, "test", opts); err == nil || backend.calls != 1 {
+		t.Fatalf("ERROR expected no retry of a permanent error, got %v after %d calls", err, backend.calls)
+	}
+}
+
+// contractOnlyBackend hides the receipts of the wrapped backend, so the
+// deployments can be sent but not waited for
+type contractOnlyBackend struct {
+	bind.ContractBackend
+}
+
+func Test_DeployWithoutDeployBackend(t *testing.T) {
+	ctx := context.Background()
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := contractOnlyBackend{blockchain}
+
+	contractChan, errChan := DeployPrecompiled(ctx, client, userKey, "[]", "0x6060", nil)
+	if _, ok := <-contractChan; ok {
+		t.Fatal("ERROR expected no deployment without a DeployBackend")
+	}
+	if err := <-errChan; err == nil {
+		t.Fatal("ERROR expected the missing DeployBackend to be reported")
+	}
+
+	contract, err := precompiledContract("[]", "6060")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DeployAll(ctx, client, userKey, []DeploySpec{{Name: "First", Contract: contract}}); err == nil {
+		t.Fatal("ERROR expected the missing DeployBackend to be reported")
+	}
+}
+
+func Test_DeployDryRun(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	userAddr := crypto.PubkeyToAddress(userKey.PublicKey)
+	alloc := make(core.GenesisAlloc)
+	alloc[userAddr] = core.GenesisAccount{Balance: big.NewInt(1000000000)}
+	blockchain := backends.NewSimulatedBackend(alloc)
+
+	chainID := crypto.Keccak256Hash([]byte("test argument"))
+	logger := &recordingLogger{}
+	contractChan, errChan := CompileAndDeployIon(ctx, blockchain, userKey, chainID, &TxOptions{DryRun: true, Logger: logger}, nil)
+
+	// nothing is committed, dry-run deployments don't wait to be mined
+	patriciaTrieContractInstance, ok := <-contractChan
+	if !ok {
+		t.Fatal("ERROR dry-run deploying PatriciaTrie", <-errChan)
+	}
+	ionContractInstance, ok := <-contractChan
+	if !ok {
+		t.Fatal("ERROR dry-run deploying Ion", <-errChan)
+	}
+
+	if patriciaTrieContractInstance.Address != crypto.CreateAddress(userAddr, 0) {
+		t.Fatal("ERROR unexpected PatriciaTrie dry-run address")
+	}
+	if ionContractInstance.Address != crypto.CreateAddress(userAddr, 1) {
+		t.Fatal("ERROR unexpected Ion dry-run address")
+	}
+
+	nonce, err := blockchain.PendingNonceAt(ctx, userAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != 0 {
+		t.Fatal("ERROR dry-run transactions were sent")
+	}
+	if len(logger.infos) != 2 {
+		t.Fatalf("ERROR expected 2 dry-run transactions logged, got %d", len(logger.infos))
+	}
+}
+
+// recordingLogger keeps the messages of the info logs
+type recordingLogger struct {
+	NopLogger
+	infos []string
+}
+
+func (l *recordingLogger) Info(msg string, keyvals ...interface{}) {
+	l.infos = append(l.infos, msg)
+}
+
+// chainIDBackend reports a fixed chain id
+type chainIDBackend struct {
+	chainID *big.Int
+}
+
+func (b chainIDBackend) ChainID(ctx context.Context) (*big.Int, error) {
+	return b.chainID, nil
+}
+
+func Test_ChainIDMismatch(t *testing.T) {
+	ctx := context.Background()
+
+	opts := &TxOptions{ChainID: big.NewInt(4)}
+	if err := opts.checkChainID(ctx, chainIDBackend{big.NewInt(4)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.checkChainID(ctx, chainIDBackend{big.NewInt(1)}); err == nil {
+		t.Fatal("ERROR expected destination network mismatch")
+	}
+
+	// the chain id of dialed backends is asked over their RPC client
+	client, err := SimulatedRPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := (&TxOptions{ChainID: SimulatedChainID}).checkChainID(ctx, rpcBackend{client}); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.checkChainID(ctx, rpcBackend{client}); err == nil {
+		t.Fatal("ERROR expected destination network mismatch over RPC")
+	}
+
+	_, err = VerifyExecuteWithParams(ctx, nil, nil, nil, common.Address{}, VerifyExecuteParams{
+		ChainID:         crypto.Keccak256Hash([]byte("source")),
+		ExpectedChainID: crypto.Keccak256Hash([]byte("other source")),
+	})
+	if err == nil {
+		t.Fatal("ERROR expected source chain id mismatch")
+	}
+}
+
+func Test_DeployTriggerVerifierAndConsumerFunctionDryRun(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	userAddr := crypto.PubkeyToAddress(userKey.PublicKey)
+	alloc := make(core.GenesisAlloc)
+	alloc[userAddr] = core.GenesisAccount{Balance: big.NewInt(1000000000)}
+	blockchain := backends.NewSimulatedBackend(alloc)
+
+	opts := &TxOptions{DryRun: true, GasEstimation: GasEstimate, Logger: NopLogger{}}
+	contractChan, errChan := CompileAndDeployTriggerVerifierAndConsumerFunction(ctx, blockchain, userKey, common.Address{}, opts, nil)
+
+	for nonce := uint64(0); nonce < 2; nonce++ {
+		instance, ok := <-contractChan
+		if !ok {
+			t.Fatal("ERROR dry-run deploying", <-errChan)
+		}
+		if instance.Address != crypto.CreateAddress(userAddr, nonce) {
+			t.Fatalf("ERROR unexpected dry-run address of deployment %d", nonce)
+		}
+		if instance.DeployTx == nil || instance.DeployTx.Gas() == 0 {
+			t.Fatalf("ERROR expected the estimated gas of deployment %d", nonce)
+		}
+	}
+}
+
+func Test_DeployConsumerFunctionsDryRun(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	userAddr := crypto.PubkeyToAddress(userKey.PublicKey)
+	alloc := make(core.GenesisAlloc)
+	alloc[userAddr] = core.GenesisAccount{Balance: big.NewInt(1000000000)}
+	blockchain := backends.NewSimulatedBackend(alloc)
+
+	opts := &TxOptions{DryRun: true, GasEstimation: GasEstimate, Logger: NopLogger{}}
+	contractChan, errChan := DeployConsumerFunctions(ctx, blockchain, userKey, common.Address{}, common.Address{}, 3, opts, nil)
+
+	nonce := uint64(0)
+	for instance := range contractChan {
+		if instance.Address != crypto.CreateAddress(userAddr, nonce) {
+			t.Fatalf("ERROR unexpected dry-run address of consumer function %d", nonce)
+		}
+		nonce++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatal("ERROR dry-run deploying", err)
+	}
+	if nonce != 3 {
+		t.Fatalf("ERROR expected 3 consumer functions, got %d", nonce)
+	}
+
+	contractChan, errChan = DeployConsumerFunctions(ctx, blockchain, userKey, common.Address{}, common.Address{}, 0, opts, nil)
+	if _, ok := <-contractChan; ok {
+		t.Fatal("ERROR expected no consumer functions")
+	}
+	if err := <-errChan; err == nil {
+		t.Fatal("ERROR expected a count of 0 to be rejected")
+	}
+}
+
+func Test_CompiledContract(t *testing.T) {
+	contracts := map[string]*compiler.Contract{
+		"Function.sol:Function":                         {},
+		"TriggerEventVerifier.sol:TriggerEventVerifier": {},
+	}
+	if _, err := compiledContract(contracts, "Function.sol:Function"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := compiledContract(contracts, "Function.sol:Consumer")
+	if err == nil {
+		t.Fatal("ERROR expected a missing contract to be reported")
+	}
+	if !strings.Contains(err.Error(), "Function.sol:Function, TriggerEventVerifier.sol:TriggerEventVerifier") {
+		t.Fatalf("ERROR expected the compiled contracts to be listed, got %v", err)
+	}
+}
+
+func Test_CompiledArtifacts(t *testing.T) {
+	function := &compiler.Contract{Code: "0x6060"}
+	artifacts := &CompiledArtifacts{Contracts: map[string]*compiler.Contract{
+		"/contracts/Function.sol:Function": function,
+	}}
+	if contract, err := artifacts.Contract("Function"); err != nil || contract != function {
+		t.Fatalf("ERROR expected the compiled Function contract, got %v", err)
+	}
+	if _, err := (*CompiledArtifacts)(nil).Contract("Function"); err == nil {
+		t.Fatal("ERROR expected no contract without artifacts")
+	}
+
+	// contracts of the same name in several files are picked by path
+	other := &compiler.Contract{Code: "0x6060"}
+	ambiguous := &CompiledArtifacts{Contracts: map[string]*compiler.Contract{
+		"/contracts/Function.sol:Function":      function,
+		"/contracts/test/Function.sol:Function": other,
+	}}
+	if _, err := ambiguous.Contract("Function"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("ERROR expected an ambiguous contract name, got %v", err)
+	}
+	if contract, err := ambiguous.Contract("/contracts/test/Function.sol:Function"); err != nil || contract != other {
+		t.Fatalf("ERROR expected the contract of the given path, got %v", err)
+	}
+
+	// the deployment fails up front without a compiled TriggerEventVerifier
+	userKey, _ := crypto.GenerateKey()
+	contractChan, errChan := DeployTriggerVerifierAndConsumerFunction(context.Background(), nil, userKey, common.Address{}, artifacts, nil)
+	if _, ok := <-contractChan; ok {
+		t.Fatal("ERROR expected no contract instance")
+	}
+	if err := <-errChan; err == nil || !strings.Contains(err.Error(), "TriggerEventVerifier") {
+		t.Fatalf("ERROR expected the missing contract to be reported, got %v", err)
+	}
+}
+
+func Test_TransactionContractABI(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	alloc := make(core.GenesisAlloc)
+	alloc[crypto.PubkeyToAddress(userKey.PublicKey)] = core.GenesisAccount{Balance: big.NewInt(1000000000)}
+	blockchain := backends.NewSimulatedBackend(alloc)
+
+	parsed, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"fire","inputs":[],"outputs":[]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &TxOptions{DryRun: true, Logger: NopLogger{}}
+
+	tx, err := TransactionContractABI(ctx, blockchain, userKey, parsed, common.HexToAddress("0x01"), nil, opts, "fire")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tx.Data(), parsed.Methods["fire"].Id()) {
+		t.Fatalf("ERROR unexpected transaction data %x", tx.Data())
+	}
+
+	if _, err := TransactionContractABI(ctx, blockchain, userKey, parsed, common.HexToAddress("0x01"), nil, opts, "missing"); err == nil {
+		t.Fatal("ERROR expected an unknown method to be rejected")
+	}
+}
+
+func Test_TransactionContractNilAmount(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract, err := precompiledContract(`[{"type":"function","name":"fire","inputs":[],"outputs":[]}]`, "6060")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &TxOptions{DryRun: true, Logger: NopLogger{}}
+	tx, err := TransactionContract(ctx, blockchain, userKey, contract, common.HexToAddress("0x01"), nil, opts, "fire")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Value() == nil || tx.Value().Sign() != 0 {
+		t.Fatalf("ERROR expected a zero value transaction, got %v", tx.Value())
+	}
+}
+
+func Test_CheckArgs(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"verify","inputs":[
+		{"name":"_id","type":"bytes32"},{"name":"_to","type":"address"},{"name":"_amount","type":"uint256"},
+		{"name":"_proof","type":"bytes"},{"name":"_validators","type":"address[]"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := parsed.Methods["verify"]
+
+	valid := []interface{}{common.HexToHash("0x01"), common.HexToAddress("0x02"), big.NewInt(3), []byte{4}, []common.Address{{}}}
+	if err := checkArgs(method, valid); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkArgs(method, valid[:4]); err == nil || !strings.Contains(err.Error(), "expected 5 arguments, got 4") {
+		t.Fatalf("ERROR expected an argument count error, got %v", err)
+	}
+
+	wrongID := append([]interface{}{common.HexToAddress("0x01").Bytes()}, valid[1:]...)
+	if err := checkArgs(method, wrongID); err == nil || !strings.Contains(err.Error(), "arg 0 (_id): expected bytes32 ([32]uint8), got []uint8 of len 20") {
+		t.Fatalf("ERROR expected a bytes32 type error, got %v", err)
+	}
+
+	nilAmount := []interface{}{valid[0], valid[1], (*big.Int)(nil), valid[3], valid[4]}
+	if err := checkArgs(method, nilAmount); err == nil || !strings.Contains(err.Error(), "arg 2 (_amount)") {
+		t.Fatalf("ERROR expected a nil amount error, got %v", err)
+	}
+
+	wrongValidator := []interface{}{valid[0], valid[1], valid[2], valid[3], []interface{}{common.HexToAddress("0x02"), "0x03"}}
+	if err := checkArgs(method, wrongValidator); err == nil || !strings.Contains(err.Error(), "element 1") {
+		t.Fatalf("ERROR expected an element type error, got %v", err)
+	}
+}
+
+func Test_GasPriceOracleAndCap(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"fire","inputs":[],"outputs":[]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &TxOptions{
+		DryRun: true,
+		Logger: NopLogger{},
+		GasPriceOracle: func(ctx context.Context) (*big.Int, error) {
+			return big.NewInt(7), nil
+		},
+		GasPriceCap: big.NewInt(10),
+	}
+	tx, err := TransactionContractABI(ctx, blockchain, userKey, parsed, common.HexToAddress("0x01"), nil, opts, "fire")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.GasPrice().Cmp(big.NewInt(7)) != 0 {
+		t.Fatalf("ERROR expected the oracle gas price, got %v", tx.GasPrice())
+	}
+
+	opts.GasPriceCap = big.NewInt(5)
+	if _, err := TransactionContractABI(ctx, blockchain, userKey, parsed, common.HexToAddress("0x01"), nil, opts, "fire"); !errors.Is(err, ErrGasPriceAboveCap) {
+		t.Fatalf("ERROR expected the gas price cap to be enforced, got %v", err)
+	}
+}
+
+func Test_MethodPayable(t *testing.T) {
+	contract, err := precompiledContract(`[
+		{"type": "function", "name": "legacy", "payable": true},
+		{"type": "function", "name": "current", "stateMutability": "payable"},
+		{"type": "function", "name": "free", "stateMutability": "nonpayable"}
+	]`, "6060")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for method, expected := range map[string]bool{"legacy": true, "current": true, "free": false} {
+		payable, err := methodPayable(contract, method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payable != expected {
+			t.Fatalf("ERROR expected %s payable to be %t", method, expected)
+		}
+	}
+	if _, err := methodPayable(contract, "missing"); err == nil {
+		t.Fatal("ERROR expected a missing method to be reported")
+	}
+}
+
+func Test_VerifyDeployedCode(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the init code returns the runtime code 0xfe
+	contractChan, errChan := DeployPrecompiled(ctx, blockchain, userKey, "[]", "60fe60005360016000f3", nil)
+	blockchain.Commit()
+	instance, ok := <-contractChan
+	if !ok {
+		t.Fatal("ERROR deploying contract", <-errChan)
+	}
+
+	if ok, err := VerifyDeployedCode(ctx, blockchain, instance.Address, "0xfe"); !ok || err != nil {
+		t.Fatal("ERROR expected the deployed code to match", err)
+	}
+
+	if ok, err := VerifyDeployedCode(ctx, blockchain, instance.Address, "0xff"); ok || err == nil {
+		t.Fatal("ERROR expected other code to mismatch")
+	}
+	if _, err := VerifyDeployedCode(ctx, blockchain, instance.Address, "0xfefe"); err == nil || !strings.Contains(err.Error(), "1 bytes") {
+		t.Fatalf("ERROR expected a length mismatch, got %v", err)
+	}
+	if _, err := VerifyDeployedCode(ctx, blockchain, instance.Address, ""); err == nil {
+		t.Fatal("ERROR expected no runtime bytecode to be rejected")
+	}
+	if _, err := VerifyDeployedCode(ctx, blockchain, common.HexToAddress("0x01"), "0xfe"); err == nil {
+		t.Fatal("ERROR expected an address without code to fail")
+	}
+}
+
+func Test_StripCodeMetadata(t *testing.T) {
+	swarmHash := strings.Repeat("11", 32)
+	code := "6060" + "a165627a7a72305820" + swarmHash + "0029"
+	if stripped := stripCodeMetadata(code); stripped != "6060" {
+		t.Fatalf("ERROR unexpected stripped code %s", stripped)
+	}
+	if stripped := stripCodeMetadata("60606060"); stripped != "60606060" {
+		t.Fatalf("ERROR expected code without metadata to be kept, got %s", stripped)
+	}
+}
+
+func Test_CheckConsensus(t *testing.T) {
+	clique, err := precompiledContract(`[{"type":"function","name":"SubmitBlock","inputs":[
+		{"name":"_id","type":"bytes32"},{"name":"_rlpBlockHeader","type":"bytes"},{"name":"_rlpSignedBlockHeader","type":"bytes"}]}]`, "6060")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as many parameters as the clique SubmitBlock, of other types
+	other, err := precompiledContract(`[{"type":"function","name":"SubmitBlock","inputs":[
+		{"name":"_id","type":"bytes32"},{"name":"_rlpBlockHeader","type":"bytes"},{"name":"_number","type":"uint256"}]}]`, "6060")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkConsensus(clique, utils.Clique); err != nil {
+		t.Fatal(err)
+	}
+
+	var mismatch *ConsensusMismatchError
+	if err := checkConsensus(clique, utils.Ethash); !errors.As(err, &mismatch) || mismatch.SubmitBlock != "SubmitBlock(bytes32,bytes,bytes)" {
+		t.Fatalf("ERROR expected a consensus mismatch, got %v", err)
+	}
+	if err := checkConsensus(other, utils.Clique); !errors.As(err, &mismatch) || mismatch.Source != utils.Clique {
+		t.Fatalf("ERROR expected a consensus mismatch, got %v", err)
+	}
+}
+
+func Test_SubmitBlockCheckSubmittedIBFT(t *testing.T) {
+	ctx := context.Background()
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the committed seals are not part of the hash IBFT validation stores
+	istanbulExtra, err := rlp.EncodeToBytes(&utils.IstanbulExtra{
+		Validators:    []common.Address{crypto.PubkeyToAddress(userKey.PublicKey)},
+		Seal:          []byte{},
+		CommittedSeal: [][]byte{bytes.Repeat([]byte{0x01}, 65)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1), Time: big.NewInt(1), Extra: append(make([]byte, utils.IstanbulVanityLength), istanbulExtra...)}
+	blockHash, err := utils.IBFTBlockHash(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockHash == header.Hash() {
+		t.Fatal("ERROR expected the IBFT block hash to differ from the header hash")
+	}
+
+	// m_blockhashes(chainId, hash) returning hash == blockHash
+	validation := append([]byte{0x60, 0x24, 0x35, 0x7f}, blockHash.Bytes()...)
+	validation = append(validation, 0x14, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)
+	validationABI := `[{"type":"function","name":"SubmitBlock","inputs":[{"name":"_id","type":"bytes32"},{"name":"_rlpUnsignedBlockHeader","type":"bytes"},{"name":"_rlpSignedBlockHeader","type":"bytes"},{"name":"_commitedSeals","type":"bytes"}]}]`
+	contractChan, errChan := DeployPrecompiled(ctx, blockchain, userKey, validationABI, hex.EncodeToString(deployCode(validation)), nil)
+	blockchain.Commit()
+	instance, ok := <-contractChan
+	if !ok {
+		t.Fatal("ERROR deploying validation stub: ", <-errChan)
+	}
+
+	_, err = SubmitBlock(ctx, blockchain, userKey, instance.Contract, instance.Address, common.Hash{}, header, utils.IBFT, &SubmitBlockOptions{CheckSubmitted: true})
+	if err != ErrBlockAlreadySubmitted {
+		t.Fatalf("ERROR expected ErrBlockAlreadySubmitted for the stored IBFT block, got %v", err)
+	}
+}
+
+func Test_RegisterValidators(t *testing.T) {
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validators := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+	extra := make([]byte, utils.CliqueVanityLength)
+	for _, validator := range validators {
+		extra = append(extra, validator.Bytes()...)
+	}
+	genesis := &types.Header{Number: big.NewInt(0), Difficulty: big.NewInt(1), Extra: append(extra, make([]byte, utils.CliqueSealLength)...)}
+
+	validation, err := precompiledContract(`[{"constant":false,"inputs":[{"name":"_id","type":"bytes32"},{"name":"_validators","type":"address[]"},{"name":"_genesisHash","type":"bytes32"}],"name":"RegisterChain","outputs":[],"payable":false,"stateMutability":"nonpayable","type":"function"}]`, "6060")
+	if err != nil {
+		t.Fatal(err)
+	}
+	validationAddr := common.HexToAddress("0x03")
+	chainID := common.HexToHash("0x04")
+	tx, err := RegisterValidators(context.Background(), blockchain, userKey, validation, validationAddr, chainID, genesis, utils.Clique, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parseContractABI(validation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := parsed.Pack("RegisterChain", chainID, validators, genesis.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *tx.To() != validationAddr || !bytes.Equal(tx.Data(), expected) {
+		t.Fatal("ERROR RegisterChain not called with the genesis validators")
+	}
+
+	if _, err := RegisterValidators(context.Background(), blockchain, userKey, validation, validationAddr, chainID, genesis, utils.Ethash, nil, nil); err == nil {
+		t.Fatal("ERROR expected an error parsing validators from an ethash genesis")
+	}
+
+	genesis.Extra = make([]byte, utils.CliqueVanityLength+utils.CliqueSealLength)
+	if _, err := RegisterValidators(context.Background(), blockchain, userKey, validation, validationAddr, chainID, genesis, utils.Clique, nil, nil); err == nil {
+		t.Fatal("ERROR expected an error registering no validators")
+	}
+}
+
+func Test_SetLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	SetLogger(logger)
+	defer SetLogger(nil)
+
+	(*TxOptions)(nil).logger().Info("package logger")
+	(&TxOptions{Logger: NopLogger{}}).logger().Info("options logger")
+	if len(logger.infos) != 1 || logger.infos[0] != "package logger" {
+		t.Fatalf("ERROR unexpected logs %v", logger.infos)
+	}
+
+	SetLogger(nil)
+	if _, ok := (*TxOptions)(nil).logger().(StdLogger); !ok {
+		t.Fatal("ERROR expected the default logger to be restored")
+	}
+}
+
+// ionCaller answers the block getters of Ion.sol from maps
+type ionCaller struct {
+	chains map[common.Hash]bool
+	blocks map[common.Hash]StoredBlock
+}
+
+func (c ionCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{0x60}, nil
+}
+
+func (c ionCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	parsed, err := abi.JSON(strings.NewReader(ionBlocksABI))
+	if err != nil {
+		return nil, err
+	}
+	key := common.BytesToHash(call.Data[4:36])
+	block, stored := c.blocks[key]
+	switch {
+	case bytes.Equal(call.Data[:4], parsed.Methods["m_chains"].Id()):
+		return parsed.Methods["m_chains"].Outputs.Pack(c.chains[key])
+	case bytes.Equal(call.Data[:4], parsed.Methods["m_blockhashes"].Id()):
+		return parsed.Methods["m_blockhashes"].Outputs.Pack(stored)
+	default:
+		return parsed.Methods["m_blockheaders"].Outputs.Pack([32]byte(block.TxRootHash), [32]byte(block.ReceiptRootHash))
+	}
+}
+
+func Test_GetStoredBlock(t *testing.T) {
+	ctx := context.Background()
+
+	chainID := common.HexToHash("0x01")
+	expected := StoredBlock{
+		ChainID:         chainID,
+		BlockHash:       common.HexToHash("0x02"),
+		TxRootHash:      common.HexToHash("0x03"),
+		ReceiptRootHash: common.HexToHash("0x04"),
+	}
+	caller := ionCaller{
+		chains: map[common.Hash]bool{chainID: true},
+		blocks: map[common.Hash]StoredBlock{expected.BlockHash: expected},
+	}
+
+	block, err := GetStoredBlock(ctx, caller, common.Address{}, chainID, expected.BlockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *block != expected {
+		t.Fatalf("ERROR unexpected stored block %+v", block)
+	}
+
+	if _, err := GetStoredBlock(ctx, caller, common.Address{}, chainID, common.HexToHash("0x05")); !errors.Is(err, ErrBlockNotStored) {
+		t.Fatalf("ERROR expected a missing block to be reported, got %v", err)
+	}
+	if _, err := GetStoredBlock(ctx, caller, common.Address{}, common.HexToHash("0x06"), expected.BlockHash); err == nil {
+		t.Fatal("ERROR expected an unregistered chain to be reported")
+	}
+}
+
+// ionBackend is a backend whose calls are answered by an ionCaller
+type ionBackend struct {
+	bind.ContractBackend
+	ion ionCaller
+}
+
+func (b ionBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return b.ion.CodeAt(ctx, contract, blockNumber)
+}
+
+func (b ionBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return b.ion.CallContract(ctx, call, blockNumber)
+}
+
+func Test_VerifyExecuteBlockNotRegistered(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract, err := precompiledContract(`[{"type":"function","name":"verifyAndExecute","inputs":[
+		{"name":"_chainId","type":"bytes32"},{"name":"_blockHash","type":"bytes32"},{"name":"_contractEmittedAddress","type":"address"},
+		{"name":"_path","type":"bytes"},{"name":"_tx","type":"bytes"},{"name":"_txNodes","type":"bytes"},
+		{"name":"_receipt","type":"bytes"},{"name":"_receiptNodes","type":"bytes"},{"name":"_expectedAddress","type":"address"}]}]`, "6060")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chainID := common.HexToHash("0x01")
+	storedHash := common.HexToHash("0x02")
+	backend := ionBackend{blockchain, ionCaller{
+		chains: map[common.Hash]bool{chainID: true},
+		blocks: map[common.Hash]StoredBlock{storedHash: {ChainID: chainID, BlockHash: storedHash}},
+	}}
+	ionAddr := common.HexToAddress("0x03")
+	params := VerifyExecuteParams{
+		ChainID:   chainID,
+		BlockHash: common.HexToHash("0x04"),
+		IonAddr:   &ionAddr,
+		Opts:      &TxOptions{DryRun: true, Logger: NopLogger{}, GasLimit: DefaultGasLimit},
+	}
+
+	if _, err := VerifyExecuteWithParams(ctx, backend, userKey, contract, common.HexToAddress("0x05"), params); !errors.Is(err, ErrBlockNotRegistered) {
+		t.Fatalf("ERROR expected the unregistered block to be reported, got %v", err)
+	}
+
+	params.BlockHash = storedHash
+	if _, err := VerifyExecuteWithParams(ctx, backend, userKey, contract, common.HexToAddress("0x05"), params); err != nil {
+		t.Fatal(err)
+	}
+}
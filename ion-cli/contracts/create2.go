@@ -0,0 +1,159 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// DefaultCreate2Factory is the address of the deterministic deployment proxy,
+// deployed at the same address on most chains, which DeployCreate2 uses
+var DefaultCreate2Factory = common.HexToAddress("0x4e59b44847b379578588920cA78FbF26c0B4956C")
+
+// DeterministicAddress returns the address CREATE2 deploys initCode at from
+// factory with salt, keccak256(0xff ++ factory ++ salt ++ keccak256(initCode))
+func DeterministicAddress(factory common.Address, salt [32]byte, initCode []byte) common.Address {
+	hash := crypto.Keccak256([]byte{0xff}, factory.Bytes(), salt[:], crypto.Keccak256(initCode))
+	return common.BytesToAddress(hash[12:])
+}
+
+// Create2Address returns the address CREATE2 deploys initCode at from deployer
+// with salt, the same as DeterministicAddress
+func Create2Address(deployer common.Address, salt [32]byte, initCode []byte) common.Address {
+	return DeterministicAddress(deployer, salt, initCode)
+}
+
+// DeployDeterministic deploys the contract with hex bytecode bin and JSON ABI
+// abi through the CREATE2 factory at factory, so it gets the same address on
+// every chain the factory is at. The factory is called with the salt followed
+// by the init code, like the widely deployed deterministic deployment proxy.
+// It returns the predicted address and the transaction calling the factory,
+// check the deployment with WaitDeterministic.
+func DeployDeterministic(
+	ctx context.Context,
+	client bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	factory common.Address,
+	salt [32]byte,
+	bin string,
+	abi string,
+	opts *TxOptions,
+	constructorArgs ...interface{},
+) (common.Address, *types.Transaction, error) {
+	initCode, err := generateContractPayload(strings.TrimPrefix(bin, "0x"), abi, constructorArgs...)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	addr := DeterministicAddress(factory, salt, initCode)
+
+	account, err := opts.account(userKey)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	payload := append(append([]byte{}, salt[:]...), initCode...)
+	signedTx, err := signAndSend(ctx, client, account, &factory, nil, opts, payload)
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("failed to send deterministic deployment transaction: %w", err)
+	}
+	return addr, signedTx, nil
+}
+
+// WaitDeterministic waits for the deterministic deployment tx to be mined and
+// checks the contract was created at the predicted address
+func WaitDeterministic(ctx context.Context, backend bind.DeployBackend, tx *types.Transaction, predicted common.Address) error {
+	receipt, err := bind.WaitMined(ctx, backend, tx)
+	if err != nil {
+		return fmt.Errorf("failed waiting for deterministic deployment: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("deterministic deployment transaction %s failed", tx.Hash().Hex())
+	}
+
+	code, err := backend.CodeAt(ctx, predicted, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get code at %s: %v", predicted.Hex(), err)
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("no contract at predicted address %s, the factory deployed elsewhere", predicted.Hex())
+	}
+	return nil
+}
+
+// DeployCreate2 deploys the contract with hex bytecode bin and JSON ABI abi
+// through DefaultCreate2Factory with salt, see DeployDeterministic. The
+// address can be predicted beforehand with DeterministicAddress.
+// The instance is sent on the first channel once deployed at the predicted
+// address. Any failure is sent on the error channel and both channels are
+// closed.
+func DeployCreate2(
+	ctx context.Context,
+	client bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	bin string,
+	abi string,
+	salt [32]byte,
+	opts *TxOptions,
+	constructorArgs ...interface{},
+) (<-chan ContractInstance, <-chan error) {
+	resChan := make(chan ContractInstance)
+	errChan := make(chan error, 1)
+
+	ctx, cancel := opts.withTimeout(ctx)
+	fail := func(err error) (<-chan ContractInstance, <-chan error) {
+		cancel()
+		errChan <- err
+		close(errChan)
+		close(resChan)
+		return resChan, errChan
+	}
+	deployBackend, ok := client.(bind.DeployBackend)
+	if !ok {
+		return fail(fmt.Errorf("client can't wait for deployments to be mined"))
+	}
+
+	contract, err := precompiledContract(abi, bin)
+	if err != nil {
+		return fail(err)
+	}
+
+	code, err := client.CodeAt(ctx, DefaultCreate2Factory, nil)
+	if err != nil {
+		return fail(stageError(ctx, StageSubmit, fmt.Errorf("failed to get code at %s: %v", DefaultCreate2Factory.Hex(), err)))
+	}
+	if len(code) == 0 {
+		return fail(fmt.Errorf("no CREATE2 factory at %s on this chain", DefaultCreate2Factory.Hex()))
+	}
+
+	addr, signedTx, err := DeployDeterministic(ctx, client, userKey, DefaultCreate2Factory, salt, bin, abi, opts, constructorArgs...)
+	if err != nil {
+		return fail(stageError(ctx, StageSubmit, err))
+	}
+
+	go func() {
+		defer cancel()
+		defer close(errChan)
+		defer close(resChan)
+
+		var receipt *types.Receipt
+		if !opts.dryRun() {
+			if err := WaitDeterministic(ctx, deployBackend, signedTx, addr); err != nil {
+				errChan <- stageError(ctx, StageWait, err)
+				return
+			}
+			if receipt, err = deployBackend.TransactionReceipt(ctx, signedTx.Hash()); err != nil {
+				errChan <- stageError(ctx, StageWait, fmt.Errorf("failed to get deterministic deployment receipt: %v", err))
+				return
+			}
+		}
+		sendInstance(ctx, resChan, errChan, deployedInstance(client, contract, addr, signedTx, receipt))
+	}()
+
+	return resChan, errChan
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Test_DeterministicAddress checks the examples of EIP-1014
+func Test_DeterministicAddress(t *testing.T) {
+	tests := []struct {
+		factory  string
+		salt     string
+		initCode []byte
+		expected string
+	}{
+		{
+			"0x0000000000000000000000000000000000000000",
+			"0x0000000000000000000000000000000000000000000000000000000000000000",
+			common.FromHex("0x00"),
+			"0x4D1A2e2bB4F88F0250f26Ffff098B0b30B26BF38",
+		},
+		{
+			"0xdeadbeef00000000000000000000000000000000",
+			"0x000000000000000000000000feed000000000000000000000000000000000000",
+			common.FromHex("0x00"),
+			"0xD04116cDd17beBE565EB2422F2497E06cC1C9833",
+		},
+		{
+			"0x00000000000000000000000000000000deadbeef",
+			"0x00000000000000000000000000000000000000000000000000000000cafebabe",
+			common.FromHex("0xdeadbeef"),
+			"0x60f3f640a8508fC6a86d45DF051962668E1e8AC7",
+		},
+	}
+
+	for _, test := range tests {
+		var salt [32]byte
+		copy(salt[:], common.FromHex(test.salt))
+		addr := DeterministicAddress(common.HexToAddress(test.factory), salt, test.initCode)
+		if addr != common.HexToAddress(test.expected) {
+			t.Fatalf("ERROR expected %s, got %s", test.expected, addr.Hex())
+		}
+		if addr := Create2Address(common.HexToAddress(test.factory), salt, test.initCode); addr != common.HexToAddress(test.expected) {
+			t.Fatalf("ERROR expected Create2Address %s, got %s", test.expected, addr.Hex())
+		}
+	}
+}
+
+func Test_DeployCreate2WithoutFactory(t *testing.T) {
+	userKey, _ := crypto.GenerateKey()
+	alloc := make(core.GenesisAlloc)
+	alloc[crypto.PubkeyToAddress(userKey.PublicKey)] = core.GenesisAccount{Balance: big.NewInt(1000000000)}
+	blockchain := backends.NewSimulatedBackend(alloc)
+
+	contractChan, errChan := DeployCreate2(context.Background(), blockchain, userKey, "60fe60005360016000f3", "[]", [32]byte{}, nil)
+	if _, ok := <-contractChan; ok {
+		t.Fatal("ERROR expected no deployment without a factory")
+	}
+	if err := <-errChan; err == nil || !strings.Contains(err.Error(), "no CREATE2 factory") {
+		t.Fatalf("ERROR expected the missing factory to be reported, got %v", err)
+	}
+}
+
+// create2Factory is the runtime of a minimal CREATE2 factory, calling
+// create2(callvalue, 0, calldatasize - 32, salt) with the salt followed by the
+// init code as calldata, like the deterministic deployment proxy
+var create2Factory = []byte{
+	0x60, 0x20, 0x36, 0x03, // calldatasize - 32
+	0x80, 0x60, 0x20, 0x60, 0x00, 0x37, // copy the init code to memory
+	0x60, 0x00, 0x35, 0x90, // load the salt
+	0x60, 0x00, 0x34, 0xf5, // create2
+	0x00,
+}
+
+func Test_DeployDeterministic(t *testing.T) {
+	// the simulated chain of the pinned go-ethereum predates Constantinople
+	if params.AllEthashProtocolChanges.ConstantinopleBlock == nil {
+		t.Skip("simulated backend has no CREATE2")
+	}
+	ctx := context.Background()
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	factoryChan, factoryErrChan := DeployPrecompiled(ctx, blockchain, userKey, "[]", hex.EncodeToString(deployCode(create2Factory)), nil)
+	blockchain.Commit()
+	factory, ok := <-factoryChan
+	if !ok {
+		t.Fatal("ERROR deploying the CREATE2 factory", <-factoryErrChan)
+	}
+
+	salt := [32]byte{0x01}
+	bin := hex.EncodeToString(deployCode([]byte{0x00}))
+	addr, tx, err := DeployDeterministic(ctx, blockchain, userKey, factory.Address, salt, bin, "[]", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != DeterministicAddress(factory.Address, salt, deployCode([]byte{0x00})) {
+		t.Fatalf("ERROR unexpected predicted address %s", addr.Hex())
+	}
+	blockchain.Commit()
+
+	if err := WaitDeterministic(ctx, blockchain, tx, addr); err != nil {
+		t.Fatal(err)
+	}
+	code, err := blockchain.CodeAt(ctx, addr, nil)
+	if err != nil || len(code) == 0 {
+		t.Fatalf("ERROR no code at the predicted address %s", addr.Hex())
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// CompileContractFile compiles the Solidity file at path and returns the hex
+// bytecode and JSON ABI of the contract name defined in it, ready for
+// DeployContract
+func CompileContractFile(path string, name string, compileOpts *CompileOptions) (bin string, abi string, err error) {
+	contracts, err := Compile(compileOpts, path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to compile %s: %v", path, err)
+	}
+	contract, ok := contracts[path+":"+name]
+	if !ok {
+		return "", "", fmt.Errorf("contract %s not found in %s", name, path)
+	}
+	return ContractBytecodeAndABI(contract)
+}
+
+// ContractArtifact is a compiled contract with the provenance of its build,
+// for logging or storing alongside the deployment
+type ContractArtifact struct {
+	// Name of the contract, empty when unknown
+	Name string
+	// Address the contract is deployed at, zero when not deployed
+	Address common.Address
+	// Bin is the hex deployment bytecode, without 0x prefix
+	Bin string
+	// ABI is the JSON ABI
+	ABI string
+	// CompilerVersion of solc
+	CompilerVersion string
+	// CompilerOptions solc was run with, e.g. the optimizer settings
+	CompilerOptions string
+	// Metadata JSON of the contract, empty for solc before 0.4.7
+	Metadata string
+	// Warnings solc reported compiling the source file
+	Warnings []string
+}
+
+// CompileContractArtifact compiles the contract name defined in the Solidity
+// file at path like CompileContractFile, also returning its compiler settings,
+// metadata and the warnings of the compilation
+func CompileContractArtifact(path string, name string, compileOpts *CompileOptions) (*ContractArtifact, error) {
+	contracts, warnings, err := CompileWithWarnings(compileOpts, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile %s: %v", path, err)
+	}
+	contract, ok := contracts[path+":"+name]
+	if !ok {
+		return nil, fmt.Errorf("contract %s not found in %s", name, path)
+	}
+	bin, abi, err := ContractBytecodeAndABI(contract)
+	if err != nil {
+		return nil, err
+	}
+	return &ContractArtifact{
+		Name:            name,
+		Bin:             bin,
+		ABI:             abi,
+		CompilerVersion: contract.Info.CompilerVersion,
+		CompilerOptions: contract.Info.CompilerOptions,
+		Metadata:        contract.Info.Metadata,
+		Warnings:        warnings,
+	}, nil
+}
+
+// DeployContract sends the deployment transaction of the contract with hex
+// bytecode bin and JSON ABI abi, packing the constructor arguments against it.
+// Wait for the deployment with WaitDeployedWithReceipt.
+func DeployContract(
+	ctx context.Context,
+	client bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	bin string,
+	abi string,
+	opts *TxOptions,
+	constructorArgs ...interface{},
+) (*types.Transaction, error) {
+	return compileAndDeployContract(ctx, client, userKey, strings.TrimPrefix(bin, "0x"), abi, nil, opts, constructorArgs...)
+}
+
+// DeployContractWithSigner sends the deployment transaction of the contract
+// like DeployContract, signed by account
+func DeployContractWithSigner(
+	ctx context.Context,
+	client bind.ContractBackend,
+	account AccountSigner,
+	bin string,
+	abi string,
+	opts *TxOptions,
+	constructorArgs ...interface{},
+) (*types.Transaction, error) {
+	return deployContract(ctx, client, account, strings.TrimPrefix(bin, "0x"), abi, nil, opts, constructorArgs...)
+}
+
+// DeployPrecompiled deploys a contract from its ABI and hex bytecode, e.g. a
+// verified build artifact, without running solc.
+// The instance is sent on the first channel once deployed. Any failure is sent
+// on the error channel and both channels are closed.
+func DeployPrecompiled(
+	ctx context.Context,
+	client bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	abiStr string,
+	binStr string,
+	opts *TxOptions,
+	constructorArgs ...interface{},
+) (<-chan ContractInstance, <-chan error) {
+	resChan := make(chan ContractInstance)
+	errChan := make(chan error, 1)
+
+	ctx, cancel := opts.withTimeout(ctx)
+	fail := func(err error) (<-chan ContractInstance, <-chan error) {
+		cancel()
+		errChan <- err
+		close(errChan)
+		close(resChan)
+		return resChan, errChan
+	}
+	deployBackend, ok := client.(bind.DeployBackend)
+	if !ok {
+		return fail(fmt.Errorf("client can't wait for deployments to be mined"))
+	}
+
+	contract, err := precompiledContract(abiStr, binStr)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fail(stageError(ctx, StageCompile, errCancelled(err)))
+	}
+	signedTx, err := compileAndDeployContract(
+		ctx,
+		client,
+		userKey,
+		strings.TrimPrefix(binStr, "0x"),
+		abiStr,
+		nil,
+		opts,
+		constructorArgs...,
+	)
+	if err != nil {
+		return fail(stageError(ctx, StageSubmit, fmt.Errorf("failed to deploy contract: %v", err)))
+	}
+
+	go func() {
+		defer cancel()
+		defer close(errChan)
+		defer close(resChan)
+
+		addr, receipt, err := waitDeployed(ctx, deployBackend, signedTx, "contract", opts)
+		if err != nil {
+			errChan <- stageError(ctx, StageWait, err)
+			return
+		}
+		sendInstance(ctx, resChan, errChan, deployedInstance(client, contract, addr, signedTx, receipt))
+	}()
+
+	return resChan, errChan
+}
+
+// libraryAddressPrefix starts the runtime code of a library, a push of its own
+// address, zero until it is deployed
+var libraryAddressPrefix = "73" + strings.Repeat("0", 2*common.AddressLength)
+
+// VerifyDeployedCode checks the code at addr is runtimeCode, the hex runtime
+// bytecode of the contract expected at addr, e.g. the RuntimeCode of a
+// StandardContract, as the compiler contracts of the pinned go-ethereum don't
+// hold it. The trailing metadata solc appends is not compared, nor are the
+// addresses of linked libraries and the address a library pushes of itself.
+// A mismatch is reported as an error with the lengths of both codes.
+func VerifyDeployedCode(ctx context.Context, client bind.ContractCaller, addr common.Address, runtimeCode string) (bool, error) {
+	compiled := strings.TrimPrefix(runtimeCode, "0x")
+	if compiled == "" {
+		return false, fmt.Errorf("no runtime bytecode to compare")
+	}
+	code, err := client.CodeAt(ctx, addr, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to get code at %s: %v", addr.Hex(), err)
+	}
+	if len(code) == 0 {
+		return false, fmt.Errorf("no code at %s", addr.Hex())
+	}
+
+	deployed := stripCodeMetadata(hex.EncodeToString(code))
+	compiled = stripCodeMetadata(strings.ToLower(compiled))
+	if len(deployed) != len(compiled) {
+		return false, fmt.Errorf("code at %s is %d bytes, the compiled runtime bytecode %d bytes", addr.Hex(), len(deployed)/2, len(compiled)/2)
+	}
+
+	start := 0
+	if strings.HasPrefix(compiled, libraryAddressPrefix) {
+		start = len(libraryAddressPrefix)
+	}
+	for i := start; i < len(compiled); i++ {
+		// link placeholders are underscores in the hex bytecode
+		if compiled[i] != '_' && compiled[i] != deployed[i] {
+			return false, fmt.Errorf("code at %s differs from the compiled runtime bytecode at byte %d (%d bytes deployed, %d bytes compiled)", addr.Hex(), i/2, len(deployed)/2, len(compiled)/2)
+		}
+	}
+	return true, nil
+}
+
+// stripCodeMetadata removes the CBOR encoded metadata solc appends to the hex
+// bytecode code, ended by its length in two bytes
+func stripCodeMetadata(code string) string {
+	if len(code) < 4 {
+		return code
+	}
+	length, err := strconv.ParseUint(code[len(code)-4:], 16, 16)
+	if err != nil || length == 0 {
+		return code
+	}
+	end := len(code) - 4 - int(length)*2
+	// a CBOR map of up to 23 entries starts with 0xa0 to 0xb7
+	if end < 0 || (code[end] != 'a' && code[end] != 'b') {
+		return code
+	}
+	return code[:end]
+}
+
+// precompiledContract wraps an ABI and bytecode into a compiler.Contract, the
+// form the rest of the package works with
+func precompiledContract(abiStr string, binStr string) (*compiler.Contract, error) {
+	var abiDefinition interface{}
+	if err := json.Unmarshal([]byte(abiStr), &abiDefinition); err != nil {
+		return nil, fmt.Errorf("failed to read contract ABI: %v", err)
+	}
+	if strings.TrimPrefix(binStr, "0x") == "" {
+		return nil, fmt.Errorf("empty contract bytecode")
+	}
+
+	return &compiler.Contract{
+		Code: "0x" + strings.TrimPrefix(binStr, "0x"),
+		Info: compiler.ContractInfo{
+			AbiDefinition: abiDefinition,
+		},
+	}, nil
+}
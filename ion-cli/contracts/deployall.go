@@ -0,0 +1,225 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+)
+
+// DeploySpec describes one contract of a DeployAll deployment
+type DeploySpec struct {
+	// Name of the deployment, unique among the specs
+	Name string
+	// Contract compiled
+	Contract *compiler.Contract
+	// DependsOn lists the names of the specs which must be deployed first
+	DependsOn []string
+	// Libraries maps the fully qualified name of each library linked into the
+	// bytecode to the name of the spec deploying it, which is an implicit
+	// dependency
+	Libraries map[string]string
+	// Args returns the constructor arguments given the deployed dependencies by
+	// name. The constructor takes no arguments when nil.
+	Args func(deps map[string]ContractInstance) ([]interface{}, error)
+	// Opts of the deployment transaction, defaults when nil. The nonces of all
+	// the deployments are allocated by a shared NonceManager.
+	Opts *TxOptions
+}
+
+// DeployResult is the outcome of deploying one DeploySpec
+type DeployResult struct {
+	// Name of the spec
+	Name string
+	// Instance deployed, set when Err is nil
+	Instance ContractInstance
+	// Err of the deployment, or of one of its dependencies
+	Err error
+}
+
+// dependencies returns the names of the specs spec depends on
+func (spec DeploySpec) dependencies() []string {
+	deps := append([]string{}, spec.DependsOn...)
+	for _, lib := range spec.Libraries {
+		deps = append(deps, lib)
+	}
+	return deps
+}
+
+// DeployAll deploys the contracts of specs, each as soon as the contracts it
+// depends on are deployed, so independent contracts are deployed concurrently.
+// The transactions are sent one at a time, so the nonce released by a failed
+// send is reused by the next one instead of leaving a gap, and are then mined
+// concurrently.
+// A result is sent for every spec as it lands, and the channel is closed once
+// all are. Specs with unknown or cyclic dependencies are rejected before
+// anything is sent.
+func DeployAll(
+	ctx context.Context,
+	client bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	specs []DeploySpec,
+) (<-chan DeployResult, error) {
+	if err := checkDeploySpecs(specs); err != nil {
+		return nil, err
+	}
+	deployBackend, ok := client.(bind.DeployBackend)
+	if !ok {
+		return nil, fmt.Errorf("client can't wait for deployments to be mined")
+	}
+
+	// one nonce sequence for the key whatever the deployment order
+	nonces := NewNonceManager()
+	var sendMu sync.Mutex
+	done := make(map[string]chan struct{})
+	results := make(map[string]*DeployResult)
+	for _, spec := range specs {
+		done[spec.Name] = make(chan struct{})
+		results[spec.Name] = &DeployResult{Name: spec.Name}
+	}
+
+	resChan := make(chan DeployResult, len(specs))
+	finished := make(chan struct{}, len(specs))
+	for _, spec := range specs {
+		go func(spec DeploySpec) {
+			result := results[spec.Name]
+			defer func() {
+				close(done[spec.Name])
+				resChan <- *result
+				finished <- struct{}{}
+			}()
+
+			deps := make(map[string]ContractInstance)
+			for _, dep := range spec.dependencies() {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+					result.Err = errCancelled(ctx.Err())
+					return
+				}
+				if err := results[dep].Err; err != nil {
+					result.Err = fmt.Errorf("dependency %s failed: %v", dep, err)
+					return
+				}
+				deps[dep] = results[dep].Instance
+			}
+
+			opts := TxOptions{}
+			if spec.Opts != nil {
+				opts = *spec.Opts
+			}
+			if opts.Nonces == nil {
+				opts.Nonces = nonces
+			}
+			result.Instance, result.Err = deploySpec(ctx, client, deployBackend, &sendMu, userKey, spec, deps, &opts)
+		}(spec)
+	}
+
+	go func() {
+		for range specs {
+			<-finished
+		}
+		close(resChan)
+	}()
+
+	return resChan, nil
+}
+
+// deploySpec links, deploys and waits for the contract of spec, holding
+// sendMu while the deployment is sent
+func deploySpec(
+	ctx context.Context,
+	client bind.ContractBackend,
+	deployBackend bind.DeployBackend,
+	sendMu *sync.Mutex,
+	userKey *ecdsa.PrivateKey,
+	spec DeploySpec,
+	deps map[string]ContractInstance,
+	opts *TxOptions,
+) (ContractInstance, error) {
+	binStr, abiStr, err := ContractBytecodeAndABI(spec.Contract)
+	if err != nil {
+		return ContractInstance{}, fmt.Errorf("%s: %v", spec.Name, err)
+	}
+
+	libs := make(map[string]common.Address)
+	for lib, dep := range spec.Libraries {
+		libs[lib] = deps[dep].Address
+	}
+	if binStr, err = LinkLibraries(binStr, libs); err != nil {
+		return ContractInstance{}, fmt.Errorf("failed to link %s: %v", spec.Name, err)
+	}
+
+	var args []interface{}
+	if spec.Args != nil {
+		if args, err = spec.Args(deps); err != nil {
+			return ContractInstance{}, fmt.Errorf("failed to get %s constructor arguments: %v", spec.Name, err)
+		}
+	}
+
+	sendMu.Lock()
+	if err := ctx.Err(); err != nil {
+		sendMu.Unlock()
+		return ContractInstance{}, errCancelled(err)
+	}
+	signedTx, err := compileAndDeployContract(ctx, client, userKey, binStr, abiStr, nil, opts, args...)
+	sendMu.Unlock()
+	if err != nil {
+		return ContractInstance{}, fmt.Errorf("failed to deploy %s: %v", spec.Name, err)
+	}
+
+	addr, receipt, err := waitDeployed(ctx, deployBackend, signedTx, spec.Name, opts)
+	if err != nil {
+		return ContractInstance{}, err
+	}
+	return deployedInstance(client, spec.Contract, addr, signedTx, receipt), nil
+}
+
+// checkDeploySpecs rejects duplicate names, unknown dependencies and cycles
+func checkDeploySpecs(specs []DeploySpec) error {
+	byName := make(map[string]DeploySpec)
+	for _, spec := range specs {
+		if _, ok := byName[spec.Name]; ok {
+			return fmt.Errorf("duplicate deployment %s", spec.Name)
+		}
+		byName[spec.Name] = spec
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("deployment %s depends on itself", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range byName[name].dependencies() {
+			if _, ok := byName[dep]; !ok {
+				return fmt.Errorf("deployment %s depends on unknown deployment %s", name, dep)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	for _, spec := range specs {
+		if err := visit(spec.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func Test_CheckDeploySpecs(t *testing.T) {
+	valid := []DeploySpec{
+		{Name: "Lib"},
+		{Name: "Main", Libraries: map[string]string{"Lib.sol:Lib": "Lib"}},
+		{Name: "Consumer", DependsOn: []string{"Main", "Lib"}},
+	}
+	if err := checkDeploySpecs(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := [][]DeploySpec{
+		{{Name: "A"}, {Name: "A"}},
+		{{Name: "A", DependsOn: []string{"B"}}},
+		{{Name: "A", DependsOn: []string{"B"}}, {Name: "B", DependsOn: []string{"A"}}},
+	}
+	for _, specs := range invalid {
+		if err := checkDeploySpecs(specs); err == nil {
+			t.Fatalf("ERROR expected specs %+v to be rejected", specs)
+		}
+	}
+}
+
+func Test_DeployAll(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	alloc := make(core.GenesisAlloc)
+	alloc[crypto.PubkeyToAddress(userKey.PublicKey)] = core.GenesisAccount{Balance: big.NewInt(1000000000)}
+	blockchain := backends.NewSimulatedBackend(alloc)
+
+	binStr, abiStr := compileTrigger(t)
+	triggerContract, err := precompiledContract(abiStr, binStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resChan, err := DeployAll(ctx, blockchain, userKey, []DeploySpec{
+		{Name: "First", Contract: triggerContract},
+		{Name: "Second", Contract: triggerContract},
+		{Name: "Third", Contract: triggerContract, DependsOn: []string{"First", "Second"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mine the deployments as they are sent
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				blockchain.Commit()
+			}
+		}
+	}()
+
+	deployed := make(map[string]ContractInstance)
+	for result := range resChan {
+		if result.Err != nil {
+			t.Fatalf("ERROR deploying %s: %v", result.Name, result.Err)
+		}
+		deployed[result.Name] = result.Instance
+	}
+	if len(deployed) != 3 {
+		t.Fatalf("ERROR expected 3 deployments, got %d", len(deployed))
+	}
+	for name, instance := range deployed {
+		code, err := blockchain.CodeAt(ctx, instance.Address, nil)
+		if err != nil || len(code) == 0 {
+			t.Fatalf("ERROR no code deployed for %s", name)
+		}
+	}
+}
+
+func Test_DeployAllFailedSend(t *testing.T) {
+	ctx := context.Background()
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract, err := precompiledContract("[]", hex.EncodeToString(deployCode([]byte{0x00})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first send is abandoned, the others must not be left behind a gap
+	backend := &failingSendBackend{SimulatedBackend: blockchain, failures: 1, err: errors.New("insufficient funds for gas * price + value")}
+	var mu sync.Mutex
+	nonces := make(map[uint64]bool)
+	opts := &TxOptions{OnSubmit: func(tx *types.Transaction) {
+		mu.Lock()
+		defer mu.Unlock()
+		nonces[tx.Nonce()] = true
+	}}
+	resChan, err := DeployAll(ctx, backend, userKey, []DeploySpec{
+		{Name: "First", Contract: contract, Opts: opts},
+		{Name: "Second", Contract: contract, Opts: opts},
+		{Name: "Third", Contract: contract, Opts: opts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				blockchain.Commit()
+			}
+		}
+	}()
+
+	var failed int
+	for result := range resChan {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if failed != 1 || len(nonces) != 2 || !nonces[0] || !nonces[1] {
+		t.Fatalf("ERROR expected one failure and nonces 0 and 1, got %d failures and nonces %v", failed, nonces)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+)
+
+// deploymentEntry is a contract of a deployment document, see WriteDeployment
+type deploymentEntry struct {
+	Name    string          `json:"name"`
+	Address common.Address  `json:"address"`
+	ABI     json.RawMessage `json:"abi"`
+}
+
+// Export returns the artifact of the deployed contract, its name, address,
+// bytecode, ABI and build provenance. The name is the compilation target of
+// the contract metadata, empty for contracts compiled without metadata.
+func (ci ContractInstance) Export() ContractArtifact {
+	artifact := ContractArtifact{Address: ci.Address}
+	if ci.Contract == nil {
+		return artifact
+	}
+	artifact.Name = contractName(ci.Contract)
+	artifact.Bin = strings.TrimPrefix(ci.Contract.Code, "0x")
+	if ci.Contract.Info.AbiDefinition != nil {
+		if abiJSON, err := json.Marshal(ci.Contract.Info.AbiDefinition); err == nil {
+			artifact.ABI = string(abiJSON)
+		}
+	}
+	artifact.CompilerVersion = ci.Contract.Info.CompilerVersion
+	artifact.CompilerOptions = ci.Contract.Info.CompilerOptions
+	artifact.Metadata = ci.Contract.Info.Metadata
+	return artifact
+}
+
+// contractName returns the name of the contract from the compilation target of
+// its metadata
+func contractName(c *compiler.Contract) string {
+	var metadata struct {
+		Settings struct {
+			CompilationTarget map[string]string `json:"compilationTarget"`
+		} `json:"settings"`
+	}
+	if err := json.Unmarshal([]byte(c.Info.Metadata), &metadata); err != nil {
+		return ""
+	}
+	for _, name := range metadata.Settings.CompilationTarget {
+		return name
+	}
+	return ""
+}
+
+// WriteDeployment writes the name, address and ABI of the deployed instances
+// to w as a JSON array, for other tools to interact with the contracts or for
+// LoadDeployment to restore them
+func WriteDeployment(w io.Writer, instances []ContractInstance) error {
+	entries := make([]deploymentEntry, len(instances))
+	for i, ci := range instances {
+		artifact := ci.Export()
+		if artifact.ABI == "" {
+			return fmt.Errorf("contract at %s has no ABI", ci.Address.Hex())
+		}
+		entries[i] = deploymentEntry{
+			Name:    artifact.Name,
+			Address: artifact.Address,
+			ABI:     json.RawMessage(artifact.ABI),
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(entries); err != nil {
+		return fmt.Errorf("failed to write deployment: %v", err)
+	}
+	return nil
+}
+
+// LoadDeployment reads the contracts written by WriteDeployment, in the same
+// order, so they can be used again without redeploying them. The instances
+// have no backend, see WithBackend, nor bytecode.
+func LoadDeployment(r io.Reader) ([]ContractInstance, error) {
+	var entries []deploymentEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to read deployment: %v", err)
+	}
+
+	instances := make([]ContractInstance, len(entries))
+	for i, entry := range entries {
+		var abiDefinition interface{}
+		if err := json.Unmarshal(entry.ABI, &abiDefinition); err != nil {
+			return nil, fmt.Errorf("failed to read ABI of %s: %v", entry.Name, err)
+		}
+		instances[i] = NewContractInstance(&compiler.Contract{
+			Info: compiler.ContractInfo{AbiDefinition: abiDefinition},
+		}, entry.Address)
+	}
+	return instances, nil
+}
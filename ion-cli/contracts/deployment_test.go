@@ -0,0 +1,46 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func Test_WriteAndLoadDeployment(t *testing.T) {
+	contract, err := precompiledContract(`[{"type":"function","name":"fire","inputs":[],"outputs":[]}]`, "6060")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract.Info.Metadata = `{"settings":{"compilationTarget":{"Trigger.sol":"Trigger"}}}`
+	addr := common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314")
+
+	artifact := NewContractInstance(contract, addr).Export()
+	if artifact.Name != "Trigger" || artifact.Address != addr || artifact.Bin != "6060" {
+		t.Fatalf("ERROR unexpected artifact %+v", artifact)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDeployment(&buf, []ContractInstance{NewContractInstance(contract, addr)}); err != nil {
+		t.Fatal(err)
+	}
+	instances, err := LoadDeployment(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 1 || instances[0].Address != addr {
+		t.Fatalf("ERROR unexpected instances %v", instances)
+	}
+	parsed, err := instances[0].ABI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := parsed.Methods["fire"]; !ok {
+		t.Fatal("ERROR expected the ABI to be restored")
+	}
+
+	if err := WriteDeployment(&buf, []ContractInstance{NewContractInstance(nil, addr)}); err == nil {
+		t.Fatal("ERROR expected a contract without ABI to be rejected")
+	}
+}
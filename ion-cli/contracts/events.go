@@ -0,0 +1,93 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DecodedEvent is a log of a receipt decoded against the ABI of its contract
+type DecodedEvent struct {
+	// Name of the event
+	Name string
+	// Address of the contract which emitted the event
+	Address common.Address
+	// Args by name. Indexed arguments of dynamic types only keep the hash of
+	// their value stored in the topic, as a common.Hash.
+	Args map[string]interface{}
+	// Log decoded
+	Log *types.Log
+}
+
+// DecodeLogs decodes the logs of receipt emitted by the events of contractABI.
+// Logs of other events, like those of contracts called along the way, are skipped.
+func DecodeLogs(receipt *types.Receipt, contractABI abi.ABI) ([]DecodedEvent, error) {
+	events := make(map[common.Hash]abi.Event)
+	for _, event := range contractABI.Events {
+		if !event.Anonymous {
+			events[event.Id()] = event
+		}
+	}
+
+	var decoded []DecodedEvent
+	for _, vlog := range receipt.Logs {
+		if len(vlog.Topics) == 0 {
+			continue
+		}
+		event, ok := events[vlog.Topics[0]]
+		if !ok {
+			continue
+		}
+
+		args, err := decodeEventArgs(event, vlog)
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, DecodedEvent{
+			Name:    event.Name,
+			Address: vlog.Address,
+			Args:    args,
+			Log:     vlog,
+		})
+	}
+	return decoded, nil
+}
+
+// decodeEventArgs unpacks the indexed arguments of event from the topics of
+// vlog and the others from its data
+func decodeEventArgs(event abi.Event, vlog *types.Log) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+
+	values, err := event.Inputs.NonIndexed().UnpackValues(vlog.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack data of event %s: %v", event.Name, err)
+	}
+
+	topics := vlog.Topics[1:]
+	for _, input := range event.Inputs {
+		if !input.Indexed {
+			args[input.Name], values = values[0], values[1:]
+			continue
+		}
+		if len(topics) == 0 {
+			return nil, fmt.Errorf("missing topic of indexed argument %s of event %s", input.Name, event.Name)
+		}
+		topic := topics[0]
+		topics = topics[1:]
+
+		switch input.Type.T {
+		case abi.StringTy, abi.BytesTy, abi.SliceTy, abi.ArrayTy:
+			args[input.Name] = topic
+		default:
+			value, err := abi.Arguments{{Type: input.Type}}.UnpackValues(topic.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("failed to unpack topic %s of event %s: %v", input.Name, event.Name, err)
+			}
+			args[input.Name] = value[0]
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const eventsABI = `[
+	{"type":"event","name":"Executed","inputs":[],"anonymous":false},
+	{"type":"event","name":"Triggered","inputs":[
+		{"name":"caller","type":"address","indexed":true},
+		{"name":"value","type":"uint256","indexed":false}
+	],"anonymous":false}
+]`
+
+func Test_DecodeLogs(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(eventsABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caller := common.HexToAddress("0x2be5ab0e43b6dc2908d5321cf318f35b80d0c10d")
+	data, err := parsed.Events["Triggered"].Inputs.NonIndexed().Pack(big.NewInt(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receipt := &types.Receipt{Logs: []*types.Log{
+		{Topics: []common.Hash{crypto.Keccak256Hash([]byte("Executed()"))}},
+		{Topics: []common.Hash{crypto.Keccak256Hash([]byte("Unknown()"))}},
+		{
+			Topics: []common.Hash{
+				crypto.Keccak256Hash([]byte("Triggered(address,uint256)")),
+				common.BytesToHash(caller.Bytes()),
+			},
+			Data: data,
+		},
+	}}
+
+	events, err := DecodeLogs(receipt, parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("ERROR expected 2 decoded events, got %d", len(events))
+	}
+	if events[0].Name != "Executed" || len(events[0].Args) != 0 {
+		t.Fatalf("ERROR unexpected first event %+v", events[0])
+	}
+	if events[1].Name != "Triggered" {
+		t.Fatalf("ERROR unexpected second event %s", events[1].Name)
+	}
+	if events[1].Args["caller"] != caller {
+		t.Fatalf("ERROR unexpected caller %v", events[1].Args["caller"])
+	}
+	if value, ok := events[1].Args["value"].(*big.Int); !ok || value.Int64() != 42 {
+		t.Fatalf("ERROR unexpected value %v", events[1].Args["value"])
+	}
+}
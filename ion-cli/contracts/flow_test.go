@@ -2,7 +2,6 @@
 package contract
 
 import (
-	"bytes"
 	"context"
 	"math/big"
 	"testing"
@@ -15,7 +14,6 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // TODO
@@ -81,20 +79,26 @@ func Test_VerifyTx(t *testing.T) {
 	// ---------------------------------------------
 	// COMPILE AND DEPLOY ION
 	// ---------------------------------------------
-	contractChan := CompileAndDeployIon(ctx, blockchain, userKey, deployedChainID)
+	ionChan, ionErrChan := CompileAndDeployIon(ctx, blockchain, userKey, deployedChainID, nil, nil)
 	blockchain.Commit()
-	<-contractChan // PatriciaTrie libraryContractInstance
+	<-ionChan // PatriciaTrie libraryContractInstance
 	blockchain.Commit()
-	ionContractInstance := <-contractChan
+	ionContractInstance, ok := <-ionChan
+	if !ok {
+		t.Fatal("ERROR deploying Ion", <-ionErrChan)
+	}
 
 	// ---------------------------------------------
 	// COMPILE AND DEPLOY VALIDATION
 	// ---------------------------------------------
 	var ionContractAddr [20]byte
 	copy(ionContractAddr[:], ionContractInstance.Address.Bytes())
-	contractChan = CompileAndDeployValidation(ctx, blockchain, userKey, deployedChainID, ionContractAddr)
+	validationChan, validationErrChan := CompileAndDeployValidation(ctx, blockchain, userKey, deployedChainID, ionContractAddr, nil, nil)
 	blockchain.Commit()
-	validationContractInstance := <-contractChan
+	validationContractInstance, ok := <-validationChan
+	if !ok {
+		t.Fatal("ERROR deploying Validation", <-validationErrChan)
+	}
 
 	// ---------------------------------------------
 	// REGISTER CHAIN ON VALIDATION
@@ -102,7 +106,7 @@ func Test_VerifyTx(t *testing.T) {
 
 	var genesisHash [32]byte
 	copy(genesisHash[:], block.ParentHash().Bytes())
-	txRegisterChainValidation := RegisterChain(
+	txRegisterChainValidation, err := RegisterChain(
 		ctx,
 		blockchain,
 		userKey,
@@ -112,6 +116,9 @@ func Test_VerifyTx(t *testing.T) {
 		testValidators,
 		genesisHash,
 	)
+	if err != nil {
+		t.Fatal("ERROR registering chain", err)
+	}
 	blockchain.Commit()
 	registerChainValidationReceipt, err := bind.WaitMined(ctx, blockchain, txRegisterChainValidation)
 	if err != nil || registerChainValidationReceipt.Status == 0 {
@@ -121,22 +128,20 @@ func Test_VerifyTx(t *testing.T) {
 	// ---------------------------------------------
 	// SUBMIT BLOCK ON VALIDATION
 	// ---------------------------------------------
-	blockHeader := block.Header()
-	extraData := blockHeader.Extra
-	unsignedExtraData := extraData[:len(extraData)-(64+1)] // 64 bytes + 1 vanity byte
-	signedBlockHeaderRLP, _ := rlp.EncodeToBytes(blockHeader)
-	blockHeader.Extra = unsignedExtraData
-	unsignedBlockHeaderRLP, _ := rlp.EncodeToBytes(blockHeader)
-	txSubmitBlockValidation := SubmitBlock(
+	txSubmitBlockValidation, err := SubmitBlock(
 		ctx,
 		blockchain,
 		userKey,
 		validationContractInstance.Contract,
 		validationContractInstance.Address,
 		testChainID,
-		unsignedBlockHeaderRLP,
-		signedBlockHeaderRLP,
+		block.Header(),
+		utils.Clique,
+		nil,
 	)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	blockchain.Commit()
 	submitBlockValidationReceipt, err := bind.WaitMined(ctx, blockchain, txSubmitBlockValidation)
@@ -144,6 +149,25 @@ func Test_VerifyTx(t *testing.T) {
 		t.Fatal("ERROR while waiting for contract deployment")
 	}
 
+	submitted, err := IsBlockSubmitted(ctx, blockchain, validationContractInstance.Address, testChainID, block.Hash())
+	if err != nil || !submitted {
+		t.Fatal("ERROR expected the block to be submitted", err)
+	}
+	_, err = SubmitBlock(
+		ctx,
+		blockchain,
+		userKey,
+		validationContractInstance.Contract,
+		validationContractInstance.Address,
+		testChainID,
+		block.Header(),
+		utils.Clique,
+		&SubmitBlockOptions{CheckSubmitted: true},
+	)
+	if err != ErrBlockAlreadySubmitted {
+		t.Fatalf("ERROR expected ErrBlockAlreadySubmitted, got %v", err)
+	}
+
 	// ---------------------------------------------
 	// CHECK ROOTS PROOF ON ION
 	// ---------------------------------------------
@@ -158,20 +182,23 @@ func Test_VerifyTx(t *testing.T) {
 	receiptKey := []byte{0x01}
 	receiptProofArr := utils.Proof(receiptTrie, receiptKey)
 
-	checkRootsProofIon := TransactionContract(
+	checkRootsProofIon, err := TransactionContract(
 		ctx,
 		blockchain,
 		userKey,
 		ionContractInstance.Contract,
 		ionContractInstance.Address,
 		nil,
-		uint64(3000000),
+		nil,
 		"CheckRootsProof",
 		testChainID,
 		blockHash,
 		txProofArr,
 		receiptProofArr,
 	)
+	if err != nil {
+		t.Fatal("ERROR checking roots proof", err)
+	}
 
 	blockchain.Commit()
 	chackRootsProofIonReceipt, err := bind.WaitMined(ctx, blockchain, checkRootsProofIon)
@@ -182,16 +209,21 @@ func Test_VerifyTx(t *testing.T) {
 	// ---------------------------------------------
 	// COMPILE AND DEPLOY TRIGGER VERIFIER AND CONSUMER FUNCTION
 	// ---------------------------------------------
-	contractChan = CompileAndDeployTriggerVerifierAndConsumerFunction(
+	contractChan, errChan := CompileAndDeployTriggerVerifierAndConsumerFunction(
 		ctx,
 		blockchain,
 		userKey,
 		ionContractInstance.Address,
+		nil,
+		nil,
 	)
 	blockchain.Commit()
 	<-contractChan // triggerEventVerifierContractInstance := <-contractChan
 	blockchain.Commit()
-	consumerFunctionContractInstance := <-contractChan
+	consumerFunctionContractInstance, ok := <-contractChan
+	if !ok {
+		t.Fatal("ERROR deploying trigger verifier and consumer function: ", <-errChan)
+	}
 
 	// ---------------------------------------------
 	// VERIFY FUNCTION EXECUITION
@@ -199,29 +231,59 @@ func Test_VerifyTx(t *testing.T) {
 	triggerCalledBy, _ := types.Sender(signer, txTrigger)
 
 	// Generate the proof
-	txPath, txValue, txNodes, receiptValue, receiptNodes := utils.GenerateProof(
+	proof, err := utils.GenerateProof(
 		ctx,
 		clientRPC,
 		txHashWithEvent,
+		nil,
 	)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	txVerifyAndExecuteFunction := VerifyExecute(
+	verifyExecuteParams := VerifyExecuteParams{
+		ChainID:      testChainID,
+		BlockHash:    blockHash,
+		TxTo:         *txTrigger.To(),
+		TxPath:       proof.TxPath,
+		TxRLP:        proof.TxValue,
+		TxProof:      proof.TxNodes,
+		Receipt:      proof.ReceiptValue,
+		ReceiptProof: proof.ReceiptNodes,
+		CalledBy:     triggerCalledBy,
+	}
+
+	// simulate the verification before sending it
+	verified, err := CallVerifyExecute(
+		ctx,
+		blockchain,
+		consumerFunctionContractInstance.Contract,
+		consumerFunctionContractInstance.Address,
+		crypto.PubkeyToAddress(userKey.PublicKey),
+		verifyExecuteParams,
+	)
+	if err != nil || !verified {
+		t.Fatal("ERROR expected the simulated verifyAndExecute to succeed", err)
+	}
+
+	// verifyAndExecute isn't payable
+	payingParams := verifyExecuteParams
+	payingParams.Amount = big.NewInt(1)
+	if _, err := VerifyExecuteWithParams(ctx, blockchain, userKey, consumerFunctionContractInstance.Contract, consumerFunctionContractInstance.Address, payingParams); err == nil {
+		t.Fatal("ERROR expected an amount sent to verifyAndExecute to be rejected")
+	}
+
+	txVerifyAndExecuteFunction, err := VerifyExecuteWithParams(
 		ctx,
 		blockchain,
 		userKey,
 		consumerFunctionContractInstance.Contract,
 		consumerFunctionContractInstance.Address,
-		testChainID,
-		blockHash,
-		*txTrigger.To(), // TRIG_DEPLOYED_RINKEBY_ADDR,
-		txPath,          // TEST_PATH,
-		txValue,         // TEST_TX_VALUE,
-		txNodes,         // TEST_TX_NODES,
-		receiptValue,    // TEST_RECEIPT_VALUE,
-		receiptNodes,    // TEST_RECEIPT_NODES,
-		triggerCalledBy, // TRIG_CALLED_BY,
-		nil,
+		verifyExecuteParams,
 	)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	blockchain.Commit()
 	verifyAndExecuteFunctionReceipt, err := bind.WaitMined(ctx, blockchain, txVerifyAndExecuteFunction)
@@ -231,15 +293,19 @@ func Test_VerifyTx(t *testing.T) {
 	}
 
 	// confirm the Executed event was emited by Consumer Function
-	eventSignatureHash := crypto.Keccak256Hash([]byte("Executed()")) // Ion argument
+	consumerFunctionABI, err := consumerFunctionContractInstance.ABI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := DecodeLogs(verifyAndExecuteFunctionReceipt, consumerFunctionABI)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	foundExecuted := false
-	for _, vlog := range verifyAndExecuteFunctionReceipt.Logs {
-		if len(vlog.Topics) < 1 {
-			continue
-		}
-		foundExecuted = bytes.Equal(vlog.Topics[0].Bytes(), eventSignatureHash.Bytes())
-		if foundExecuted {
+	for _, event := range events {
+		if event.Name == "Executed" {
+			foundExecuted = true
 			break
 		}
 	}
@@ -247,3 +313,13 @@ func Test_VerifyTx(t *testing.T) {
 		t.Fatal("ERROR did not find Executed() event")
 	}
 }
+
+func Test_VerifyExecuteFromArgsWithoutChainID(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	blockchain := backends.NewSimulatedBackend(core.GenesisAlloc{})
+
+	_, err := VerifyExecuteFromArgs(context.Background(), blockchain, key, nil, common.Address{}, nil, &VerifyExecuteArgs{}, nil)
+	if err == nil {
+		t.Fatal("ERROR expected arguments without a source chain id to be rejected")
+	}
+}
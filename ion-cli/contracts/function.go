@@ -2,101 +2,402 @@
 package contract
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
-	"log"
+	"errors"
+	"fmt"
 	"math/big"
-	"os"
+	"sort"
+	"strings"
 
+	"github.com/clearmatics/ion/ion-cli/utils"
+	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/compiler"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// CompiledArtifacts holds the contracts compiled for a deployment, so they
+// can be reused to call the deployed contracts, e.g. by VerifyExecute, without
+// compiling them again
+type CompiledArtifacts struct {
+	// Contracts compiled, keyed by path:Name like the output of Compile
+	Contracts map[string]*compiler.Contract
+}
+
+// Contract returns the compiled contract name, either its path:Name key or
+// the Name alone when a single compiled contract has that name
+func (a *CompiledArtifacts) Contract(name string) (*compiler.Contract, error) {
+	if a == nil {
+		return nil, fmt.Errorf("no compiled contracts")
+	}
+	if contract, ok := a.Contracts[name]; ok && contract != nil {
+		return contract, nil
+	}
+
+	var matches []string
+	for key := range a.Contracts {
+		if strings.HasSuffix(key, ":"+name) {
+			matches = append(matches, key)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("contract %s not among the compiled contracts", name)
+	case 1:
+		return compiledContract(a.Contracts, matches[0])
+	default:
+		sort.Strings(matches)
+		return nil, fmt.Errorf("contract %s is ambiguous, compiled as [%s]", name, strings.Join(matches, ", "))
+	}
+}
+
+// Progress stages of the trigger verifier and consumer function deployment,
+// passed to TxOptions.OnProgress
+const (
+	// ProgressCompiled follows the compilation, with the *CompiledArtifacts
+	ProgressCompiled = "compiled"
+	// ProgressTriggerSubmitted follows sending the TriggerEventVerifier
+	// deployment, with its *types.Transaction
+	ProgressTriggerSubmitted = "trigger-submitted"
+	// ProgressTriggerMined follows the TriggerEventVerifier deployment being
+	// mined, with its ContractInstance
+	ProgressTriggerMined = "trigger-mined"
+	// ProgressFunctionSubmitted follows sending the Function deployment, with
+	// its *types.Transaction
+	ProgressFunctionSubmitted = "function-submitted"
+	// ProgressFunctionMined follows the Function deployment being mined, with
+	// its ContractInstance
+	ProgressFunctionMined = "function-mined"
+)
+
+// CompileTriggerVerifierAndConsumerFunction compiles TriggerEventVerifier.sol
+// and Function.sol for DeployTriggerVerifierAndConsumerFunction
+func CompileTriggerVerifierAndConsumerFunction(compileOpts *CompileOptions) (*CompiledArtifacts, error) {
+	basePath, err := contractsBasePath("TriggerEventVerifier.sol", "Function.sol")
+	if err != nil {
+		return nil, err
+	}
+	triggerEventVerifierContractPath := basePath + "TriggerEventVerifier.sol"
+	consumerFunctionContractPath := basePath + "Function.sol"
+
+	contracts, err := Compile(compileOpts, consumerFunctionContractPath, triggerEventVerifierContractPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile TriggerEventVerifier.sol: %v", err)
+	}
+	for _, key := range []string{triggerEventVerifierContractPath + ":TriggerEventVerifier", consumerFunctionContractPath + ":Function"} {
+		if _, err := compiledContract(contracts, key); err != nil {
+			return nil, err
+		}
+	}
+	return &CompiledArtifacts{Contracts: contracts}, nil
+}
+
 // CompileAndDeployTriggerVerifierAndConsumerFunction method
+// Instances are sent on the first channel in deployment order. Any failure is
+// sent on the error channel and both channels are closed, so callers should
+// check the error channel once the instance channel is drained. Cancelling ctx
+// stops the pipeline before the next deployment is sent, and lets it return
+// even when the instance channel is abandoned before being drained.
+// The instances hold the compiled contracts, or compile them first with
+// CompileTriggerVerifierAndConsumerFunction and deploy them with
+// DeployTriggerVerifierAndConsumerFunction to keep them.
+// opts.OnProgress is called at every step, see ProgressCompiled.
 func CompileAndDeployTriggerVerifierAndConsumerFunction(
 	ctx context.Context,
 	client bind.ContractBackend,
 	userKey *ecdsa.PrivateKey,
 	ionContractAddress common.Address,
-) <-chan ContractInstance {
-	// ---------------------------------------------
-	// COMPILE VALIDATION AND DEPENDENCIES
-	// ---------------------------------------------
-	basePath := os.Getenv("GOPATH") + "/src/github.com/clearmatics/ion/contracts/"
-	triggerEventVerifierContractPath := basePath + "TriggerEventVerifier.sol"
-	consumerFunctionContractPath := basePath + "Function.sol"
+	opts *TxOptions,
+	compileOpts *CompileOptions,
+) (<-chan ContractInstance, <-chan error) {
+	artifacts, err := CompileTriggerVerifierAndConsumerFunction(compileOpts)
+	if err != nil {
+		return failedDeployment(err)
+	}
+	opts.progress(ProgressCompiled, artifacts)
+	return DeployTriggerVerifierAndConsumerFunction(ctx, client, userKey, ionContractAddress, artifacts, opts)
+}
 
-	contracts, err := compiler.CompileSolidity("", consumerFunctionContractPath, triggerEventVerifierContractPath)
+// DeployTriggerVerifierAndConsumerFunction deploys the TriggerEventVerifier
+// and Function contracts compiled in artifacts, see
+// CompileAndDeployTriggerVerifierAndConsumerFunction. opts.OnProgress is
+// called at every step but the compilation.
+func DeployTriggerVerifierAndConsumerFunction(
+	ctx context.Context,
+	client bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	ionContractAddress common.Address,
+	artifacts *CompiledArtifacts,
+	opts *TxOptions,
+) (<-chan ContractInstance, <-chan error) {
+	opts = opts.withNonces()
+	resChan := make(chan ContractInstance)
+	errChan := make(chan error, 1)
+
+	ctx, cancel := opts.withTimeout(ctx)
+	fail := func(err error) (<-chan ContractInstance, <-chan error) {
+		cancel()
+		errChan <- err
+		close(errChan)
+		close(resChan)
+		return resChan, errChan
+	}
+
+	triggerEventVerifierContract, err := artifacts.Contract("TriggerEventVerifier")
 	if err != nil {
-		log.Fatal("ERROR failed to compile TriggerEventVerifier.sol:", err)
+		return fail(err)
+	}
+	triggerEventVerifierBinStr, triggerEventVerifierABIStr, err := getContractBytecodeAndABI(triggerEventVerifierContract)
+	if err != nil {
+		return fail(err)
+	}
+	consumerFunctionContract, err := artifacts.Contract("Function")
+	if err != nil {
+		return fail(err)
+	}
+	consumerFunctionBinStr, consumerFunctionABIStr, err := getContractBytecodeAndABI(consumerFunctionContract)
+	if err != nil {
+		return fail(err)
 	}
 
-	triggerEventVerifierContract := contracts[triggerEventVerifierContractPath+":TriggerEventVerifier"]
-	triggerEventVerifierBinStr, triggerEventVerifierABIStr := getContractBytecodeAndABI(triggerEventVerifierContract)
-	consumerFunctionContract := contracts[consumerFunctionContractPath+":Function"]
-	consumerFunctionBinStr, consumerFunctionABIStr := getContractBytecodeAndABI(consumerFunctionContract)
+	deployBackend, ok := client.(bind.DeployBackend)
+	if !ok {
+		return fail(fmt.Errorf("client can't wait for deployments to be mined"))
+	}
 
 	// ---------------------------------------------
 	// DEPLOY TRIGGER EVENT CONTRACT
 	// ---------------------------------------------
-	triggerEventSignedTx := compileAndDeployContract(
+	if err := ctx.Err(); err != nil {
+		return fail(stageError(ctx, StageCompile, errCancelled(err)))
+	}
+	triggerEventSignedTx, err := compileAndDeployContract(
 		ctx,
 		client,
 		userKey,
 		triggerEventVerifierBinStr,
 		triggerEventVerifierABIStr,
 		nil,
-		uint64(3000000),
+		opts,
 	)
+	if err != nil {
+		return fail(stageError(ctx, StageSubmit, fmt.Errorf("failed to deploy TriggerEventVerifier: %v", err)))
+	}
+	opts.progress(ProgressTriggerSubmitted, triggerEventSignedTx)
 
-	resChan := make(chan ContractInstance)
-
-	// Go-Routine that waits for PatriciaTrie Library and Ion Contract to be deployed
-	// Ion depends on PatriciaTrie library
+	// Go-Routine that waits for the trigger event verifier and consumer function to be deployed
+	// The consumer function depends on the trigger event verifier address
 	go func() {
+		defer cancel()
+		defer close(errChan)
 		defer close(resChan)
-		deployBackend := client.(bind.DeployBackend)
 
 		// wait for trigger event contract to be deployed
-		triggerEventAddr, err := bind.WaitDeployed(ctx, deployBackend, triggerEventSignedTx)
+		triggerEventAddr, triggerEventReceipt, err := waitDeployed(ctx, deployBackend, triggerEventSignedTx, "TriggerEventVerifier", opts)
 		if err != nil {
-			log.Fatal("ERROR while waiting for contract deployment")
+			errChan <- stageError(ctx, StageWait, err)
+			return
 		}
+		triggerEventVerifier := deployedInstance(client, triggerEventVerifierContract, triggerEventAddr, triggerEventSignedTx, triggerEventReceipt)
+		opts.progress(ProgressTriggerMined, triggerEventVerifier)
 
 		// ---------------------------------------------
 		// DEPLOY CONSUMER FUNCTION CONTRACT
 		// ---------------------------------------------
-		consumerFunctionSignedTx := compileAndDeployContract(
+		if err := ctx.Err(); err != nil {
+			errChan <- stageError(ctx, StageSubmit, errCancelled(err))
+			return
+		}
+		consumerFunctionSignedTx, err := compileAndDeployContract(
 			ctx,
 			client,
 			userKey,
 			consumerFunctionBinStr,
 			consumerFunctionABIStr,
 			nil,
-			uint64(3000000),
+			opts,
 			ionContractAddress,
 			triggerEventAddr,
 		)
+		if err != nil {
+			errChan <- stageError(ctx, StageSubmit, fmt.Errorf("failed to deploy Function: %v", err))
+			return
+		}
+		opts.progress(ProgressFunctionSubmitted, consumerFunctionSignedTx)
 
-		resChan <- ContractInstance{triggerEventVerifierContract, triggerEventAddr}
+		if !sendInstance(ctx, resChan, errChan, triggerEventVerifier) {
+			return
+		}
 
 		// wait for consumer function contract to be deployed
-		consumerFunctionAddr, err := bind.WaitDeployed(ctx, deployBackend, consumerFunctionSignedTx)
+		consumerFunctionAddr, consumerFunctionReceipt, err := waitDeployed(ctx, deployBackend, consumerFunctionSignedTx, "Function", opts)
 		if err != nil {
-			log.Fatal("ERROR while waiting for contract deployment")
+			errChan <- stageError(ctx, StageWait, err)
+			return
 		}
 
-		resChan <- ContractInstance{consumerFunctionContract, consumerFunctionAddr}
+		consumerFunction := deployedInstance(client, consumerFunctionContract, consumerFunctionAddr, consumerFunctionSignedTx, consumerFunctionReceipt)
+		opts.progress(ProgressFunctionMined, consumerFunction)
+		sendInstance(ctx, resChan, errChan, consumerFunction)
 	}()
 
-	return resChan
+	return resChan, errChan
 }
 
+// DeployConsumerFunctions compiles Function.sol once and deploys count
+// instances of it consuming the trigger events verified by the
+// TriggerEventVerifier at triggerAddr against the Ion contract at ionAddr. The
+// deployments are all sent up front with consecutive nonces, then the instances
+// are sent on the first channel in deployment order as they are mined. Any
+// failure is sent on the error channel and both channels are closed.
+func DeployConsumerFunctions(
+	ctx context.Context,
+	client bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	ionAddr common.Address,
+	triggerAddr common.Address,
+	count int,
+	opts *TxOptions,
+	compileOpts *CompileOptions,
+) (<-chan ContractInstance, <-chan error) {
+	opts = opts.withNonces()
+	resChan := make(chan ContractInstance)
+	errChan := make(chan error, 1)
+
+	ctx, cancel := opts.withTimeout(ctx)
+	fail := func(err error) (<-chan ContractInstance, <-chan error) {
+		cancel()
+		errChan <- err
+		close(errChan)
+		close(resChan)
+		return resChan, errChan
+	}
+
+	if count <= 0 {
+		return fail(fmt.Errorf("invalid number of consumer functions %d", count))
+	}
+	deployBackend, ok := client.(bind.DeployBackend)
+	if !ok {
+		return fail(fmt.Errorf("client can't wait for deployments to be mined"))
+	}
+
+	basePath, err := contractsBasePath("TriggerEventVerifier.sol", "Function.sol")
+	if err != nil {
+		return fail(err)
+	}
+	consumerFunctionContractPath := basePath + "Function.sol"
+
+	contracts, err := Compile(compileOpts, consumerFunctionContractPath, basePath+"TriggerEventVerifier.sol")
+	if err != nil {
+		return fail(fmt.Errorf("failed to compile Function.sol: %v", err))
+	}
+	consumerFunctionContract, err := compiledContract(contracts, consumerFunctionContractPath+":Function")
+	if err != nil {
+		return fail(err)
+	}
+	consumerFunctionBinStr, consumerFunctionABIStr, err := getContractBytecodeAndABI(consumerFunctionContract)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fail(stageError(ctx, StageCompile, errCancelled(err)))
+	}
+	signedTxs := make([]*types.Transaction, count)
+	for i := range signedTxs {
+		if err := ctx.Err(); err != nil {
+			return fail(stageError(ctx, StageSubmit, errCancelled(err)))
+		}
+		signedTxs[i], err = compileAndDeployContract(
+			ctx,
+			client,
+			userKey,
+			consumerFunctionBinStr,
+			consumerFunctionABIStr,
+			nil,
+			opts,
+			ionAddr,
+			triggerAddr,
+		)
+		if err != nil {
+			return fail(stageError(ctx, StageSubmit, fmt.Errorf("failed to deploy Function %d of %d: %v", i+1, count, err)))
+		}
+	}
+
+	go func() {
+		defer cancel()
+		defer close(errChan)
+		defer close(resChan)
+
+		for i, signedTx := range signedTxs {
+			addr, receipt, err := waitDeployed(ctx, deployBackend, signedTx, fmt.Sprintf("Function %d", i+1), opts)
+			if err != nil {
+				errChan <- stageError(ctx, StageWait, err)
+				return
+			}
+			if !sendInstance(ctx, resChan, errChan, deployedInstance(client, consumerFunctionContract, addr, signedTx, receipt)) {
+				return
+			}
+		}
+	}()
+
+	return resChan, errChan
+}
+
+// VerifyExecuteParams holds the arguments of verifyAndExecute
+type VerifyExecuteParams struct {
+	// ChainID of the source chain the trigger happened on
+	ChainID common.Hash
+	// BlockHash of the source block including the trigger transaction
+	BlockHash common.Hash
+	// TxTo is the address of the trigger contract called by the transaction
+	TxTo common.Address
+	// TxPath is the RLP encoded index of the transaction in the block
+	TxPath []byte
+	// TxRLP is the RLP encoded trigger transaction
+	TxRLP []byte
+	// TxProof is the RLP encoded array of transaction trie nodes
+	TxProof []byte
+	// Receipt is the RLP encoded receipt of the trigger transaction
+	Receipt []byte
+	// ReceiptProof is the RLP encoded array of receipt trie nodes
+	ReceiptProof []byte
+	// CalledBy is the sender of the trigger transaction
+	CalledBy common.Address
+	// ExpectedChainID guards against proving against the wrong source chain.
+	// When set ChainID must match it.
+	ExpectedChainID common.Hash
+	// Amount sent with the transaction, none when nil. A non-zero amount is
+	// rejected unless verifyAndExecute is payable.
+	Amount *big.Int
+	// Opts of the transaction, defaults when nil
+	Opts *TxOptions
+	// WaitMined waits for the transaction to be mined, failing with the
+	// reason it reverted with, e.g. an invalid proof or an unregistered block
+	WaitMined bool
+	// IonAddr of the Ion contract on the destination chain. When set the block
+	// is checked to be stored by Ion before anything is sent, failing with
+	// ErrBlockNotRegistered rather than with a revert.
+	IonAddr *common.Address
+}
+
+// ErrBlockNotRegistered is returned by VerifyExecuteWithParams for a block Ion
+// doesn't have yet, see VerifyExecuteParams.IonAddr
+var ErrBlockNotRegistered = errors.New("block not registered with Ion, submit it to the validation contract first")
+
+// VerifyExecute calls verifyAndExecute on the consumer function contract with
+// the proofs of the trigger transaction
+//
+// Deprecated: use VerifyExecuteWithParams, which can't have its byte slice
+// arguments transposed.
 func VerifyExecute(
 	ctx context.Context,
-	backend bind.ContractBackend,
+	destClient bind.ContractBackend,
 	userKey *ecdsa.PrivateKey,
 	contract *compiler.Contract,
 	toAddr common.Address,
@@ -110,26 +411,294 @@ func VerifyExecute(
 	receiptTriggerProofArr []byte,
 	triggerCalledBy common.Address,
 	amount *big.Int,
+	opts *TxOptions,
+) (*types.Transaction, error) {
+	return VerifyExecuteWithParams(ctx, destClient, userKey, contract, toAddr, VerifyExecuteParams{
+		ChainID:      chainId,
+		BlockHash:    blockHash,
+		TxTo:         txTriggerTo,
+		TxPath:       txTriggerPath,
+		TxRLP:        txTriggerRLP,
+		TxProof:      txTriggerProofArr,
+		Receipt:      receiptTrigger,
+		ReceiptProof: receiptTriggerProofArr,
+		CalledBy:     triggerCalledBy,
+		Amount:       amount,
+		Opts:         opts,
+	})
+}
 
-) (tx *types.Transaction) {
-	tx = TransactionContract(
+// VerifyExecuteWithParams calls verifyAndExecute on the consumer function
+// contract at toAddr on the destination chain of destClient. The proofs in
+// params come from the source chain, see utils.GenerateProof.
+func VerifyExecuteWithParams(
+	ctx context.Context,
+	destClient bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	contract *compiler.Contract,
+	toAddr common.Address,
+	params VerifyExecuteParams,
+) (*types.Transaction, error) {
+	if err := params.check(contract); err != nil {
+		return nil, err
+	}
+	ctx, cancel := params.Opts.withTimeout(ctx)
+	defer cancel()
+
+	if params.IonAddr != nil {
+		_, err := GetStoredBlock(ctx, destClient, *params.IonAddr, params.ChainID, params.BlockHash)
+		if errors.Is(err, ErrBlockNotStored) {
+			return nil, fmt.Errorf("block %s of chain %s: %w", params.BlockHash.Hex(), params.ChainID.Hex(), ErrBlockNotRegistered)
+		}
+		if err != nil {
+			return nil, stageError(ctx, StageSubmit, err)
+		}
+	}
+
+	tx, err := transactContract(
 		ctx,
-		backend,
+		destClient,
 		userKey,
 		contract,
 		toAddr,
-		amount,
-		uint64(3000000),
+		params.Amount,
+		params.Opts,
 		"verifyAndExecute",
-		chainId,
-		blockHash,
-		txTriggerTo,            // TRIG_DEPLOYED_RINKEBY_ADDR,
-		txTriggerPath,          // TEST_PATH,
-		txTriggerRLP,           // TEST_TX_VALUE,
-		txTriggerProofArr,      // TEST_TX_NODES,
-		receiptTrigger,         // TEST_RECEIPT_VALUE,
-		receiptTriggerProofArr, // TEST_RECEIPT_NODES,
-		triggerCalledBy,        // TRIG_CALLED_BY,
+		params.ChainID,
+		params.BlockHash,
+		params.TxTo,
+		params.TxPath,
+		params.TxRLP,
+		params.TxProof,
+		params.Receipt,
+		params.ReceiptProof,
+		params.CalledBy,
 	)
-	return
+	if err != nil || !params.WaitMined || params.Opts.dryRun() {
+		return tx, stageError(ctx, StageSubmit, err)
+	}
+
+	backend, ok := destClient.(MinedBackend)
+	if !ok {
+		return tx, fmt.Errorf("destination client can't wait for transactions to be mined")
+	}
+	if _, err := WaitMined(ctx, backend, tx); err != nil {
+		return tx, stageError(ctx, StageWait, fmt.Errorf("verifyAndExecute failed: %w", err))
+	}
+	return tx, nil
+}
+
+// VerifyExecuteArgs holds the arguments of verifyAndExecute proving a trigger
+// transaction, in the order of its parameters
+type VerifyExecuteArgs struct {
+	// ChainID the source chain is registered with in Ion. It is left empty by
+	// BuildVerifyExecuteArgs and must be set by the caller, as registered
+	// chain ids are unrelated to the chain id the source network reports.
+	ChainID common.Hash
+	// BlockHash of the source block including the trigger transaction
+	BlockHash common.Hash
+	// TxTo is the address of the trigger contract called by the transaction
+	TxTo common.Address
+	// TxPath is the RLP encoded index of the transaction in the block
+	TxPath []byte
+	// TxRLP is the RLP encoded trigger transaction
+	TxRLP []byte
+	// TxProof is the RLP encoded array of transaction trie nodes
+	TxProof []byte
+	// Receipt is the RLP encoded receipt of the trigger transaction
+	Receipt []byte
+	// ReceiptProof is the RLP encoded array of receipt trie nodes
+	ReceiptProof []byte
+	// CalledBy is the sender of the trigger transaction
+	CalledBy common.Address
+}
+
+// BuildVerifyExecuteArgs fetches the trigger transaction txHash, its receipt
+// and block from the source chain of srcBackend and assembles the arguments of
+// verifyAndExecute proving it. The proofs are checked against the block header.
+// The ChainID of the arguments is left for the caller to set.
+func BuildVerifyExecuteArgs(ctx context.Context, srcBackend *rpc.Client, txHash common.Hash) (*VerifyExecuteArgs, error) {
+	blockNumberStr, txTrigger, err := utils.BlockNumberByTransactionHash(ctx, srcBackend, txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trigger transaction: %v", err)
+	}
+	if blockNumberStr == nil {
+		return nil, fmt.Errorf("trigger transaction %s is pending", txHash.Hex())
+	}
+	if txTrigger.To() == nil {
+		return nil, fmt.Errorf("trigger transaction %s is a contract creation", txHash.Hex())
+	}
+	blockNumber, ok := new(big.Int).SetString((*blockNumberStr)[2:], 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid block number %s", *blockNumberStr)
+	}
+
+	header, err := ethclient.NewClient(srcBackend).HeaderByNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trigger block header: %v", err)
+	}
+
+	var signer types.Signer = types.HomesteadSigner{}
+	if txTrigger.Protected() {
+		signer = types.NewEIP155Signer(txTrigger.ChainId())
+	}
+	triggerCalledBy, err := types.Sender(signer, txTrigger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover trigger transaction sender: %v", err)
+	}
+
+	proof, err := utils.GenerateProof(ctx, srcBackend, txHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	// a broken proof would only be found out by the revert
+	if err := proof.Validate(header); err != nil {
+		return nil, err
+	}
+
+	args := &VerifyExecuteArgs{
+		BlockHash:    header.Hash(),
+		TxTo:         *txTrigger.To(),
+		TxPath:       proof.TxPath,
+		TxRLP:        proof.TxValue,
+		TxProof:      proof.TxNodes,
+		Receipt:      proof.ReceiptValue,
+		ReceiptProof: proof.ReceiptNodes,
+		CalledBy:     triggerCalledBy,
+	}
+	return args, nil
+}
+
+// CallVerifyExecute simulates verifyAndExecute on the consumer function
+// contract at toAddr with eth_call from the from account, without sending a
+// transaction. It returns the result of the verification, or a RevertError
+// with the reason when the call reverts, e.g. on an invalid proof. Opts and
+// WaitMined of params are unused.
+func CallVerifyExecute(
+	ctx context.Context,
+	destClient bind.ContractCaller,
+	contract *compiler.Contract,
+	toAddr common.Address,
+	from common.Address,
+	params VerifyExecuteParams,
+) (bool, error) {
+	if err := params.check(contract); err != nil {
+		return false, err
+	}
+	abiContract, err := parseContractABI(contract)
+	if err != nil {
+		return false, err
+	}
+	input, err := abiContract.Pack(
+		"verifyAndExecute",
+		params.ChainID,
+		params.BlockHash,
+		params.TxTo,
+		params.TxPath,
+		params.TxRLP,
+		params.TxProof,
+		params.Receipt,
+		params.ReceiptProof,
+		params.CalledBy,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to pack arguments of verifyAndExecute: %v", err)
+	}
+
+	output, err := destClient.CallContract(ctx, ethereum.CallMsg{From: from, To: &toAddr, Value: params.Amount, Data: input}, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to call verifyAndExecute: %v", err)
+	}
+	// the pinned go-ethereum returns the output of a reverted call without an error
+	if len(output) == 0 || bytes.HasPrefix(output, revertSelector) {
+		reason, err := decodeRevertReason(output)
+		if err != nil {
+			return false, err
+		}
+		return false, RevertError{Reason: reason}
+	}
+
+	var verified bool
+	if err := abiContract.Unpack(&verified, "verifyAndExecute", output); err != nil {
+		return false, fmt.Errorf("failed to unpack verifyAndExecute result: %v", err)
+	}
+	return verified, nil
+}
+
+// check rejects params proving for another chain than ExpectedChainID, or
+// sending an Amount the verifyAndExecute function of contract can't accept
+func (params VerifyExecuteParams) check(contract *compiler.Contract) error {
+	if params.ExpectedChainID != (common.Hash{}) && params.ChainID != params.ExpectedChainID {
+		return fmt.Errorf("source chain id mismatch: proving for chain %s, expected %s", params.ChainID.Hex(), params.ExpectedChainID.Hex())
+	}
+	if params.Amount == nil || params.Amount.Sign() == 0 {
+		return nil
+	}
+	payable, err := methodPayable(contract, "verifyAndExecute")
+	if err != nil {
+		return err
+	}
+	if !payable {
+		return fmt.Errorf("verifyAndExecute is not payable, can't send %v wei with it", params.Amount)
+	}
+	return nil
+}
+
+// VerifyExecuteFromArgs calls verifyAndExecute on the consumer function
+// contract at toAddr with args, see BuildVerifyExecuteArgs
+func VerifyExecuteFromArgs(
+	ctx context.Context,
+	destClient bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	contract *compiler.Contract,
+	toAddr common.Address,
+	amount *big.Int,
+	args *VerifyExecuteArgs,
+	opts *TxOptions,
+) (*types.Transaction, error) {
+	if args.ChainID == (common.Hash{}) {
+		return nil, fmt.Errorf("no source chain id to prove the trigger transaction for")
+	}
+	return VerifyExecuteWithParams(ctx, destClient, userKey, contract, toAddr, VerifyExecuteParams{
+		ChainID:      args.ChainID,
+		BlockHash:    args.BlockHash,
+		TxTo:         args.TxTo,
+		TxPath:       args.TxPath,
+		TxRLP:        args.TxRLP,
+		TxProof:      args.TxProof,
+		Receipt:      args.Receipt,
+		ReceiptProof: args.ReceiptProof,
+		CalledBy:     args.CalledBy,
+		Amount:       amount,
+		Opts:         opts,
+	})
+}
+
+// VerifyExecuteFromTx calls verifyAndExecute for the trigger transaction
+// txHash of the source chain. The block hash, trigger address, trigger caller
+// and the transaction and receipt proofs are all fetched from sourceClient,
+// and the transaction is sent to the consumer on the destination destClient.
+// chainId is the id the source chain is registered with, which is unrelated to
+// the chain id the source network reports.
+func VerifyExecuteFromTx(
+	ctx context.Context,
+	destClient bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	contract *compiler.Contract,
+	toAddr common.Address,
+	chainId common.Hash,
+	sourceClient *rpc.Client,
+	txHash common.Hash,
+	opts *TxOptions,
+) (*types.Transaction, error) {
+	ctx, cancel := opts.withTimeout(ctx)
+	defer cancel()
+
+	args, err := BuildVerifyExecuteArgs(ctx, sourceClient, txHash)
+	if err != nil {
+		return nil, stageError(ctx, StageProve, err)
+	}
+	args.ChainID = chainId
+
+	return VerifyExecuteFromArgs(ctx, destClient, userKey, contract, toAddr, nil, args, opts)
 }
@@ -4,94 +4,222 @@ package contract
 import (
 	"context"
 	"crypto/ecdsa"
-	"log"
-	"os"
-	"regexp"
+	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	"github.com/ethereum/go-ethereum/common/compiler"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 // CompileAndDeployIon specific compile and deploy ion contract
+// The PatriciaTrie library is deployed first and its address linked into the
+// Ion bytecode. Both instances are sent on the first channel in deployment
+// order. Any failure is sent on the error channel and both channels are closed.
 func CompileAndDeployIon(
 	ctx context.Context,
 	client bind.ContractBackend,
 	userKey *ecdsa.PrivateKey,
 	chainID interface{},
-) <-chan ContractInstance {
+	opts *TxOptions,
+	compileOpts *CompileOptions,
+) (<-chan ContractInstance, <-chan error) {
+	opts = opts.withNonces()
+	resChan := make(chan ContractInstance)
+	errChan := make(chan error, 1)
+
+	ctx, cancel := opts.withTimeout(ctx)
+	fail := func(err error) (<-chan ContractInstance, <-chan error) {
+		cancel()
+		errChan <- err
+		close(errChan)
+		close(resChan)
+		return resChan, errChan
+	}
+	deployBackend, ok := client.(bind.DeployBackend)
+	if !ok {
+		return fail(fmt.Errorf("client can't wait for deployments to be mined"))
+	}
+
 	// ---------------------------------------------
 	// COMPILE ION AND DEPENDENCIES
 	// ---------------------------------------------
-	basePath := os.Getenv("GOPATH") + "/src/github.com/clearmatics/ion/contracts/"
+	basePath, err := contractsBasePath("Ion.sol", "libraries/PatriciaTrie.sol")
+	if err != nil {
+		return fail(err)
+	}
 	ionContractPath := basePath + "Ion.sol"
 
-	contracts, err := compiler.CompileSolidity("", ionContractPath)
+	contracts, err := Compile(compileOpts, ionContractPath)
+	if err != nil {
+		return fail(fmt.Errorf("failed to compile Ion.sol: %v", err))
+	}
+
+	patriciaTrieName := basePath + "libraries/PatriciaTrie.sol:PatriciaTrie"
+	patriciaTrieContract, err := compiledContract(contracts, patriciaTrieName)
+	if err != nil {
+		return fail(err)
+	}
+	patriciaTrieBinStr, patriciaTrieABIStr, err := getContractBytecodeAndABI(patriciaTrieContract)
 	if err != nil {
-		log.Fatal("ERROR failed to compile Ion.sol:", err)
+		return fail(err)
 	}
 
-	patriciaTrieContract := contracts[basePath+"libraries/PatriciaTrie.sol:PatriciaTrie"]
-	patriciaTrieBinStr, patriciaTrieABIStr := getContractBytecodeAndABI(patriciaTrieContract)
+	ionContract, err := compiledContract(contracts, ionContractPath+":Ion")
+	if err != nil {
+		return fail(err)
+	}
+	ionBinStr, ionABIStr, err := getContractBytecodeAndABI(ionContract)
+	if err != nil {
+		return fail(err)
+	}
 
-	ionContract := contracts[basePath+"Ion.sol:Ion"]
-	ionBinStr, ionABIStr := getContractBytecodeAndABI(ionContract)
+	if !hasLibraryPlaceholder(ionBinStr, patriciaTrieName) {
+		return fail(fmt.Errorf("PatriciaTrie placeholder not found in Ion bytecode"))
+	}
 
 	// ---------------------------------------------
 	// DEPLOY PATRICIA LIB ADDRESS
 	// ---------------------------------------------
-	patriciaTrieSignedTx := compileAndDeployContract(
+	if err := ctx.Err(); err != nil {
+		return fail(stageError(ctx, StageCompile, errCancelled(err)))
+	}
+	patriciaTrieSignedTx, err := compileAndDeployContract(
 		ctx,
 		client,
 		userKey,
 		patriciaTrieBinStr,
 		patriciaTrieABIStr,
 		nil,
-		uint64(3000000),
+		opts,
 	)
-
-	resChan := make(chan ContractInstance)
+	if err != nil {
+		return fail(stageError(ctx, StageSubmit, fmt.Errorf("failed to deploy PatriciaTrie library: %v", err)))
+	}
 
 	// Go-Routine that waits for PatriciaTrie Library and Ion Contract to be deployed
 	// Ion depends on PatriciaTrie library
 	go func() {
+		defer cancel()
+		defer close(errChan)
 		defer close(resChan)
-		deployBackend := client.(bind.DeployBackend)
 
 		// wait for PatriciaTrie library to be deployed
-		patriciaTrieAddr, err := bind.WaitDeployed(ctx, deployBackend, patriciaTrieSignedTx)
+		patriciaTrieAddr, patriciaTrieReceipt, err := waitDeployed(ctx, deployBackend, patriciaTrieSignedTx, "PatriciaTrie", opts)
 		if err != nil {
-			log.Fatal("ERROR while waiting for contract deployment")
+			errChan <- stageError(ctx, StageWait, err)
+			return
 		}
 
 		// ---------------------------------------------
 		// DEPLOY ION CONTRACT WITH PATRICIA LIB ADDRESS
 		// ---------------------------------------------
-		// replace palceholder with Prticia Trie Lib address
-		var re = regexp.MustCompile(`__.*__`)
-		ionBinStrWithLibAddr := re.ReplaceAllString(ionBinStr, patriciaTrieAddr.Hex()[2:])
-		ionSignedTx := compileAndDeployContract(
+		// replace placeholder with Patricia Trie Lib address
+		ionOpts := *opts
+		ionOpts.Libraries = map[string]common.Address{patriciaTrieName: patriciaTrieAddr}
+		for name, addr := range opts.Libraries {
+			ionOpts.Libraries[name] = addr
+		}
+		ionSignedTx, err := compileAndDeployContract(
 			ctx,
 			client,
 			userKey,
-			ionBinStrWithLibAddr,
+			ionBinStr,
 			ionABIStr,
 			nil,
-			uint64(3000000),
+			&ionOpts,
 			chainID,
 		)
+		if err != nil {
+			errChan <- stageError(ctx, StageSubmit, fmt.Errorf("failed to deploy Ion: %v", err))
+			return
+		}
 
 		// only stop blocking the first result after the Ion contract as been deploy
 		// this guarantees that it works well with the blockchain simulator Commit()
-		resChan <- ContractInstance{patriciaTrieContract, patriciaTrieAddr}
+		if !sendInstance(ctx, resChan, errChan, deployedInstance(client, patriciaTrieContract, patriciaTrieAddr, patriciaTrieSignedTx, patriciaTrieReceipt)) {
+			return
+		}
 
 		// wait for Ion to be deployed
-		ionAddr, err := bind.WaitDeployed(ctx, deployBackend, ionSignedTx)
+		ionAddr, ionReceipt, err := waitDeployed(ctx, deployBackend, ionSignedTx, "Ion", opts)
 		if err != nil {
-			log.Fatal("ERROR while waiting for contract deployment")
+			errChan <- stageError(ctx, StageWait, err)
+			return
 		}
 
-		resChan <- ContractInstance{ionContract, ionAddr}
+		sendInstance(ctx, resChan, errChan, deployedInstance(client, ionContract, ionAddr, ionSignedTx, ionReceipt))
 	}()
 
-	return resChan
+	return resChan, errChan
+}
+
+// ionBlocksABI is the ABI of the chain and block mappings of Ion.sol
+const ionBlocksABI = `[
+{"constant":true,"inputs":[{"name":"","type":"bytes32"}],"name":"m_chains","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"view","type":"function"},
+{"constant":true,"inputs":[{"name":"","type":"bytes32"}],"name":"m_blockhashes","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"view","type":"function"},
+{"constant":true,"inputs":[{"name":"","type":"bytes32"}],"name":"m_blockheaders","outputs":[{"name":"txRootHash","type":"bytes32"},{"name":"receiptRootHash","type":"bytes32"}],"payable":false,"stateMutability":"view","type":"function"}
+]`
+
+// ErrBlockNotStored is returned by GetStoredBlock for a block Ion doesn't have
+var ErrBlockNotStored = errors.New("block not stored by Ion")
+
+// StoredBlock is a block header as stored by the Ion contract, which keeps the
+// trie roots the proofs of verifyAndExecute are checked against
+type StoredBlock struct {
+	ChainID         common.Hash
+	BlockHash       common.Hash
+	TxRootHash      common.Hash
+	ReceiptRootHash common.Hash
+}
+
+// GetStoredBlock reads back the block blockHash of the chain chainID stored by
+// the Ion contract at ionAddr, e.g. to check a block was submitted before
+// paying for verifyAndExecute. It fails with ErrBlockNotStored when Ion
+// doesn't have the block. The number and parent of the block are only kept by
+// the validation contract.
+func GetStoredBlock(
+	ctx context.Context,
+	backend bind.ContractCaller,
+	ionAddr common.Address,
+	chainID common.Hash,
+	blockHash common.Hash,
+) (*StoredBlock, error) {
+	parsed, err := abi.JSON(strings.NewReader(ionBlocksABI))
+	if err != nil {
+		return nil, err
+	}
+	ion := bind.NewBoundContract(ionAddr, parsed, backend, nil, nil)
+	callOpts := &bind.CallOpts{Context: ctx}
+
+	var registered bool
+	if err := ion.Call(callOpts, &registered, "m_chains", chainID); err != nil {
+		return nil, fmt.Errorf("failed to check chain %s: %v", chainID.Hex(), err)
+	}
+	if !registered {
+		return nil, fmt.Errorf("chain %s is not registered with Ion", chainID.Hex())
+	}
+
+	var stored bool
+	if err := ion.Call(callOpts, &stored, "m_blockhashes", blockHash); err != nil {
+		return nil, fmt.Errorf("failed to check block %s: %v", blockHash.Hex(), err)
+	}
+	if !stored {
+		return nil, fmt.Errorf("block %s of chain %s: %w", blockHash.Hex(), chainID.Hex(), ErrBlockNotStored)
+	}
+
+	var header struct {
+		TxRootHash      [32]byte
+		ReceiptRootHash [32]byte
+	}
+	if err := ion.Call(callOpts, &header, "m_blockheaders", blockHash); err != nil {
+		return nil, fmt.Errorf("failed to get block %s: %v", blockHash.Hex(), err)
+	}
+	return &StoredBlock{
+		ChainID:         chainID,
+		BlockHash:       blockHash,
+		TxRootHash:      header.TxRootHash,
+		ReceiptRootHash: header.ReceiptRootHash,
+	}, nil
 }
@@ -0,0 +1,89 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// KeyFromKeystore decrypts the V3 keystore file at path with passphrase
+func KeyFromKeystore(path, passphrase string) (*ecdsa.PrivateKey, error) {
+	keyJSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore %q: %v", path, err)
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err == keystore.ErrDecrypt {
+		return nil, fmt.Errorf("wrong passphrase for keystore %q", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt keystore %q: %v", path, err)
+	}
+	return key.PrivateKey, nil
+}
+
+// KeystoreAccount is an encrypted keystore file signing the transactions in
+// place of a raw private key. It is decrypted once, on first use.
+type KeystoreAccount struct {
+	Path       string
+	Passphrase string
+
+	once sync.Once
+	key  *ecdsa.PrivateKey
+	err  error
+}
+
+// keystoreSigner signs with an account of a go-ethereum keystore
+type keystoreSigner struct {
+	ks         *keystore.KeyStore
+	account    accounts.Account
+	passphrase string
+}
+
+// SignerFromKeystore returns the AccountSigner of the V3 keystore file at
+// path. The key is decrypted with passphrase for every signature and dropped
+// right after by the go-ethereum keystore, so it is never held by the caller.
+func SignerFromKeystore(path, passphrase string) (AccountSigner, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve keystore %q: %v", path, err)
+	}
+
+	ks := keystore.NewKeyStore(filepath.Dir(absPath), keystore.StandardScryptN, keystore.StandardScryptP)
+	for _, account := range ks.Accounts() {
+		if account.URL.Path == absPath {
+			return &keystoreSigner{ks: ks, account: account, passphrase: passphrase}, nil
+		}
+	}
+	return nil, fmt.Errorf("no account found in keystore %q", path)
+}
+
+func (s *keystoreSigner) Address() common.Address {
+	return s.account.Address
+}
+
+func (s *keystoreSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signedTx, err := s.ks.SignTxWithPassphrase(s.account, s.passphrase, tx, chainID)
+	if err == keystore.ErrDecrypt {
+		return nil, fmt.Errorf("wrong passphrase for keystore %q", s.account.URL.Path)
+	}
+	return signedTx, err
+}
+
+// privateKey returns the decrypted key of the account
+func (account *KeystoreAccount) privateKey() (*ecdsa.PrivateKey, error) {
+	account.once.Do(func() {
+		account.key, account.err = KeyFromKeystore(account.Path, account.Passphrase)
+	})
+	return account.key, account.err
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testKeystore = "../config/UTC--2018-06-05T09-31-57.109288703Z--2be5ab0e43b6dc2908d5321cf318f35b80d0c10d"
+
+func Test_KeyFromKeystore(t *testing.T) {
+	key, err := KeyFromKeystore(testKeystore, "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(crypto.FromECDSA(key)) != "e176c157b5ae6413726c23094bb82198eb283030409624965231606ec0fbe65b" {
+		t.Fatal("ERROR unexpected private key decrypted")
+	}
+
+	if _, err := KeyFromKeystore(testKeystore, "wrong"); err == nil {
+		t.Fatal("ERROR expected wrong passphrase to fail")
+	}
+
+	dir, err := ioutil.TempDir("", "ion-keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	malformed := filepath.Join(dir, "malformed.json")
+	if err := ioutil.WriteFile(malformed, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := KeyFromKeystore(malformed, "password1"); err == nil {
+		t.Fatal("ERROR expected malformed keystore to fail")
+	}
+}
+
+func Test_SignerFromKeystore(t *testing.T) {
+	signer, err := SignerFromKeystore(testKeystore, "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.Address() != common.HexToAddress("0x2be5ab0e43b6dc2908d5321cf318f35b80d0c10d") {
+		t.Fatalf("ERROR unexpected keystore address %x", signer.Address())
+	}
+
+	chainID := big.NewInt(1)
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	signedTx, err := signer.SignTx(tx, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from, err := types.Sender(types.NewEIP155Signer(chainID), signedTx); err != nil || from != signer.Address() {
+		t.Fatal("ERROR expected the transaction to be signed by the keystore account")
+	}
+
+	signer, err = SignerFromKeystore(testKeystore, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := signer.SignTx(tx, chainID); err == nil {
+		t.Fatal("ERROR expected wrong passphrase to fail signing")
+	}
+}
+
+func Test_SignerFnSigner(t *testing.T) {
+	ctx := context.Background()
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key stays behind the signing function, like in an HSM
+	signatures := 0
+	signer := SignerFnSigner(from, func(signer types.Signer, addr common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		signatures++
+		return types.SignTx(tx, signer, key)
+	})
+
+	parsed, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"fire","inputs":[],"outputs":[]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := TransactionContractABI(ctx, blockchain, nil, parsed, common.HexToAddress("0x01"), nil, &TxOptions{Signer: signer}, "fire")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signatures != 1 {
+		t.Fatalf("ERROR expected the signing function to be called once, got %d", signatures)
+	}
+	sender, err := types.Sender(types.HomesteadSigner{}, tx)
+	if err != nil || sender != from {
+		t.Fatalf("ERROR expected the transaction to be sent by %s, got %s %v", from.Hex(), sender.Hex(), err)
+	}
+}
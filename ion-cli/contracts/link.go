@@ -0,0 +1,54 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// placeholderRegexp matches any link placeholder left in hex bytecode
+var placeholderRegexp = regexp.MustCompile(`__\$[0-9a-fA-F]{34}\$__|__.{36}__`)
+
+// LinkLibraries replaces the library placeholders in the hex bytecode bin with
+// the library addresses. libs is keyed by fully qualified library name, e.g.
+// "/path/to/libraries/PatriciaTrie.sol:PatriciaTrie". Both the legacy
+// __Name___ placeholders and the __$keccak$__ ones of solc >= 0.5 are linked.
+// It is an error for any placeholder to remain unresolved.
+func LinkLibraries(bin string, libs map[string]common.Address) (string, error) {
+	for name, addr := range libs {
+		addrHex := strings.TrimPrefix(strings.ToLower(addr.Hex()), "0x")
+		bin = strings.Replace(bin, libraryPlaceholder(name), addrHex, -1)
+		bin = strings.Replace(bin, libraryHashPlaceholder(name), addrHex, -1)
+	}
+
+	if unresolved := placeholderRegexp.FindString(bin); unresolved != "" {
+		return "", fmt.Errorf("unresolved library placeholder %s", unresolved)
+	}
+	return bin, nil
+}
+
+// hasLibraryPlaceholder reports whether bin links against the library name
+func hasLibraryPlaceholder(bin string, name string) bool {
+	return strings.Contains(bin, libraryPlaceholder(name)) || strings.Contains(bin, libraryHashPlaceholder(name))
+}
+
+// libraryPlaceholder returns the placeholder solc < 0.5 leaves in bytecode for
+// the address of a library, given its fully qualified name (path:Name). The
+// name is truncated or padded with underscores to 36 characters.
+func libraryPlaceholder(name string) string {
+	if len(name) > 36 {
+		name = name[:36]
+	}
+	return "__" + name + strings.Repeat("_", 38-len(name))
+}
+
+// libraryHashPlaceholder returns the placeholder solc >= 0.5 leaves in bytecode,
+// built from the first 34 hex characters of the keccak256 of the library name
+func libraryHashPlaceholder(name string) string {
+	hash := crypto.Keccak256Hash([]byte(name)).Hex()[2:]
+	return "__$" + hash[:34] + "$__"
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func Test_LibraryPlaceholder(t *testing.T) {
+	if p := libraryPlaceholder("Lib.sol:Lib"); p != "__Lib.sol:Lib___________________________" {
+		t.Fatalf("ERROR unexpected placeholder %q", p)
+	}
+	longName := "/home/user/contracts/libraries/PatriciaTrie.sol:PatriciaTrie"
+	if p := libraryPlaceholder(longName); p != "__"+longName[:36]+"__" || len(p) != 40 {
+		t.Fatalf("ERROR unexpected placeholder %q", p)
+	}
+	if p := libraryHashPlaceholder(longName); len(p) != 40 || !strings.HasPrefix(p, "__$") || !strings.HasSuffix(p, "$__") {
+		t.Fatalf("ERROR unexpected placeholder %q", p)
+	}
+}
+
+func Test_LinkLibraries(t *testing.T) {
+	name := "contracts/libraries/PatriciaTrie.sol:PatriciaTrie"
+	addr := common.HexToAddress("0x00000000000000000000000000000000000000AB")
+	linked := "6060" + "00000000000000000000000000000000000000ab" + "6060"
+
+	legacyBin := "6060" + libraryPlaceholder(name) + "6060"
+	bin, err := LinkLibraries(legacyBin, map[string]common.Address{name: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin != linked {
+		t.Fatalf("ERROR legacy placeholder linked to %s", bin)
+	}
+
+	hashBin := "6060" + libraryHashPlaceholder(name) + "6060"
+	bin, err = LinkLibraries(hashBin, map[string]common.Address{name: addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bin != linked {
+		t.Fatalf("ERROR hash placeholder linked to %s", bin)
+	}
+
+	if _, err := LinkLibraries(legacyBin, nil); err == nil {
+		t.Fatal("ERROR expected unresolved placeholder error")
+	}
+}
+
+func Test_TxOptionsLink(t *testing.T) {
+	name := "Lib.sol:Lib"
+	bin := "6060" + libraryPlaceholder(name) + "6060"
+
+	if _, err := (*TxOptions)(nil).link(bin); err == nil {
+		t.Fatal("ERROR expected unlinked bytecode to be rejected")
+	}
+
+	opts := &TxOptions{Libraries: map[string]common.Address{name: common.HexToAddress("0xab")}}
+	linked, err := opts.link(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked != "6060"+"00000000000000000000000000000000000000ab"+"6060" {
+		t.Fatalf("ERROR unexpected linked bytecode %s", linked)
+	}
+}
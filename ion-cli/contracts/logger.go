@@ -0,0 +1,82 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger receives the logs of deployments and transactions. The messages come
+// with alternating keys and values, like the sugared loggers of zap or the
+// fields of logrus, e.g. Warn("Gas estimation failed", "err", err).
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// packageLogger receives the logs not tied to a TxOptions, and those of
+// transactions without a Logger of their own
+var packageLogger Logger = StdLogger{}
+
+// SetLogger sets the Logger used when TxOptions.Logger is nil. Passing nil
+// restores the default StdLogger.
+func SetLogger(logger Logger) {
+	if logger == nil {
+		logger = StdLogger{}
+	}
+	packageLogger = logger
+}
+
+// StdLogger writes the logs with the standard log package, one line each,
+// e.g. "WARN Gas estimation failed err=...". Debug logs are dropped unless
+// Verbose is set.
+type StdLogger struct {
+	Verbose bool
+}
+
+// Debug implements Logger
+func (l StdLogger) Debug(msg string, keyvals ...interface{}) {
+	if l.Verbose {
+		l.print("DEBUG", msg, keyvals)
+	}
+}
+
+// Info implements Logger
+func (l StdLogger) Info(msg string, keyvals ...interface{}) { l.print("INFO", msg, keyvals) }
+
+// Warn implements Logger
+func (l StdLogger) Warn(msg string, keyvals ...interface{}) { l.print("WARN", msg, keyvals) }
+
+// Error implements Logger
+func (l StdLogger) Error(msg string, keyvals ...interface{}) { l.print("ERROR", msg, keyvals) }
+
+func (l StdLogger) print(level string, msg string, keyvals []interface{}) {
+	var line strings.Builder
+	line.WriteString(level + " " + msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			fmt.Fprintf(&line, " %v=%v", keyvals[i], keyvals[i+1])
+		} else {
+			fmt.Fprintf(&line, " %v", keyvals[i])
+		}
+	}
+	log.Print(line.String())
+}
+
+// NopLogger discards all logs, e.g. to silence tests
+type NopLogger struct{}
+
+// Debug implements Logger
+func (NopLogger) Debug(msg string, keyvals ...interface{}) {}
+
+// Info implements Logger
+func (NopLogger) Info(msg string, keyvals ...interface{}) {}
+
+// Warn implements Logger
+func (NopLogger) Warn(msg string, keyvals ...interface{}) {}
+
+// Error implements Logger
+func (NopLogger) Error(msg string, keyvals ...interface{}) {}
@@ -0,0 +1,94 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// DefaultDerivationPath is the BIP-44 path of the first Ethereum account, the
+// one MetaMask and Hardhat sign with by default
+const DefaultDerivationPath = "m/44'/60'/0'/0/0"
+
+// hardenedKeyStart is the index of the first BIP-32 hardened child key
+const hardenedKeyStart = 0x80000000
+
+// KeyFromMnemonic derives the private key at the BIP-32 derivation path, e.g.
+// DefaultDerivationPath, from the seed of the BIP-39 mnemonic, without a
+// passphrase. The keys match those of MetaMask and Hardhat for the same
+// mnemonic and path.
+func KeyFromMnemonic(mnemonic, path string) (*ecdsa.PrivateKey, error) {
+	words := strings.Fields(mnemonic)
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, fmt.Errorf("mnemonic has %d words, expected 12, 15, 18, 21 or 24", len(words))
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(words, " "), "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %v", err)
+	}
+
+	if !strings.HasPrefix(path, "m/") {
+		return nil, fmt.Errorf("invalid derivation path %q: must start with m/", path)
+	}
+	derivationPath, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid derivation path %q: %v", path, err)
+	}
+
+	key, chainCode, err := deriveChildKey([]byte("Bitcoin seed"), seed, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range derivationPath {
+		var data []byte
+		if index >= hardenedKeyStart {
+			data = append([]byte{0x00}, math.PaddedBigBytes(key, 32)...)
+		} else {
+			pub, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&pub.PublicKey)
+		}
+		data = append(data, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(data[len(data)-4:], index)
+
+		if key, chainCode, err = deriveChildKey(chainCode, data, key); err != nil {
+			return nil, err
+		}
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
+
+// deriveChildKey returns the BIP-32 key and chain code of the HMAC-SHA512 of
+// data keyed by hmacKey, added to the parent key unless deriving the master
+func deriveChildKey(hmacKey, data []byte, parent *big.Int) (*big.Int, []byte, error) {
+	mac := hmac.New(sha512.New, hmacKey)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	key := new(big.Int).SetBytes(sum[:32])
+	if key.Cmp(n) >= 0 {
+		return nil, nil, fmt.Errorf("derived an invalid key, try the next index")
+	}
+	if parent != nil {
+		key.Add(key, parent).Mod(key, n)
+	}
+	if key.Sign() == 0 {
+		return nil, nil, fmt.Errorf("derived an invalid key, try the next index")
+	}
+	return key, sum[32:], nil
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testMnemonic = "test test test test test test test test test test test junk"
+
+func Test_KeyFromMnemonic(t *testing.T) {
+	key, err := KeyFromMnemonic(testMnemonic, DefaultDerivationPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first Hardhat account
+	if crypto.PubkeyToAddress(key.PublicKey) != common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266") {
+		t.Fatalf("ERROR unexpected account %x", crypto.PubkeyToAddress(key.PublicKey))
+	}
+
+	key, err = KeyFromMnemonic(testMnemonic, "m/44'/60'/0'/0/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.PubkeyToAddress(key.PublicKey) != common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8") {
+		t.Fatalf("ERROR unexpected account %x", crypto.PubkeyToAddress(key.PublicKey))
+	}
+
+	if _, err := KeyFromMnemonic("test test test test test test test test test test test test", DefaultDerivationPath); err == nil {
+		t.Fatal("ERROR expected a bad checksum to fail")
+	}
+	if _, err := KeyFromMnemonic("test test test", DefaultDerivationPath); err == nil {
+		t.Fatal("ERROR expected a bad word count to fail")
+	}
+	if _, err := KeyFromMnemonic(testMnemonic, "m/44'/sixty'/0'"); err == nil {
+		t.Fatal("ERROR expected an invalid path to fail")
+	}
+}
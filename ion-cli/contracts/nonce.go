@@ -0,0 +1,52 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// NonceManager allocates consecutive nonces to the transactions sent from each
+// account, so transactions sent back to back don't reuse the pending nonce of
+// the backend. Share one between deployments from the same key through
+// TxOptions.Nonces. It is safe for concurrent use.
+type NonceManager struct {
+	mu     sync.Mutex
+	nonces map[common.Address]uint64
+}
+
+// NewNonceManager returns an empty NonceManager
+func NewNonceManager() *NonceManager {
+	return &NonceManager{nonces: make(map[common.Address]uint64)}
+}
+
+// Next returns the nonce of the next transaction from addr. The first nonce of
+// an account is its pending nonce on backend, later ones are incremented locally.
+func (m *NonceManager) Next(ctx context.Context, backend bind.ContractTransactor, addr common.Address) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nonce, ok := m.nonces[addr]
+	if !ok {
+		pending, err := backend.PendingNonceAt(ctx, addr)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get pending nonce: %v", err)
+		}
+		nonce = pending
+	}
+	m.nonces[addr] = nonce + 1
+	return nonce, nil
+}
+
+// Reset drops the local nonce of addr, so the next one is read from the
+// backend again, e.g. to recover after transactions were dropped or sent from
+// the same account by other means. Abandoned sends reset the nonce by themselves.
+func (m *NonceManager) Reset(addr common.Address) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.nonces, addr)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func Test_NonceManager(t *testing.T) {
+	ctx := context.Background()
+	userKey, _ := crypto.GenerateKey()
+	alloc := make(core.GenesisAlloc)
+	alloc[crypto.PubkeyToAddress(userKey.PublicKey)] = core.GenesisAccount{
+		Balance: big.NewInt(1000000000),
+	}
+	blockchain := backends.NewSimulatedBackend(alloc)
+
+	binStr, abiStr := compileTrigger(t)
+
+	// deploy twice in a row without mining in between
+	opts := &TxOptions{Nonces: NewNonceManager()}
+	firstTx, err := compileAndDeployContract(ctx, blockchain, userKey, binStr, abiStr, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondTx, err := compileAndDeployContract(ctx, blockchain, userKey, binStr, abiStr, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if firstTx.Nonce() != 0 || secondTx.Nonce() != firstTx.Nonce()+1 {
+		t.Fatalf("ERROR expected consecutive nonces, got %d and %d", firstTx.Nonce(), secondTx.Nonce())
+	}
+
+	// after a reset the nonce is read from the backend again
+	opts.Nonces.Reset(crypto.PubkeyToAddress(userKey.PublicKey))
+	thirdTx, err := compileAndDeployContract(ctx, blockchain, userKey, binStr, abiStr, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thirdTx.Nonce() != secondTx.Nonce()+1 {
+		t.Fatalf("ERROR expected pending nonce %d after reset, got %d", secondTx.Nonce()+1, thirdTx.Nonce())
+	}
+}
+
+// failingSendBackend fails to send the first transactions with err, and
+// counts the pending nonces read
+type failingSendBackend struct {
+	*backends.SimulatedBackend
+	failures      int
+	err           error
+	pendingNonces int
+}
+
+func (b *failingSendBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if b.failures > 0 {
+		b.failures--
+		return b.err
+	}
+	return b.SimulatedBackend.SendTransaction(ctx, tx)
+}
+
+func (b *failingSendBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	b.pendingNonces++
+	return b.SimulatedBackend.PendingNonceAt(ctx, account)
+}
+
+func Test_NonceKeptOnResend(t *testing.T) {
+	ctx := context.Background()
+	userKey, _ := crypto.GenerateKey()
+	userAddr := crypto.PubkeyToAddress(userKey.PublicKey)
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retry := &RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	// a transient failure sends the same transaction again, keeping its nonce
+	backend := &failingSendBackend{SimulatedBackend: blockchain, failures: 1, err: errors.New("connection reset by peer")}
+	opts := &TxOptions{Nonces: NewNonceManager(), Retry: retry}
+	tx, err := signAndSend(ctx, backend, PrivateKeySigner(userKey), nil, nil, opts, []byte{0x60, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := opts.Nonces.nonces[userAddr]; next != tx.Nonce()+1 || backend.pendingNonces != 1 {
+		t.Fatalf("ERROR expected the nonce kept after a resend, next %d after %d pending nonce reads", next, backend.pendingNonces)
+	}
+
+	// an abandoned transaction releases its nonce
+	backend.failures, backend.err = 1, errors.New("insufficient funds for gas * price + value")
+	if _, err := signAndSend(ctx, backend, PrivateKeySigner(userKey), nil, nil, opts, []byte{0x60, 0x00}); err == nil {
+		t.Fatal("ERROR expected the permanent failure to be reported")
+	}
+	if _, ok := opts.Nonces.nonces[userAddr]; ok {
+		t.Fatal("ERROR expected the nonce of the abandoned transaction to be released")
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultGasLimit is the gas limit used when estimating the gas of a transaction fails
+const DefaultGasLimit = uint64(3000000)
+
+// DefaultGasMultiplier is the safety margin applied to gas estimates when none is given
+const DefaultGasMultiplier = 1.2
+
+// GasEstimation selects how the gas limit of a transaction is chosen
+type GasEstimation int
+
+const (
+	// GasFixed uses TxOptions.GasLimit. When it is zero the gas is estimated
+	// and scaled by TxOptions.GasMultiplier.
+	GasFixed GasEstimation = iota
+	// GasEstimate always estimates the gas against the backend
+	GasEstimate
+	// GasEstimateWithMultiplier estimates the gas and scales it by TxOptions.GasMultiplier
+	GasEstimateWithMultiplier
+)
+
+// CompileOptions holds the optional settings of Solidity compilation.
+// A nil *CompileOptions compiles with the solc found on PATH.
+type CompileOptions struct {
+	// SolcPath of the solc binary, "solc" when empty
+	SolcPath string
+	// SolcVersion the binary must report, e.g. "0.4.24". Any version is accepted when empty.
+	SolcVersion string
+	// DisableOptimizer compiles without the solc optimizer, which is on by default
+	DisableOptimizer bool
+	// OptimizeRuns tunes the optimizer for the number of times the code is
+	// expected to run, the solc default when zero
+	OptimizeRuns int
+	// EVMVersion targeted, e.g. "byzantium", the solc default when empty
+	EVMVersion string
+	// StandardJSON compiles through the solc standard-JSON interface, see
+	// CompileStandardJSON
+	StandardJSON bool
+	// Remappings of import paths in the standard-JSON mode, e.g.
+	// "openzeppelin-solidity/=node_modules/openzeppelin-solidity/"
+	Remappings []string
+}
+
+// solc returns the selected solc binary, checking it is the requested version
+func (opts *CompileOptions) solc() (*compiler.Solidity, error) {
+	if opts == nil {
+		opts = &CompileOptions{}
+	}
+	solc, err := solidityVersion(opts.SolcPath)
+	if err != nil {
+		return nil, err
+	}
+	if want := strings.TrimPrefix(opts.SolcVersion, "v"); want != "" && want != solc.Version {
+		return nil, fmt.Errorf("solc %s is version %s, expected %s", solc.Path, solc.Version, want)
+	}
+	return solc, nil
+}
+
+// TxOptions holds the optional settings of deployments and transactions.
+// A nil *TxOptions, or any zero field, falls back to the defaults.
+type TxOptions struct {
+	// GasLimit of every transaction sent. When zero the gas is estimated
+	// against the backend, falling back to DefaultGasLimit if that fails.
+	// Deployments are estimated the same way as EstimateDeployGas.
+	GasLimit uint64
+	// GasEstimation mode, GasFixed by default
+	GasEstimation GasEstimation
+	// GasMultiplier applied to gas estimates, DefaultGasMultiplier when zero
+	GasMultiplier float64
+	// GasPriceOracle prices the transactions in place of the gas price the
+	// backend suggests, e.g. from a percentile of the recent blocks
+	GasPriceOracle func(ctx context.Context) (*big.Int, error)
+	// GasPriceCap fails the transactions priced above it with
+	// ErrGasPriceAboveCap rather than sending them. There is no cap when nil.
+	GasPriceCap *big.Int
+	// Nonces allocates the transaction nonces when set, otherwise the pending
+	// nonce of the backend is used. The deployment pipelines use a NonceManager
+	// of their own when none is given.
+	Nonces *NonceManager
+	// OnSubmit is called with every transaction as soon as it is sent, before it
+	// is mined, e.g. to show the pending deployment hashes
+	OnSubmit func(tx *types.Transaction)
+	// OnProgress is called as a deployment pipeline moves through its steps,
+	// with one of the Progress stages and the artifacts, transaction or
+	// instance of the step as detail, e.g. to render the progress in a CLI.
+	// It is called from the goroutine of the pipeline.
+	OnProgress func(stage string, detail interface{})
+	// Retry of sending transactions and waiting for deployments on transient
+	// RPC errors. Every call is attempted once when nil.
+	Retry *RetryOptions
+	// DryRun builds and signs the transactions and logs them instead of sending
+	// them. Deployments report the address the contract would be created at.
+	DryRun bool
+	// Keystore signs the transactions when the key passed is nil, so the
+	// caller never handles the decrypted key
+	Keystore *KeystoreAccount
+	// Signer signs the transactions when the key passed is nil, e.g. an HSM or
+	// remote KMS through SignerFnSigner. It takes precedence over Keystore.
+	Signer AccountSigner
+	// ChainID of the destination network. When set the transactions are
+	// signed for it with EIP-155 replay protection, and sending fails if the
+	// backend reports another chain id. Transactions are signed without a
+	// chain id when nil.
+	ChainID *big.Int
+	// Logger of the deployments and transactions, the one given to SetLogger
+	// when nil
+	Logger Logger
+	// Libraries linked into the bytecode of deployments, by fully qualified
+	// name, see LinkLibraries
+	Libraries map[string]common.Address
+	// Confirmations a deployment is waited for, the blocks mined on top of
+	// it, see WaitConfirmations. Deployments are trusted once mined when zero.
+	Confirmations uint64
+	// Timeout bounds a deployment pipeline or verifyAndExecute call as a
+	// whole when positive, on top of any deadline of the context passed. An
+	// operation running out of time fails with a TimeoutError naming its stage.
+	Timeout time.Duration
+}
+
+// link links the Libraries into the hex bytecode bin of a deployment
+func (opts *TxOptions) link(bin string) (string, error) {
+	var libs map[string]common.Address
+	if opts != nil {
+		libs = opts.Libraries
+	}
+	linked, err := LinkLibraries(bin, libs)
+	if err != nil {
+		return "", fmt.Errorf("failed to link libraries: %v", err)
+	}
+	return linked, nil
+}
+
+// withTimeout derives the context of an operation bounded by Timeout
+func (opts *TxOptions) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if opts != nil && opts.Timeout > 0 {
+		return context.WithTimeout(ctx, opts.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// logger returns the configured Logger
+func (opts *TxOptions) logger() Logger {
+	if opts != nil && opts.Logger != nil {
+		return opts.Logger
+	}
+	return packageLogger
+}
+
+// chainIDReader is implemented by backends reporting their chain id directly
+type chainIDReader interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// chainID returns the chain id the transactions are signed for, nil when none
+func (opts *TxOptions) chainID() *big.Int {
+	if opts != nil {
+		return opts.ChainID
+	}
+	return nil
+}
+
+// signer returns the signer of the transactions
+func (opts *TxOptions) signer() types.Signer {
+	return chainSigner(opts.chainID())
+}
+
+// backendChainID returns the chain id of backend, asked with eth_chainId or
+// else net_version over the RPC client of the backends of DialBackend. It is
+// nil for backends which can't report it, like the simulated one.
+func backendChainID(ctx context.Context, backend interface{}) (*big.Int, error) {
+	if reader, ok := backend.(chainIDReader); ok {
+		return reader.ChainID(ctx)
+	}
+	client, ok := backend.(rpcClientBackend)
+	if !ok {
+		return nil, nil
+	}
+	chainID, _, err := utils.DetectChainID(ctx, client.RPCClient())
+	if err == nil {
+		return chainID, nil
+	}
+	// nodes predating eth_chainId report the network id instead
+	var version string
+	if netErr := client.RPCClient().CallContext(ctx, &version, "net_version"); netErr != nil {
+		return nil, fmt.Errorf("%v, net_version: %v", err, netErr)
+	}
+	networkID, ok := new(big.Int).SetString(version, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid network id %q", version)
+	}
+	return networkID, nil
+}
+
+// checkChainID fails when the backend is on another network than the ChainID
+// the transactions are signed for. Backends which can't report their chain id
+// are not checked, see backendChainID.
+func (opts *TxOptions) checkChainID(ctx context.Context, backend interface{}) error {
+	if opts == nil || opts.ChainID == nil {
+		return nil
+	}
+	chainID, err := backendChainID(ctx, backend)
+	if err != nil {
+		return fmt.Errorf("failed to get backend chain id: %v", err)
+	}
+	if chainID == nil {
+		opts.logger().Debug("Backend can't report its chain id, not checked", "chainId", opts.ChainID)
+		return nil
+	}
+	if chainID.Cmp(opts.ChainID) != 0 {
+		return fmt.Errorf("destination network mismatch: backend is on chain %v, transactions are signed for chain %v", chainID, opts.ChainID)
+	}
+	return nil
+}
+
+// account returns the signer of userKey, or the Signer or keystore account
+// of opts when it is nil
+func (opts *TxOptions) account(userKey *ecdsa.PrivateKey) (AccountSigner, error) {
+	if userKey != nil {
+		return PrivateKeySigner(userKey), nil
+	}
+	if opts != nil && opts.Signer != nil {
+		return opts.Signer, nil
+	}
+	if opts == nil || opts.Keystore == nil {
+		return nil, fmt.Errorf("no private key, signer or keystore account given")
+	}
+	key, err := opts.Keystore.privateKey()
+	if err != nil {
+		return nil, err
+	}
+	return PrivateKeySigner(key), nil
+}
+
+// confirmations returns the number of blocks deployments are waited for
+func (opts *TxOptions) confirmations() uint64 {
+	if opts != nil {
+		return opts.Confirmations
+	}
+	return 0
+}
+
+// dryRun reports whether transactions are only logged
+func (opts *TxOptions) dryRun() bool {
+	return opts != nil && opts.DryRun
+}
+
+// nonce returns the nonce of the next transaction from addr
+func (opts *TxOptions) nonce(ctx context.Context, backend bind.ContractTransactor, addr common.Address) (uint64, error) {
+	if opts != nil && opts.Nonces != nil {
+		return opts.Nonces.Next(ctx, backend, addr)
+	}
+	nonce, err := backend.PendingNonceAt(ctx, addr)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pending nonce: %v", err)
+	}
+	return nonce, nil
+}
+
+// unsent releases the nonce of a transaction from addr that failed to send
+func (opts *TxOptions) unsent(addr common.Address) {
+	if opts != nil && opts.Nonces != nil {
+		opts.Nonces.Reset(addr)
+	}
+}
+
+// withNonces returns a copy of opts using a new NonceManager if it has none,
+// so the transactions of a pipeline get consecutive nonces
+func (opts *TxOptions) withNonces() *TxOptions {
+	if opts != nil && opts.Nonces != nil {
+		return opts
+	}
+	withNonces := TxOptions{}
+	if opts != nil {
+		withNonces = *opts
+	}
+	withNonces.Nonces = NewNonceManager()
+	return &withNonces
+}
+
+// submitted calls the OnSubmit callback, if any
+func (opts *TxOptions) submitted(tx *types.Transaction) {
+	if opts != nil && opts.OnSubmit != nil {
+		opts.OnSubmit(tx)
+	}
+}
+
+// progress calls the OnProgress callback, if any
+func (opts *TxOptions) progress(stage string, detail interface{}) {
+	if opts != nil && opts.OnProgress != nil {
+		opts.OnProgress(stage, detail)
+	}
+}
+
+// EstimateDeployGas estimates the gas needed to deploy the contract binary
+// binStr with the constructor arguments packed against abiStr
+func EstimateDeployGas(
+	ctx context.Context,
+	backend bind.ContractBackend,
+	binStr string,
+	abiStr string,
+	constructorArgs ...interface{},
+) (uint64, error) {
+	payload, err := generateContractPayload(binStr, abiStr, constructorArgs...)
+	if err != nil {
+		return 0, err
+	}
+	return estimateGas(ctx, backend, ethereum.CallMsg{Data: payload})
+}
+
+// ErrGasPriceAboveCap is returned for a transaction priced above
+// TxOptions.GasPriceCap
+var ErrGasPriceAboveCap = errors.New("gas price above cap")
+
+// gasPrice returns the price of a transaction from the GasPriceOracle, or
+// the price the backend suggests, checked against the GasPriceCap
+func (opts *TxOptions) gasPrice(ctx context.Context, backend bind.ContractTransactor) (*big.Int, error) {
+	var price *big.Int
+	if opts != nil && opts.GasPriceOracle != nil {
+		oraclePrice, err := opts.GasPriceOracle(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("gas price oracle failed: %v", err)
+		}
+		if oraclePrice == nil {
+			return nil, fmt.Errorf("gas price oracle returned no price")
+		}
+		price = oraclePrice
+	} else {
+		suggested, err := backend.SuggestGasPrice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to suggest gas price: %v", err)
+		}
+		price = suggested
+	}
+
+	if opts != nil && opts.GasPriceCap != nil && price.Cmp(opts.GasPriceCap) > 0 {
+		return nil, fmt.Errorf("gas price %v exceeds the cap of %v: %w", price, opts.GasPriceCap, ErrGasPriceAboveCap)
+	}
+	return price, nil
+}
+
+func estimateGas(ctx context.Context, backend bind.ContractBackend, msg ethereum.CallMsg) (uint64, error) {
+	gas, err := backend.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to estimate gas: %v", err)
+	}
+	return gas, nil
+}
+
+// gasLimit returns the gas limit for msg according to the estimation mode.
+// If estimation fails the fixed limit is used and a warning is logged.
+func (opts *TxOptions) gasLimit(ctx context.Context, backend bind.ContractBackend, msg ethereum.CallMsg) uint64 {
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	if opts.GasEstimation == GasFixed && opts.GasLimit != 0 {
+		return opts.GasLimit
+	}
+
+	fixed := opts.GasLimit
+	if fixed == 0 {
+		fixed = DefaultGasLimit
+	}
+
+	gas, err := estimateGas(ctx, backend, msg)
+	if err != nil {
+		opts.logger().Warn("Gas estimation failed, using the fixed gas limit", "gas", fixed, "err", err)
+		return fixed
+	}
+
+	// estimates made in place of a missing fixed limit get the safety margin too
+	if opts.GasEstimation == GasEstimateWithMultiplier || opts.GasEstimation == GasFixed {
+		multiplier := opts.GasMultiplier
+		if multiplier == 0 {
+			multiplier = DefaultGasMultiplier
+		}
+		gas = uint64(float64(gas) * multiplier)
+	}
+	return gas
+}
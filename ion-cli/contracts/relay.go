@@ -0,0 +1,171 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RelayConfig holds the contracts and account RelayTriggers relays with
+type RelayConfig struct {
+	// Key signing the destination transactions
+	Key *ecdsa.PrivateKey
+	// ChainID the source chain is registered with in the validation contract
+	ChainID common.Hash
+	// Trigger contract watched on the source chain
+	Trigger common.Address
+	// TriggerABI decodes the trigger events, see WatchTriggerEvents
+	TriggerABI abi.ABI
+	// Validation contract of the source chain on the destination chain
+	Validation *compiler.Contract
+	// ValidationAddr is the address of the validation contract
+	ValidationAddr common.Address
+	// Engine of the source chain, which the headers are encoded for
+	Engine utils.ConsensusType
+	// Function consumer contract on the destination chain
+	Function *compiler.Contract
+	// FunctionAddr is the address of the consumer contract
+	FunctionAddr common.Address
+	// FromBlock resumes relaying from the trigger events of this block of the
+	// source chain. Only the events emitted from now are relayed when nil.
+	FromBlock *big.Int
+	// OnRelay is called with every event relayed and its verifyAndExecute
+	// transaction, e.g. to record the block to resume from
+	OnRelay func(event TriggerEvent, tx *types.Transaction)
+	// Opts of the block submissions and verifyAndExecute transactions,
+	// defaults when nil. In dry-run mode nothing is sent or waited for.
+	Opts *TxOptions
+}
+
+// RelayTriggers relays the trigger events of the source chain to the consumer
+// contract of the destination chain until ctx is done. For every event the
+// block including it is submitted to the validation contract, along with the
+// blocks between it and the last one submitted, then the transaction and
+// receipt proofs are generated and verifyAndExecute is called. Every
+// transaction is waited for, so a revert stops the relay with its reason.
+// Events are relayed once, even when seen again while catching up. It returns
+// the error of the first event that fails to relay, or ctx.Err() once done.
+func RelayTriggers(ctx context.Context, srcBackend *rpc.Client, dstBackend bind.ContractBackend, cfg RelayConfig) error {
+	dst, ok := dstBackend.(MinedBackend)
+	if !ok {
+		return fmt.Errorf("destination backend can't wait for transactions to be mined")
+	}
+	if err := checkConsensus(cfg.Validation, cfg.Engine); err != nil {
+		return err
+	}
+	src := ethclient.NewClient(srcBackend)
+	logger := cfg.Opts.logger()
+
+	// the watch is started first so no event is missed while catching up
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	eventChan, errChan := WatchTriggerEvents(watchCtx, src, cfg.Trigger, cfg.TriggerABI)
+
+	relayed := make(map[logKey]bool)
+	relay := func(event TriggerEvent) error {
+		key := logKey{event.TxHash, event.Log.Index}
+		if relayed[key] {
+			return nil
+		}
+		if err := submitBlocks(ctx, src, dstBackend, dst, cfg, event.BlockHash); err != nil {
+			return fmt.Errorf("failed to submit block %s: %v", event.BlockHash.Hex(), err)
+		}
+
+		tx, err := VerifyExecuteFromTx(ctx, dstBackend, cfg.Key, cfg.Function, cfg.FunctionAddr, cfg.ChainID, srcBackend, event.TxHash, cfg.Opts)
+		if err != nil {
+			return fmt.Errorf("failed to relay trigger transaction %s: %v", event.TxHash.Hex(), err)
+		}
+		if !cfg.Opts.dryRun() {
+			if _, err := WaitMined(ctx, dst, tx); err != nil {
+				return fmt.Errorf("verifyAndExecute of trigger transaction %s failed: %w", event.TxHash.Hex(), err)
+			}
+		}
+
+		relayed[key] = true
+		logger.Info("Trigger event relayed", "block", event.BlockNumber, "trigger", event.TxHash.Hex(), "tx", tx.Hash().Hex())
+		if cfg.OnRelay != nil {
+			cfg.OnRelay(event, tx)
+		}
+		return nil
+	}
+
+	if cfg.FromBlock != nil {
+		missed, err := GetTriggerEvents(ctx, src, cfg.Trigger, cfg.FromBlock, nil)
+		if err != nil {
+			return err
+		}
+		for _, event := range missed {
+			if err := relay(event); err != nil {
+				return err
+			}
+		}
+	}
+
+	for event := range eventChan {
+		if err := relay(event); err != nil {
+			return err
+		}
+	}
+	if err := <-errChan; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// submitBlocks submits the block blockHash of the source chain to the
+// validation contract, after the blocks between it and the last one submitted
+// since the contract only accepts children of the blocks it knows
+func submitBlocks(ctx context.Context, src *ethclient.Client, dstBackend bind.ContractBackend, dst MinedBackend, cfg RelayConfig, blockHash common.Hash) error {
+	var headers []*types.Header
+	hash := blockHash
+	for {
+		submitted, err := IsBlockSubmitted(ctx, dstBackend, cfg.ValidationAddr, cfg.ChainID, hash)
+		if err != nil {
+			return err
+		}
+		if submitted {
+			break
+		}
+
+		header, err := src.HeaderByHash(ctx, hash)
+		if err != nil {
+			return fmt.Errorf("failed to get header %s: %v", hash.Hex(), err)
+		}
+		if header.Number.Sign() == 0 {
+			return fmt.Errorf("chain %s is not registered with the validation contract", cfg.ChainID.Hex())
+		}
+		headers = append(headers, header)
+		hash = header.ParentHash
+	}
+
+	// parents first
+	for i := len(headers) - 1; i >= 0; i-- {
+		// another relayer may have submitted the block meanwhile
+		tx, err := SubmitBlock(ctx, dstBackend, cfg.Key, cfg.Validation, cfg.ValidationAddr, cfg.ChainID, headers[i], cfg.Engine, &SubmitBlockOptions{Tx: cfg.Opts, CheckSubmitted: true})
+		if err == ErrBlockAlreadySubmitted {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		// a dry run only logs the submission
+		if cfg.Opts.dryRun() {
+			continue
+		}
+		if _, err := WaitMined(ctx, dst, tx); err != nil {
+			return fmt.Errorf("block %v rejected: %w", headers[i].Number, err)
+		}
+	}
+	return nil
+}
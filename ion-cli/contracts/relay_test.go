@@ -0,0 +1,260 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// SourceService serves the eth namespace calls of the relay for a generated
+// source chain, without subscriptions like an HTTP endpoint
+type SourceService struct {
+	blocks   []*types.Block
+	receipts []types.Receipts
+}
+
+// jsonFields returns the JSON object of v as a map, to add fields to it
+func jsonFields(v interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	return fields, json.Unmarshal(encoded, &fields)
+}
+
+func (s *SourceService) txJSON(block *types.Block, idx int) (map[string]interface{}, error) {
+	tx := block.Transactions()[idx]
+	fields, err := jsonFields(tx)
+	if err != nil {
+		return nil, err
+	}
+	from, err := types.Sender(types.HomesteadSigner{}, tx)
+	if err != nil {
+		return nil, err
+	}
+	fields["from"] = from
+	fields["blockHash"] = block.Hash()
+	fields["blockNumber"] = hexutil.EncodeBig(block.Number())
+	fields["transactionIndex"] = hexutil.Uint(idx)
+	return fields, nil
+}
+
+func (s *SourceService) blockJSON(block *types.Block, full bool) (map[string]interface{}, error) {
+	if block == nil {
+		return nil, nil
+	}
+	fields, err := jsonFields(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]interface{}, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		if !full {
+			txs[i] = tx.Hash()
+		} else if txs[i], err = s.txJSON(block, i); err != nil {
+			return nil, err
+		}
+	}
+	fields["hash"] = block.Hash()
+	fields["transactions"] = txs
+	fields["uncles"] = []common.Hash{}
+	return fields, nil
+}
+
+func (s *SourceService) GetBlockByNumber(number rpc.BlockNumber, full bool) (map[string]interface{}, error) {
+	if number < 0 {
+		number = rpc.BlockNumber(len(s.blocks) - 1)
+	}
+	if int(number) >= len(s.blocks) {
+		return nil, nil
+	}
+	return s.blockJSON(s.blocks[number], full)
+}
+
+func (s *SourceService) GetBlockByHash(hash common.Hash, full bool) (map[string]interface{}, error) {
+	for _, block := range s.blocks {
+		if block.Hash() == hash {
+			return s.blockJSON(block, full)
+		}
+	}
+	return nil, nil
+}
+
+func (s *SourceService) GetTransactionByHash(hash common.Hash) (map[string]interface{}, error) {
+	for _, block := range s.blocks {
+		for i, tx := range block.Transactions() {
+			if tx.Hash() == hash {
+				return s.txJSON(block, i)
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *SourceService) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	for _, receipts := range s.receipts {
+		for _, receipt := range receipts {
+			if receipt.TxHash == hash {
+				return receipt, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (s *SourceService) GetLogs(crit filters.FilterCriteria) ([]types.Log, error) {
+	head := uint64(len(s.blocks) - 1)
+	from, to := uint64(0), head
+	if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 {
+		from = crit.FromBlock.Uint64()
+	}
+	if crit.ToBlock != nil && crit.ToBlock.Sign() >= 0 && crit.ToBlock.Uint64() < head {
+		to = crit.ToBlock.Uint64()
+	}
+
+	logs := []types.Log{}
+	for number := from; number <= to; number++ {
+		for _, receipt := range s.receipts[number] {
+			for _, vlog := range receipt.Logs {
+				if len(crit.Topics) > 0 && len(crit.Topics[0]) > 0 && crit.Topics[0][0] != vlog.Topics[0] {
+					continue
+				}
+				if len(crit.Addresses) > 0 && crit.Addresses[0] != vlog.Address {
+					continue
+				}
+				logs = append(logs, *vlog)
+			}
+		}
+	}
+	return logs, nil
+}
+
+func (s *SourceService) Logs(ctx context.Context, crit filters.FilterCriteria) (*rpc.Subscription, error) {
+	return nil, errors.New("notifications not supported")
+}
+
+// deployCode returns the init code deploying runtime
+func deployCode(runtime []byte) []byte {
+	size := byte(len(runtime))
+	return append([]byte{0x60, size, 0x60, 0x0c, 0x60, 0x00, 0x39, 0x60, size, 0x60, 0x00, 0xf3}, runtime...)
+}
+
+func Test_RelayTriggersDryRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// source chain: the trigger is deployed in block 1 and called in block 2
+	userKey, _ := crypto.GenerateKey()
+	userAddr := crypto.PubkeyToAddress(userKey.PublicKey)
+	db := ethdb.NewMemDatabase()
+	gspec := core.Genesis{Config: params.AllEthashProtocolChanges, Alloc: core.GenesisAlloc{userAddr: {Balance: DefaultSimBalance}}}
+	genesis := gspec.MustCommit(db)
+
+	// CALLER, MSTORE at 0, then LOG1 of the 32 bytes with the Triggered topic
+	trigger := append([]byte{0x33, 0x60, 0x00, 0x52, 0x7f}, triggeredTopic.Bytes()...)
+	trigger = append(trigger, 0x60, 0x20, 0x60, 0x00, 0xa1, 0x00)
+	triggerAddr := crypto.CreateAddress(userAddr, 0)
+	deployTx, _ := types.SignTx(types.NewContractCreation(0, big.NewInt(0), 100000, big.NewInt(1), deployCode(trigger)), types.HomesteadSigner{}, userKey)
+	triggerTx, _ := types.SignTx(types.NewTransaction(1, triggerAddr, big.NewInt(0), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, userKey)
+
+	blocks, receipts := core.GenerateChain(gspec.Config, genesis, ethash.NewFaker(), db, 2, func(i int, gen *core.BlockGen) {
+		// room for a clique seal
+		gen.SetExtra(make([]byte, 32+utils.CliqueSealLength))
+		if i == 0 {
+			gen.AddTx(deployTx)
+		} else {
+			gen.AddTx(triggerTx)
+		}
+	})
+	source := &SourceService{blocks: append([]*types.Block{genesis}, blocks...), receipts: append([]types.Receipts{nil}, receipts...)}
+	for i, block := range source.blocks {
+		for _, receipt := range source.receipts[i] {
+			for _, vlog := range receipt.Logs {
+				vlog.BlockHash = block.Hash()
+			}
+		}
+	}
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", source); err != nil {
+		t.Fatal(err)
+	}
+	srcClient := rpc.DialInProc(server)
+	defer srcClient.Close()
+
+	// destination chain: a validation contract which only knows the genesis
+	// block, m_blockhashes(chainId, hash) returning hash == genesis
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validation := append([]byte{0x60, 0x24, 0x35, 0x7f}, genesis.Hash().Bytes()...)
+	validation = append(validation, 0x14, 0x60, 0x00, 0x52, 0x60, 0x20, 0x60, 0x00, 0xf3)
+	validationABI := `[{"type":"function","name":"SubmitBlock","inputs":[{"name":"_id","type":"bytes32"},{"name":"_rlpBlockHeader","type":"bytes"},{"name":"_rlpSignedBlockHeader","type":"bytes"}]}]`
+	contractChan, errChan := DeployPrecompiled(ctx, blockchain, userKey, validationABI, hex.EncodeToString(deployCode(validation)), nil)
+	blockchain.Commit()
+	validationInstance, ok := <-contractChan
+	if !ok {
+		t.Fatal("ERROR deploying validation stub: ", <-errChan)
+	}
+
+	function, err := precompiledContract(`[{"type":"function","name":"verifyAndExecute","inputs":[
+		{"name":"_chainId","type":"bytes32"},{"name":"_blockHash","type":"bytes32"},{"name":"_contractEmittedAddress","type":"address"},
+		{"name":"_path","type":"bytes"},{"name":"_tx","type":"bytes"},{"name":"_txNodes","type":"bytes"},
+		{"name":"_receipt","type":"bytes"},{"name":"_receiptNodes","type":"bytes"},{"name":"_expectedAddress","type":"address"}]}]`, "6060")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var relayed []TriggerEvent
+	cfg := RelayConfig{
+		ChainID:        crypto.Keccak256Hash([]byte("source")),
+		Trigger:        triggerAddr,
+		Validation:     validationInstance.Contract,
+		ValidationAddr: validationInstance.Address,
+		Engine:         utils.Clique,
+		Function:       function,
+		FunctionAddr:   validationInstance.Address,
+		FromBlock:      big.NewInt(0),
+		OnRelay: func(event TriggerEvent, tx *types.Transaction) {
+			relayed = append(relayed, event)
+			cancel()
+		},
+		// the key is only known to the signer
+		Opts: &TxOptions{DryRun: true, Signer: PrivateKeySigner(userKey), Logger: NopLogger{}},
+	}
+
+	if err := RelayTriggers(ctx, srcClient, blockchain, cfg); err != context.Canceled {
+		t.Fatal("ERROR relaying trigger events: ", err)
+	}
+	if len(relayed) != 1 || relayed[0].TxHash != triggerTx.Hash() || relayed[0].Caller != userAddr {
+		t.Fatalf("ERROR expected the trigger transaction %x to be relayed, got %v", triggerTx.Hash(), relayed)
+	}
+
+	// nothing but the validation stub was sent
+	nonce, err := blockchain.PendingNonceAt(context.Background(), userAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != 1 {
+		t.Fatalf("ERROR expected no transaction sent in dry-run mode, got nonce %d", nonce)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// ErrRelayerClosed is returned by the methods of a Relayer once it is closed
+var ErrRelayerClosed = errors.New("relayer closed")
+
+// RelayerCloseTimeout is how long Close waits for the watches and deployments
+// of a Relayer to stop
+var RelayerCloseTimeout = 10 * time.Second
+
+// Relayer owns the clients it dials and the trigger event watches and
+// deployments started through it, so a long-running service can release them
+// all with Close rather than leaking connections and goroutines
+type Relayer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closed  bool
+	clients []*ethclient.Client
+	running sync.WaitGroup
+}
+
+// NewRelayer returns a Relayer whose watches and deployments run until ctx is
+// done or the relayer is closed
+func NewRelayer(ctx context.Context) *Relayer {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Relayer{ctx: ctx, cancel: cancel}
+}
+
+// track registers a goroutine Close waits for, failing once closed
+func (r *Relayer) track() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrRelayerClosed
+	}
+	r.running.Add(1)
+	return nil
+}
+
+// Dial connects to the node at rawurl like utils.Dial. The client is closed
+// with the relayer.
+func (r *Relayer) Dial(ctx context.Context, rawurl string) (*ethclient.Client, error) {
+	client, err := utils.Dial(ctx, rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		client.Close()
+		return nil, ErrRelayerClosed
+	}
+	r.clients = append(r.clients, client)
+	return client, nil
+}
+
+// WatchTriggerEvents watches the trigger contract at triggerAddr like the
+// WatchTriggerEvents function until ctx is done or the relayer is closed,
+// which unsubscribes from the logs and closes both channels
+func (r *Relayer) WatchTriggerEvents(
+	ctx context.Context,
+	client bind.ContractFilterer,
+	triggerAddr common.Address,
+	triggerABI abi.ABI,
+) (<-chan TriggerEvent, <-chan error) {
+	resChan := make(chan TriggerEvent)
+	errChan := make(chan error, 1)
+	if err := r.track(); err != nil {
+		errChan <- err
+		close(errChan)
+		close(resChan)
+		return resChan, errChan
+	}
+
+	watchCtx, cancel := context.WithCancel(r.ctx)
+	events, errs := WatchTriggerEvents(watchCtx, client, triggerAddr, triggerABI)
+
+	go func() {
+		defer r.running.Done()
+		defer cancel()
+		defer close(errChan)
+		defer close(resChan)
+
+		// the watch is drained until it closes its channels, so its
+		// goroutine has returned once this one does
+		done := ctx.Done()
+		for events != nil || errs != nil {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					events = nil
+					continue
+				}
+				select {
+				case resChan <- event:
+				case <-watchCtx.Done():
+				case <-done:
+					cancel()
+					done = nil
+				}
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				select {
+				case errChan <- err:
+				default:
+				}
+			case <-done:
+				cancel()
+				done = nil
+			}
+		}
+	}()
+
+	return resChan, errChan
+}
+
+// Deploy runs deploy, e.g. a closure over DeployPrecompiled or
+// CompileAndDeployIon, with a context cancelled when ctx is done or the
+// relayer is closed. Close waits for the deployment to return.
+func (r *Relayer) Deploy(
+	ctx context.Context,
+	deploy func(ctx context.Context) (<-chan ContractInstance, <-chan error),
+) (<-chan ContractInstance, <-chan error) {
+	if err := r.track(); err != nil {
+		return failedDeployment(err)
+	}
+
+	resChan := make(chan ContractInstance)
+	errChan := make(chan error, 1)
+	deployCtx, cancel := context.WithCancel(r.ctx)
+	instances, errs := deploy(deployCtx)
+
+	go func() {
+		defer r.running.Done()
+		defer cancel()
+		defer close(errChan)
+		defer close(resChan)
+
+		done := ctx.Done()
+		for instances != nil || errs != nil {
+			select {
+			case ci, ok := <-instances:
+				if !ok {
+					instances = nil
+					continue
+				}
+				select {
+				case resChan <- ci:
+				case <-deployCtx.Done():
+				case <-done:
+					cancel()
+					done = nil
+				}
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				select {
+				case errChan <- err:
+				default:
+				}
+			case <-done:
+				cancel()
+				done = nil
+			}
+		}
+	}()
+
+	return resChan, errChan
+}
+
+// Close stops the watches and deployments of the relayer, waiting
+// RelayerCloseTimeout at most for them to return, then closes the clients it
+// dialed. Closing a closed relayer does nothing.
+func (r *Relayer) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	clients := r.clients
+	r.clients = nil
+	r.mu.Unlock()
+
+	r.cancel()
+	drained := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-time.After(RelayerCloseTimeout):
+		err = fmt.Errorf("timed out after %v waiting for the relayer to stop", RelayerCloseTimeout)
+	}
+	for _, client := range clients {
+		client.Close()
+	}
+	return err
+}
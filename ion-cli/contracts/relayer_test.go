@@ -0,0 +1,59 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func Test_RelayerClose(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	goroutines := runtime.NumGoroutine()
+
+	relayer := NewRelayer(ctx)
+	eventChan, _ := relayer.WatchTriggerEvents(ctx, blockchain, common.Address{}, abi.ABI{})
+	// never mined, the deployment waits until the relayer is closed
+	contractChan, _ := relayer.Deploy(ctx, func(ctx context.Context) (<-chan ContractInstance, <-chan error) {
+		return DeployPrecompiled(ctx, blockchain, userKey, "[]", "60fe60005360016000f3", nil)
+	})
+
+	if err := relayer.Close(); err != nil {
+		t.Fatal("ERROR closing relayer: ", err)
+	}
+	if _, ok := <-eventChan; ok {
+		t.Fatal("ERROR expected the trigger events channel to be closed")
+	}
+	if _, ok := <-contractChan; ok {
+		t.Fatal("ERROR expected the deployment channel to be closed")
+	}
+
+	// goroutines which have been told to stop may take a moment to exit
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Fatalf("ERROR expected %d goroutines after Close, got %d", goroutines, n)
+	}
+
+	if err := relayer.Close(); err != nil {
+		t.Fatal("ERROR closing relayer twice: ", err)
+	}
+	_, errChan := relayer.WatchTriggerEvents(ctx, blockchain, common.Address{}, abi.ABI{})
+	if err := <-errChan; !errors.Is(err, ErrRelayerClosed) {
+		t.Fatalf("ERROR expected ErrRelayerClosed, got %v", err)
+	}
+}
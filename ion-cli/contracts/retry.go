@@ -0,0 +1,182 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DefaultRetryAttempts is the number of attempts made when retrying with a zero MaxAttempts
+const DefaultRetryAttempts = 5
+
+// DefaultRetryDelay is the delay before the first retry when retrying with a zero BaseDelay
+const DefaultRetryDelay = time.Second
+
+// RetryOptions is the policy retrying RPC calls failing with transient errors
+// with exponential backoff
+type RetryOptions struct {
+	// MaxAttempts including the first one, DefaultRetryAttempts when zero
+	MaxAttempts int
+	// BaseDelay before the first retry, doubled after every attempt.
+	// DefaultRetryDelay when zero.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts, no cap when zero
+	MaxDelay time.Duration
+}
+
+// backoff tracks the attempts and delays of a retried call
+type backoff struct {
+	attempts int
+	delay    time.Duration
+	maxDelay time.Duration
+}
+
+// retry returns the backoff of a call, a single attempt when no policy is set
+func (opts *TxOptions) retry() *backoff {
+	if opts == nil || opts.Retry == nil {
+		return &backoff{attempts: 1}
+	}
+	b := &backoff{
+		attempts: opts.Retry.MaxAttempts,
+		delay:    opts.Retry.BaseDelay,
+		maxDelay: opts.Retry.MaxDelay,
+	}
+	if b.attempts <= 0 {
+		b.attempts = DefaultRetryAttempts
+	}
+	if b.delay <= 0 {
+		b.delay = DefaultRetryDelay
+	}
+	return b
+}
+
+// wait sleeps before the next attempt and doubles the delay, failing with
+// errCancelled when ctx is done first
+func (b *backoff) wait(ctx context.Context) error {
+	select {
+	case <-time.After(b.delay):
+	case <-ctx.Done():
+		return errCancelled(ctx.Err())
+	}
+	b.delay *= 2
+	if b.maxDelay > 0 && b.delay > b.maxDelay {
+		b.delay = b.maxDelay
+	}
+	return nil
+}
+
+// transientErrors are fragments of the messages of RPC failures worth retrying
+var transientErrors = []string{
+	"timeout",
+	"timed out",
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"eof",
+	"429",
+	"too many requests",
+	"temporarily unavailable",
+	"bad gateway",
+	"service unavailable",
+}
+
+// isTransientError reports whether err is a network failure that may not
+// happen again. Reverts and other execution errors are permanent.
+func isTransientError(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fragment := range transientErrors {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNonceTooLow reports whether the node rejected a transaction for a stale nonce
+func isNonceTooLow(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "nonce too low")
+}
+
+// isKnownTransaction reports whether the node already has the transaction,
+// e.g. because an earlier attempt reached it before the connection failed
+func isKnownTransaction(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "known transaction") || strings.Contains(msg, "already known")
+}
+
+// signAndSend builds, signs and sends a transaction from userKey, retrying
+// transient failures according to opts. The same signed transaction is sent
+// again after network errors, and rebuilt with a fresh nonce when the node
+// reports the nonce is too low.
+func signAndSend(
+	ctx context.Context,
+	backend bind.ContractBackend,
+	account AccountSigner,
+	to *common.Address,
+	amount *big.Int,
+	opts *TxOptions,
+	payload []byte,
+) (*types.Transaction, error) {
+	if err := opts.checkChainID(ctx, backend); err != nil {
+		return nil, err
+	}
+	from := account.Address()
+
+	// the nonce is only released once the transaction is abandoned, as a
+	// transaction sent again keeps its nonce
+	abandon := func(err error) (*types.Transaction, error) {
+		opts.unsent(from)
+		return nil, err
+	}
+
+	retry := opts.retry()
+	var signedTx *types.Transaction
+	for attempt := 1; ; attempt++ {
+		if signedTx == nil {
+			tx, err := newTx(ctx, backend, &from, to, amount, opts, payload)
+			if err != nil {
+				return abandon(err)
+			}
+			if signedTx, err = signTx(tx, account, opts); err != nil {
+				return abandon(err)
+			}
+		}
+
+		err := sendTx(ctx, backend, from, signedTx, opts)
+		if err == nil {
+			return signedTx, nil
+		}
+		if attempt > 1 && isKnownTransaction(err) {
+			opts.submitted(signedTx)
+			return signedTx, nil
+		}
+
+		rebuild := isNonceTooLow(err)
+		if attempt >= retry.attempts || !(rebuild || isTransientError(err)) {
+			return abandon(err)
+		}
+		if rebuild {
+			opts.unsent(from)
+			signedTx = nil
+		}
+
+		opts.logger().Warn("Sending transaction failed, retrying", "delay", retry.delay, "err", err)
+		if err := retry.wait(ctx); err != nil {
+			return abandon(err)
+		}
+	}
+}
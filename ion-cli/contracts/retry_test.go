@@ -0,0 +1,60 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_IsTransientError(t *testing.T) {
+	transient := []string{
+		"dial tcp 127.0.0.1:8545: connect: connection refused",
+		"read tcp 10.0.0.1:443: read: connection reset by peer",
+		"429 Too Many Requests",
+		"unexpected EOF",
+		"context deadline exceeded (Client.Timeout exceeded while awaiting headers)",
+	}
+	for _, msg := range transient {
+		if !isTransientError(errors.New(msg)) {
+			t.Fatalf("ERROR expected %q to be transient", msg)
+		}
+	}
+
+	permanent := []string{
+		"execution reverted",
+		"invalid opcode: opcode 0xfe not defined",
+		"insufficient funds for gas * price + value",
+	}
+	for _, msg := range permanent {
+		if isTransientError(errors.New(msg)) {
+			t.Fatalf("ERROR expected %q to be permanent", msg)
+		}
+	}
+
+	if !isNonceTooLow(errors.New("nonce too low")) {
+		t.Fatal("ERROR expected nonce too low to be detected")
+	}
+	if !isKnownTransaction(errors.New("known transaction: 9b1c")) {
+		t.Fatal("ERROR expected known transaction to be detected")
+	}
+}
+
+func Test_BackoffMaxDelay(t *testing.T) {
+	opts := &TxOptions{Retry: &RetryOptions{BaseDelay: time.Millisecond, MaxDelay: 3 * time.Millisecond}}
+	retry := opts.retry()
+	if retry.attempts != DefaultRetryAttempts {
+		t.Fatalf("ERROR expected %d attempts, got %d", DefaultRetryAttempts, retry.attempts)
+	}
+
+	expected := []time.Duration{2 * time.Millisecond, 3 * time.Millisecond, 3 * time.Millisecond}
+	for _, delay := range expected {
+		if err := retry.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if retry.delay != delay {
+			t.Fatalf("ERROR expected delay %v, got %v", delay, retry.delay)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DefaultSimBalance funds the simulated accounts given no balance, 1000 ether
+var DefaultSimBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+
+// SimulatedChainID is the chain id of the simulated backend, the one its
+// transactions must be signed for
+var SimulatedChainID = params.AllEthashProtocolChanges.ChainID
+
+// SimAccount is an account funded in the genesis of a simulated backend
+type SimAccount struct {
+	Key *ecdsa.PrivateKey
+	// Balance of the account, DefaultSimBalance when nil
+	Balance *big.Int
+}
+
+// NewSimulatedBackend returns an in-memory chain with the accounts funded in
+// its genesis, and their addresses in the same order, to deploy and call the
+// Ion contracts in process. Transactions are only mined by Commit, see
+// CommitBlocks.
+func NewSimulatedBackend(accounts ...SimAccount) (*backends.SimulatedBackend, []common.Address, error) {
+	alloc := make(core.GenesisAlloc)
+	addrs := make([]common.Address, len(accounts))
+	for i, account := range accounts {
+		if account.Key == nil {
+			return nil, nil, fmt.Errorf("simulated account %d has no key", i)
+		}
+		addrs[i] = crypto.PubkeyToAddress(account.Key.PublicKey)
+		if _, ok := alloc[addrs[i]]; ok {
+			return nil, nil, fmt.Errorf("simulated account %s given twice", addrs[i].Hex())
+		}
+		balance := account.Balance
+		if balance == nil {
+			balance = DefaultSimBalance
+		}
+		alloc[addrs[i]] = core.GenesisAccount{Balance: balance}
+	}
+	return backends.NewSimulatedBackend(alloc), addrs, nil
+}
+
+// CommitBlocks mines n blocks on the simulated backend, the first including
+// the pending transactions
+func CommitBlocks(backend *backends.SimulatedBackend, n int) {
+	for i := 0; i < n; i++ {
+		backend.Commit()
+	}
+}
+
+// SimulatedEthService serves the eth namespace calls of a simulated backend
+// the package makes through an RPC client. It is exported as the RPC server
+// of the pinned go-ethereum only registers exported services.
+type SimulatedEthService struct{}
+
+// ChainId serves eth_chainId
+func (SimulatedEthService) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(SimulatedChainID)
+}
+
+// SimulatedRPC returns an in-process RPC client answering eth_chainId with
+// SimulatedChainID, so utils.DetectChainID works against the simulated backend
+func SimulatedRPC() (*rpc.Client, error) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", SimulatedEthService{}); err != nil {
+		return nil, fmt.Errorf("failed to register simulated eth service: %v", err)
+	}
+	return rpc.DialInProc(server), nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func Test_NewSimulatedBackend(t *testing.T) {
+	ctx := context.Background()
+	key1, _ := crypto.GenerateKey()
+	key2, _ := crypto.GenerateKey()
+
+	blockchain, addrs, err := NewSimulatedBackend(SimAccount{Key: key1}, SimAccount{Key: key2, Balance: big.NewInt(42)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 || addrs[1] != crypto.PubkeyToAddress(key2.PublicKey) {
+		t.Fatalf("ERROR unexpected addresses %v", addrs)
+	}
+	balance, err := blockchain.BalanceAt(ctx, addrs[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(DefaultSimBalance) != 0 {
+		t.Fatalf("ERROR expected the default balance, got %v", balance)
+	}
+	if balance, _ = blockchain.BalanceAt(ctx, addrs[1], nil); balance.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("ERROR expected a balance of 42, got %v", balance)
+	}
+
+	tx := types.NewTransaction(0, addrs[1], big.NewInt(1), 21000, big.NewInt(1), nil)
+	signedTx, err := types.SignTx(tx, types.HomesteadSigner{}, key1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := blockchain.SendTransaction(ctx, signedTx); err != nil {
+		t.Fatal(err)
+	}
+	CommitBlocks(blockchain, 3)
+	if receipt, _ := blockchain.TransactionReceipt(ctx, signedTx.Hash()); receipt == nil {
+		t.Fatal("ERROR expected the transaction to be mined")
+	}
+
+	if _, _, err := NewSimulatedBackend(SimAccount{Key: key1}, SimAccount{Key: key1}); err == nil {
+		t.Fatal("ERROR expected an account given twice to be rejected")
+	}
+}
+
+func Test_SimulatedRPC(t *testing.T) {
+	client, err := SimulatedRPC()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	chainID, chainIDHash, err := utils.DetectChainID(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chainID.Cmp(SimulatedChainID) != 0 || chainIDHash != common.BigToHash(SimulatedChainID) {
+		t.Fatalf("ERROR unexpected chain id %v", chainID)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/compiler"
+)
+
+// CompileContracts compiles the Solidity files at paths with the solc binary,
+// version and settings of opts, caching the output like Compile
+func CompileContracts(paths []string, opts CompileOptions) (map[string]*compiler.Contract, error) {
+	return Compile(&opts, paths...)
+}
+
+// solcArgs returns the solc arguments of the compile settings of opts. The
+// optimizer is on unless disabled, as go-ethereum compiles with it.
+func (opts *CompileOptions) solcArgs(solc *compiler.Solidity) []string {
+	outputs := "bin,bin-runtime,srcmap,srcmap-runtime,abi,userdoc,devdoc"
+	if solc.Major > 0 || solc.Minor > 4 || solc.Patch > 6 {
+		outputs += ",metadata"
+	}
+	args := []string{"--combined-json", outputs}
+
+	if opts == nil {
+		opts = &CompileOptions{}
+	}
+	if !opts.DisableOptimizer {
+		args = append(args, "--optimize")
+		if opts.OptimizeRuns > 0 {
+			args = append(args, "--optimize-runs", strconv.Itoa(opts.OptimizeRuns))
+		}
+	}
+	if opts.EVMVersion != "" {
+		args = append(args, "--evm-version", opts.EVMVersion)
+	}
+	return args
+}
+
+// runSolc compiles files with solc and args, returning the warnings solc
+// reported along with the contracts
+func runSolc(solc *compiler.Solidity, args []string, files ...string) (map[string]*compiler.Contract, []string, error) {
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no Solidity files to compile")
+	}
+
+	var source strings.Builder
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %q: %v", file, err)
+		}
+		source.Write(content)
+	}
+
+	cmd := exec.Command(solc.Path, append(append(append([]string{}, args...), "--"), files...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, nil, fmt.Errorf("solc: %v\n%s", err, stderr.Bytes())
+	}
+	contracts, err := compiler.ParseCombinedJSON(stdout.Bytes(), source.String(), solc.Version, solc.Version, strings.Join(args, " "))
+	if err != nil {
+		return nil, nil, err
+	}
+	return contracts, solcWarnings(stderr.String()), nil
+}
+
+// solcWarnings splits the diagnostics solc prints on stderr into its warnings,
+// each with the source excerpt following it
+func solcWarnings(stderr string) []string {
+	var warnings []string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			warnings = append(warnings, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+	for _, line := range strings.Split(stderr, "\n") {
+		switch {
+		case strings.Contains(line, "Warning: "):
+			flush()
+			current = []string{line}
+		case strings.Contains(line, "Error: "):
+			flush()
+		case current != nil && strings.TrimSpace(line) != "":
+			current = append(current, line)
+		}
+	}
+	flush()
+	return warnings
+}
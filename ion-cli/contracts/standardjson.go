@@ -0,0 +1,262 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+)
+
+// LinkReference is the position of a library address in the bytecode of a
+// contract, in bytes
+type LinkReference struct {
+	Start  int `json:"start"`
+	Length int `json:"length"`
+}
+
+// StandardContract is a contract compiled through the solc standard-JSON
+// interface, with the positions of the libraries it links against
+type StandardContract struct {
+	*compiler.Contract
+	// RuntimeCode is the runtime bytecode of the contract, which the compiler
+	// contracts of the pinned go-ethereum don't hold, see VerifyDeployedCode
+	RuntimeCode string
+	// LinkReferences of the deployment bytecode by fully qualified library name
+	LinkReferences map[string][]LinkReference
+	// RuntimeLinkReferences of the runtime bytecode by fully qualified library name
+	RuntimeLinkReferences map[string][]LinkReference
+}
+
+// Link replaces the library references of the deployment bytecode with the
+// library addresses, keyed by fully qualified name. Every library referenced
+// must be given.
+func (c *StandardContract) Link(libs map[string]common.Address) (string, error) {
+	bin := []byte(strings.TrimPrefix(c.Code, "0x"))
+	for name, refs := range c.LinkReferences {
+		addr, ok := libs[name]
+		if !ok {
+			return "", fmt.Errorf("no address given for library %s", name)
+		}
+		addrHex := strings.TrimPrefix(strings.ToLower(addr.Hex()), "0x")
+		for _, ref := range refs {
+			start, end := ref.Start*2, (ref.Start+ref.Length)*2
+			if ref.Length != common.AddressLength || end > len(bin) {
+				return "", fmt.Errorf("invalid link reference of library %s at %d", name, ref.Start)
+			}
+			copy(bin[start:end], addrHex)
+		}
+	}
+	return string(bin), nil
+}
+
+// standardJSONInput is the input of solc --standard-json
+type standardJSONInput struct {
+	Language string                        `json:"language"`
+	Sources  map[string]standardJSONSource `json:"sources"`
+	Settings standardJSONSettings          `json:"settings"`
+}
+
+type standardJSONSource struct {
+	Content string `json:"content"`
+}
+
+type standardJSONSettings struct {
+	Remappings      []string                       `json:"remappings,omitempty"`
+	Optimizer       standardJSONOptimizer          `json:"optimizer"`
+	EVMVersion      string                         `json:"evmVersion,omitempty"`
+	OutputSelection map[string]map[string][]string `json:"outputSelection"`
+}
+
+type standardJSONOptimizer struct {
+	Enabled bool `json:"enabled"`
+	Runs    int  `json:"runs,omitempty"`
+}
+
+// standardJSONOutput is the output of solc --standard-json
+type standardJSONOutput struct {
+	Errors []struct {
+		Severity         string `json:"severity"`
+		FormattedMessage string `json:"formattedMessage"`
+	} `json:"errors"`
+	Contracts map[string]map[string]struct {
+		ABI      interface{} `json:"abi"`
+		Metadata string      `json:"metadata"`
+		UserDoc  interface{} `json:"userdoc"`
+		DevDoc   interface{} `json:"devdoc"`
+		EVM      struct {
+			Bytecode         standardJSONBytecode `json:"bytecode"`
+			DeployedBytecode standardJSONBytecode `json:"deployedBytecode"`
+		} `json:"evm"`
+	} `json:"contracts"`
+}
+
+type standardJSONBytecode struct {
+	Object         string                                `json:"object"`
+	LinkReferences map[string]map[string][]LinkReference `json:"linkReferences"`
+}
+
+// standardJSONOutputs selected for every contract
+var standardJSONOutputs = []string{
+	"abi",
+	"metadata",
+	"userdoc",
+	"devdoc",
+	"evm.bytecode.object",
+	"evm.bytecode.linkReferences",
+	"evm.deployedBytecode.object",
+	"evm.deployedBytecode.linkReferences",
+}
+
+// CompileStandardJSON compiles the Solidity files with a single run of solc
+// through its standard-JSON interface, with the binary, settings and import
+// Remappings of opts. Contracts are keyed by path:Name like Compile, and report
+// the positions of the libraries they link against. The output is not cached,
+// as the imports resolved through remappings can't be followed for the key.
+func CompileStandardJSON(opts *CompileOptions, files ...string) (map[string]*StandardContract, error) {
+	contracts, _, err := compileStandardJSON(opts, files...)
+	return contracts, err
+}
+
+// compileStandardJSON compiles like CompileStandardJSON, also returning the
+// warnings of the compilation
+func compileStandardJSON(opts *CompileOptions, files ...string) (map[string]*StandardContract, []string, error) {
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no Solidity files to compile")
+	}
+	solc, err := opts.solc()
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts == nil {
+		opts = &CompileOptions{}
+	}
+
+	input := standardJSONInput{
+		Language: "Solidity",
+		Sources:  make(map[string]standardJSONSource),
+		Settings: standardJSONSettings{
+			Remappings: opts.Remappings,
+			Optimizer: standardJSONOptimizer{
+				Enabled: !opts.DisableOptimizer,
+				Runs:    opts.OptimizeRuns,
+			},
+			EVMVersion:      opts.EVMVersion,
+			OutputSelection: map[string]map[string][]string{"*": {"*": standardJSONOutputs}},
+		},
+	}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %q: %v", file, err)
+		}
+		input.Sources[file] = standardJSONSource{Content: string(content)}
+	}
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode solc input: %v", err)
+	}
+
+	cmd := exec.Command(solc.Path, "--standard-json", "--allow-paths", strings.Join(allowedPaths(files, opts.Remappings), ","))
+	cmd.Stdin = bytes.NewReader(inputJSON)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, nil, fmt.Errorf("solc: %v\n%s", err, stderr.Bytes())
+	}
+	return parseStandardJSON(stdout.Bytes(), solc, opts)
+}
+
+// parseStandardJSON converts the solc standard-JSON output to contracts and
+// warnings, failing with the compile errors if any
+func parseStandardJSON(data []byte, solc *compiler.Solidity, opts *CompileOptions) (map[string]*StandardContract, []string, error) {
+	var output standardJSONOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode solc output: %v", err)
+	}
+
+	var errs, warnings []string
+	for _, e := range output.Errors {
+		switch e.Severity {
+		case "error":
+			errs = append(errs, e.FormattedMessage)
+		case "warning":
+			warnings = append(warnings, strings.TrimSpace(e.FormattedMessage))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("solc: %s", strings.Join(errs, "\n"))
+	}
+
+	compilerOptions := fmt.Sprintf("--standard-json optimizer=%t runs=%d evmVersion=%s", !opts.DisableOptimizer, opts.OptimizeRuns, opts.EVMVersion)
+	contracts := make(map[string]*StandardContract)
+	for file, fileContracts := range output.Contracts {
+		for name, out := range fileContracts {
+			contracts[file+":"+name] = &StandardContract{
+				Contract: &compiler.Contract{
+					Code: "0x" + out.EVM.Bytecode.Object,
+					Info: compiler.ContractInfo{
+						Language:        "Solidity",
+						LanguageVersion: solc.Version,
+						CompilerVersion: solc.Version,
+						CompilerOptions: compilerOptions,
+						AbiDefinition:   out.ABI,
+						UserDoc:         out.UserDoc,
+						DeveloperDoc:    out.DevDoc,
+						Metadata:        out.Metadata,
+					},
+				},
+				RuntimeCode:           "0x" + out.EVM.DeployedBytecode.Object,
+				LinkReferences:        flattenLinkReferences(out.EVM.Bytecode.LinkReferences),
+				RuntimeLinkReferences: flattenLinkReferences(out.EVM.DeployedBytecode.LinkReferences),
+			}
+		}
+	}
+	return contracts, warnings, nil
+}
+
+// flattenLinkReferences keys the solc link references, grouped by file then
+// library, by fully qualified library name
+func flattenLinkReferences(refs map[string]map[string][]LinkReference) map[string][]LinkReference {
+	flat := make(map[string][]LinkReference)
+	for file, libs := range refs {
+		for lib, libRefs := range libs {
+			flat[file+":"+lib] = libRefs
+		}
+	}
+	return flat
+}
+
+// allowedPaths returns the directories solc may read imports from, those of
+// the files compiled and the targets of the remappings
+func allowedPaths(files []string, remappings []string) []string {
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		if dir, err := filepath.Abs(filepath.Dir(file)); err == nil {
+			dirs[dir] = true
+		}
+	}
+	for _, remapping := range remappings {
+		// remappings are [context:]prefix=target
+		if i := strings.Index(remapping, "="); i >= 0 {
+			if dir, err := filepath.Abs(remapping[i+1:]); err == nil {
+				dirs[dir] = true
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		paths = append(paths, dir)
+	}
+	sort.Strings(paths)
+	return paths
+}
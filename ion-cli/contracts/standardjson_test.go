@@ -0,0 +1,60 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+)
+
+func Test_ParseStandardJSON(t *testing.T) {
+	output := `{
+		"errors": [{"severity": "warning", "formattedMessage": "Main.sol: unused variable"}],
+		"contracts": {"Main.sol": {"Main": {
+			"abi": [],
+			"evm": {
+				"bytecode": {
+					"object": "6060` + libraryPlaceholder("Lib.sol:Lib") + `6060",
+					"linkReferences": {"Lib.sol": {"Lib": [{"start": 2, "length": 20}]}}
+				},
+				"deployedBytecode": {"object": "6060", "linkReferences": {}}
+			}
+		}}}
+	}`
+	solc := &compiler.Solidity{Version: "0.4.24"}
+
+	contracts, warnings, err := parseStandardJSON([]byte(output), solc, &CompileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0] != "Main.sol: unused variable" {
+		t.Fatalf("ERROR unexpected warnings %v", warnings)
+	}
+	main, ok := contracts["Main.sol:Main"]
+	if !ok {
+		t.Fatal("ERROR expected Main.sol:Main to be compiled")
+	}
+	if main.RuntimeCode != "0x6060" {
+		t.Fatalf("ERROR unexpected runtime bytecode %s", main.RuntimeCode)
+	}
+	if refs := main.LinkReferences["Lib.sol:Lib"]; len(refs) != 1 || refs[0].Start != 2 {
+		t.Fatalf("ERROR unexpected link references %v", main.LinkReferences)
+	}
+
+	linked, err := main.Link(map[string]common.Address{"Lib.sol:Lib": common.HexToAddress("0xab")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if linked != "6060"+"00000000000000000000000000000000000000ab"+"6060" {
+		t.Fatalf("ERROR unexpected linked bytecode %s", linked)
+	}
+	if _, err := main.Link(nil); err == nil {
+		t.Fatal("ERROR expected a missing library to be rejected")
+	}
+
+	failed := `{"errors": [{"severity": "error", "formattedMessage": "Main.sol: parse error"}]}`
+	if _, _, err := parseStandardJSON([]byte(failed), solc, &CompileOptions{}); err == nil {
+		t.Fatal("ERROR expected the compile errors to be reported")
+	}
+}
@@ -18,15 +18,15 @@ func Fire(
 	userKey *ecdsa.PrivateKey,
 	contract *compiler.Contract,
 	toAddr common.Address,
-) (tx *types.Transaction) {
-	tx = TransactionContract(
+) (tx *types.Transaction, err error) {
+	tx, err = TransactionContract(
 		ctx,
 		backend,
 		userKey,
 		contract,
 		toAddr,
 		nil,
-		uint64(3000000),
+		nil,
 		"fire",
 	)
 
@@ -4,9 +4,12 @@ package contract
 import (
 	"context"
 	"crypto/ecdsa"
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/compiler"
@@ -14,59 +17,93 @@ import (
 )
 
 // CompileAndDeployValidation method
+// The instance is sent on the first channel once deployed. Any failure is sent
+// on the error channel and both channels are closed.
 func CompileAndDeployValidation(
 	ctx context.Context,
 	client bind.ContractBackend,
 	userKey *ecdsa.PrivateKey,
 	chainID interface{},
 	ionContractAddress common.Address,
-) <-chan ContractInstance {
+	opts *TxOptions,
+	compileOpts *CompileOptions,
+) (<-chan ContractInstance, <-chan error) {
+	resChan := make(chan ContractInstance)
+	errChan := make(chan error, 1)
+
+	ctx, cancel := opts.withTimeout(ctx)
+	fail := func(err error) (<-chan ContractInstance, <-chan error) {
+		cancel()
+		errChan <- err
+		close(errChan)
+		close(resChan)
+		return resChan, errChan
+	}
+	deployBackend, ok := client.(bind.DeployBackend)
+	if !ok {
+		return fail(fmt.Errorf("client can't wait for deployments to be mined"))
+	}
+
 	// ---------------------------------------------
 	// COMPILE VALIDATION AND DEPENDENCIES
 	// ---------------------------------------------
-	basePath := os.Getenv("GOPATH") + "/src/github.com/clearmatics/ion/contracts/"
+	basePath, err := contractsBasePath("Validation.sol")
+	if err != nil {
+		return fail(err)
+	}
 	validationContractPath := basePath + "Validation.sol"
 
-	contracts, err := compiler.CompileSolidity("", validationContractPath)
+	contracts, err := Compile(compileOpts, validationContractPath)
 	if err != nil {
-		log.Fatal("ERROR failed to compile Validation.sol:", err)
+		return fail(fmt.Errorf("failed to compile Validation.sol: %v", err))
 	}
 
-	validationContract := contracts[basePath+"Validation.sol:Validation"]
-	validationBinStr, validationABIStr := getContractBytecodeAndABI(validationContract)
+	validationContract, err := compiledContract(contracts, validationContractPath+":Validation")
+	if err != nil {
+		return fail(err)
+	}
+	validationBinStr, validationABIStr, err := getContractBytecodeAndABI(validationContract)
+	if err != nil {
+		return fail(err)
+	}
 
 	// ---------------------------------------------
 	// DEPLOY VALIDATION CONTRACT
 	// ---------------------------------------------
-	validationSignedTx := compileAndDeployContract(
+	if err := ctx.Err(); err != nil {
+		return fail(stageError(ctx, StageCompile, errCancelled(err)))
+	}
+	validationSignedTx, err := compileAndDeployContract(
 		ctx,
 		client,
 		userKey,
 		validationBinStr,
 		validationABIStr,
 		nil,
-		uint64(3000000),
+		opts,
 		chainID,
 		ionContractAddress,
 	)
+	if err != nil {
+		return fail(stageError(ctx, StageSubmit, fmt.Errorf("failed to deploy Validation: %v", err)))
+	}
 
-	resChan := make(chan ContractInstance)
-
-	// Go-Routine that waits for PatriciaTrie Library and Ion Contract to be deployed
-	// Ion depends on PatriciaTrie library
+	// Go-Routine that waits for the Validation contract to be deployed
 	go func() {
+		defer cancel()
+		defer close(errChan)
 		defer close(resChan)
-		deployBackend := client.(bind.DeployBackend)
 
-		// wait for PatriciaTrie library to be deployed
-		validationAddr, err := bind.WaitDeployed(ctx, deployBackend, validationSignedTx)
+		validationAddr, validationReceipt, err := waitDeployed(ctx, deployBackend, validationSignedTx, "Validation", opts)
 		if err != nil {
-			log.Fatal("ERROR while waiting for contract deployment")
+			errChan <- stageError(ctx, StageWait, err)
+			return
 		}
-		resChan <- ContractInstance{validationContract, validationAddr}
+
+		sendInstance(ctx, resChan, errChan, deployedInstance(client, validationContract, validationAddr, validationSignedTx, validationReceipt))
 	}()
 
-	return resChan
+	return resChan, errChan
 }
 
 // RegisterChain with Validation contract specified
@@ -79,15 +116,15 @@ func RegisterChain(
 	chainID common.Hash,
 	validators []common.Address,
 	registerHash common.Hash,
-) (tx *types.Transaction) {
-	tx = TransactionContract(
+) (tx *types.Transaction, err error) {
+	tx, err = TransactionContract(
 		ctx,
 		backend,
 		userKey,
 		contract,
 		toAddr,
 		nil,
-		uint64(3000000),
+		nil,
 		"RegisterChain",
 		chainID,
 		validators,
@@ -97,8 +134,230 @@ func RegisterChain(
 	return
 }
 
-// SubmitBlock Submits block to Validation contract specified
+// RegisterValidators registers the chain chainId with the validation contract
+// deployed at validationAddr, calling its RegisterChain function with the
+// genesis header hash and initial validators. The genesis is hashed the way
+// the validation contract of engine stores blocks. When validators is nil they
+// are parsed from the extra data of the genesis header, the Istanbul extra of
+// IBFT chains or the signers list of clique chains.
+func RegisterValidators(
+	ctx context.Context,
+	backend bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	contract *compiler.Contract,
+	validationAddr common.Address,
+	chainId common.Hash,
+	genesisHeader *types.Header,
+	engine utils.ConsensusType,
+	validators []common.Address,
+	opts *TxOptions,
+) (*types.Transaction, error) {
+	if genesisHeader == nil {
+		return nil, fmt.Errorf("no genesis header given")
+	}
+	genesisHash, err := storedBlockHash(genesisHeader, engine)
+	if err != nil {
+		return nil, err
+	}
+
+	if validators == nil {
+		switch engine {
+		case utils.IBFT:
+			extra, err := utils.DecodeIstanbulExtra(genesisHeader)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the genesis validators: %v", err)
+			}
+			validators = extra.Validators
+		case utils.Clique:
+			cliqueValidators, err := utils.ParseCliqueValidators(genesisHeader)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the genesis validators: %v", err)
+			}
+			validators = cliqueValidators
+		default:
+			return nil, fmt.Errorf("no validators in the extra data of %v headers", engine)
+		}
+	}
+	if len(validators) == 0 {
+		return nil, fmt.Errorf("no validators to register")
+	}
+
+	return transactContract(ctx, backend, userKey, contract, validationAddr, nil, opts, "RegisterChain", chainId, validators, genesisHash)
+}
+
+// ErrBlockAlreadySubmitted is returned by SubmitBlock, when checking for it,
+// for a block the validation contract already has
+var ErrBlockAlreadySubmitted = errors.New("block already submitted")
+
+// blockHashesABI is the ABI of the m_blockhashes mapping of Validation.sol
+const blockHashesABI = `[{"constant":true,"inputs":[{"name":"","type":"bytes32"},{"name":"","type":"bytes32"}],"name":"m_blockhashes","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"view","type":"function"}]`
+
+// IsBlockSubmitted reports whether the block blockHash of the chain chainID was
+// submitted to the validation contract at validationAddr
+func IsBlockSubmitted(
+	ctx context.Context,
+	backend bind.ContractCaller,
+	validationAddr common.Address,
+	chainID common.Hash,
+	blockHash common.Hash,
+) (bool, error) {
+	parsed, err := abi.JSON(strings.NewReader(blockHashesABI))
+	if err != nil {
+		return false, err
+	}
+	var submitted bool
+	validation := bind.NewBoundContract(validationAddr, parsed, backend, nil, nil)
+	if err := validation.Call(&bind.CallOpts{Context: ctx}, &submitted, "m_blockhashes", chainID, blockHash); err != nil {
+		return false, fmt.Errorf("failed to check block %s: %v", blockHash.Hex(), err)
+	}
+	return submitted, nil
+}
+
+// ConsensusMismatchError is returned for headers of one consensus engine
+// submitted to a validation contract whose SubmitBlock doesn't take the
+// encodings of that engine, e.g. a clique header to an ethash validation
+// contract, before anything is sent
+type ConsensusMismatchError struct {
+	// Source is the engine the headers were encoded for
+	Source utils.ConsensusType
+	// SubmitBlock is the signature of the SubmitBlock function of the
+	// validation contract
+	SubmitBlock string
+}
+
+func (e *ConsensusMismatchError) Error() string {
+	return fmt.Sprintf("%s headers can't be submitted to a validation contract with %s, expected %s", e.Source, e.SubmitBlock, submitBlockSignatures[e.Source])
+}
+
+// submitBlockSignatures are the SubmitBlock functions taking the encodings
+// SubmitBlock sends for the headers of each engine. The clique one is the
+// SubmitBlock of Validation.sol.
+var submitBlockSignatures = map[utils.ConsensusType]string{
+	utils.Clique: "SubmitBlock(bytes32,bytes,bytes)",
+	utils.Ethash: "SubmitBlock(bytes32,bytes)",
+	utils.IBFT:   "SubmitBlock(bytes32,bytes,bytes,bytes)",
+}
+
+// checkConsensus fails with a ConsensusMismatchError when the SubmitBlock
+// function of the validation contract doesn't take the headers of engine
+func checkConsensus(contract *compiler.Contract, engine utils.ConsensusType) error {
+	expected, ok := submitBlockSignatures[engine]
+	if !ok {
+		return fmt.Errorf("unsupported consensus type %v", engine)
+	}
+	parsed, err := parseContractABI(contract)
+	if err != nil {
+		return err
+	}
+	method, ok := parsed.Methods["SubmitBlock"]
+	if !ok {
+		return fmt.Errorf("contract has no SubmitBlock function, not a validation contract")
+	}
+	if method.Sig() != expected {
+		return &ConsensusMismatchError{Source: engine, SubmitBlock: method.Sig()}
+	}
+	return nil
+}
+
+// storedBlockHash returns the hash the validation contract of engine stores
+// header under, which for IBFT headers is the hash without the committed
+// seals, see utils.IBFTBlockHash
+func storedBlockHash(header *types.Header, engine utils.ConsensusType) (common.Hash, error) {
+	if engine == utils.IBFT {
+		return utils.IBFTBlockHash(header)
+	}
+	return header.Hash(), nil
+}
+
+// SubmitBlockOptions holds the optional settings of SubmitBlock
+type SubmitBlockOptions struct {
+	// Tx options of the submission, defaults when nil
+	Tx *TxOptions
+	// CheckSubmitted skips blocks already submitted, e.g. by another relayer,
+	// returning ErrBlockAlreadySubmitted instead of a transaction that reverts
+	CheckSubmitted bool
+}
+
+// SubmitBlock Submits block header to Validation contract specified
+// Ion only accepts blocks added by its registered validation modules, so
+// headers are stored in Ion through the validation contract of their chain.
+// The header is RLP encoded for engine, the consensus engine of the source
+// chain. Clique contracts take the unsigned and signed encodings, so the
+// signer can be recovered from the seal, IBFT contracts take the committed
+// seals too, see utils.ExtractIBFTSeals, while ethash contracts take the full
+// encoding only. A validation contract whose SubmitBlock doesn't take these
+// encodings is rejected with a ConsensusMismatchError.
 func SubmitBlock(
+	ctx context.Context,
+	backend bind.ContractBackend,
+	userKey *ecdsa.PrivateKey,
+	contract *compiler.Contract,
+	toAddr common.Address,
+	chainID common.Hash,
+	header *types.Header,
+	engine utils.ConsensusType,
+	opts *SubmitBlockOptions,
+) (*types.Transaction, error) {
+	if opts == nil {
+		opts = &SubmitBlockOptions{}
+	}
+	if err := checkConsensus(contract, engine); err != nil {
+		return nil, err
+	}
+	if opts.CheckSubmitted {
+		blockHash, err := storedBlockHash(header, engine)
+		if err != nil {
+			return nil, err
+		}
+		submitted, err := IsBlockSubmitted(ctx, backend, toAddr, chainID, blockHash)
+		if err != nil {
+			return nil, err
+		}
+		if submitted {
+			return nil, ErrBlockAlreadySubmitted
+		}
+	}
+
+	signedBlockHeaderRLP, err := utils.EncodeBlockHeader(header, utils.Ethash)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{chainID}
+	switch engine {
+	case utils.Clique:
+		unsignedBlockHeaderRLP, err := utils.EncodeBlockHeader(header, utils.Clique)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, unsignedBlockHeaderRLP, signedBlockHeaderRLP)
+	case utils.Ethash:
+		args = append(args, signedBlockHeaderRLP)
+	case utils.IBFT:
+		_, unsignedRLP, signedRLP, committedSeals, err := utils.ExtractIBFTSeals(header)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, unsignedRLP, signedRLP, committedSeals)
+	default:
+		return nil, fmt.Errorf("unsupported consensus type %v", engine)
+	}
+
+	return transactContract(
+		ctx,
+		backend,
+		userKey,
+		contract,
+		toAddr,
+		nil,
+		opts.Tx,
+		"SubmitBlock",
+		args...,
+	)
+}
+
+// SubmitBlockRLP Submits already encoded block to Validation contract specified
+func SubmitBlockRLP(
 	ctx context.Context,
 	backend bind.ContractBackend,
 	userKey *ecdsa.PrivateKey,
@@ -107,15 +366,15 @@ func SubmitBlock(
 	chainID common.Hash,
 	unsignedBlockHeaderRLP []byte,
 	signedBlockHeaderRLP []byte,
-) (tx *types.Transaction) {
-	tx = TransactionContract(
+) (tx *types.Transaction, err error) {
+	tx, err = TransactionContract(
 		ctx,
 		backend,
 		userKey,
 		contract,
 		toAddr,
 		nil,
-		uint64(3000000),
+		nil,
 		"SubmitBlock",
 		chainID,
 		unsignedBlockHeaderRLP,
@@ -133,9 +392,9 @@ func ValidBlock(
 	toAddr common.Address,
 	chainID common.Hash,
 	blockHash common.Hash,
-) (isBlockValid bool) {
+) (isBlockValid bool, err error) {
 	methodName := "m_blockhashes"
-	CallContract(
+	err = CallContract(
 		ctx,
 		backend,
 		contract,
@@ -157,9 +416,9 @@ func LatestValidBlock(
 	userAddr common.Address,
 	toAddr common.Address,
 	chainID common.Hash,
-) (latestBlock common.Hash) {
+) (latestBlock common.Hash, err error) {
 	methodName := "m_latestblock"
-	CallContract(
+	err = CallContract(
 		ctx,
 		backend,
 		contract,
@@ -0,0 +1,290 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrReorged is returned when a mined transaction is dropped from the chain by
+// a reorganisation while waiting for its confirmations
+var ErrReorged = errors.New("transaction dropped by a chain reorganisation")
+
+// ConfirmationsPollInterval is the delay between checks of the confirmations
+// of a transaction
+var ConfirmationsPollInterval = time.Second
+
+// revertSelector is the selector of the Error(string) revert payload
+var revertSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+// MinedBackend is the backend WaitMined needs to fetch receipts and replay calls
+type MinedBackend interface {
+	bind.DeployBackend
+	bind.ContractCaller
+}
+
+// RevertError is returned for a mined transaction that failed
+type RevertError struct {
+	// Reason given to revert or require, empty when none was
+	Reason string
+}
+
+func (e RevertError) Error() string {
+	if e.Reason == "" {
+		return "transaction reverted"
+	}
+	return fmt.Sprintf("transaction reverted: %s", e.Reason)
+}
+
+// Stages of an operation named by a TimeoutError
+const (
+	StageCompile = "compile"
+	StageProve   = "prove"
+	StageSubmit  = "submit"
+	StageWait    = "wait"
+)
+
+// TimeoutError is returned when an operation runs out of time, see
+// TxOptions.Timeout, during Stage. It matches context.DeadlineExceeded with
+// errors.Is.
+type TimeoutError struct {
+	Stage string
+	Err   error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out: %v", e.Stage, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports the timeout as context.DeadlineExceeded, even when Err was a
+// failed RPC call the deadline interrupted
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// stageError wraps err in a TimeoutError of stage once the deadline of ctx
+// has passed
+func stageError(ctx context.Context, stage string, err error) error {
+	if err == nil || ctx.Err() != context.DeadlineExceeded {
+		return err
+	}
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return err
+	}
+	return &TimeoutError{Stage: stage, Err: err}
+}
+
+// WaitMined waits for tx to be mined and returns its receipt. When the
+// transaction failed the receipt is returned with a RevertError holding the
+// reason decoded from replaying the call, see RevertReason.
+func WaitMined(ctx context.Context, backend MinedBackend, tx *types.Transaction) (*types.Receipt, error) {
+	receipt, err := bind.WaitMined(ctx, backend, tx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("waiting for transaction cancelled: %w", ctx.Err())
+		}
+		return nil, fmt.Errorf("failed waiting for transaction %s: %v", tx.Hash().Hex(), err)
+	}
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		return receipt, nil
+	}
+
+	reason, err := RevertReason(ctx, backend, tx, receipt)
+	if err != nil {
+		return receipt, fmt.Errorf("transaction reverted, failed to get the reason: %v", err)
+	}
+	return receipt, RevertError{Reason: reason}
+}
+
+// RevertReason returns the reason the mined transaction tx with receipt
+// reverted with, replaying it as a call and decoding the Error(string)
+// payload. The reason is empty for a revert without one. The call is replayed
+// against the block tx was mined in when the backend can find it, like the
+// ones of DialBackend. The receipts of the pinned go-ethereum don't record
+// their block, so other backends replay it against the latest state.
+func RevertReason(ctx context.Context, backend bind.ContractCaller, tx *types.Transaction, receipt *types.Receipt) (string, error) {
+	if receipt == nil {
+		return "", fmt.Errorf("no receipt for transaction %s", tx.Hash().Hex())
+	}
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		return "", fmt.Errorf("transaction %s did not revert", tx.Hash().Hex())
+	}
+	return revertReason(ctx, backend, tx)
+}
+
+// WaitDeployedWithReceipt waits for the contract creation tx to be mined and
+// returns the address of the contract with the receipt of the deployment.
+// Unlike bind.WaitDeployed a creation mined but reverted is reported with a
+// RevertError, holding the reason when the backend can replay the call, instead
+// of the address the contract would have had.
+func WaitDeployedWithReceipt(ctx context.Context, backend bind.DeployBackend, tx *types.Transaction) (common.Address, *types.Receipt, error) {
+	if tx.To() != nil {
+		return common.Address{}, nil, fmt.Errorf("transaction %s is not a contract creation", tx.Hash().Hex())
+	}
+	receipt, err := bind.WaitMined(ctx, backend, tx)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		var reason string
+		if caller, ok := backend.(bind.ContractCaller); ok {
+			// the failure is reported even when the reason can't be replayed
+			reason, _ = revertReason(ctx, caller, tx)
+		}
+		return common.Address{}, receipt, fmt.Errorf("contract creation %s failed: %w", tx.Hash().Hex(), RevertError{Reason: reason})
+	}
+	if receipt.ContractAddress == (common.Address{}) {
+		return common.Address{}, receipt, fmt.Errorf("no contract address in the receipt of %s", tx.Hash().Hex())
+	}
+
+	code, err := backend.CodeAt(ctx, receipt.ContractAddress, nil)
+	if err != nil {
+		return common.Address{}, receipt, err
+	}
+	if len(code) == 0 {
+		return common.Address{}, receipt, bind.ErrNoCodeAfterDeploy
+	}
+	return receipt.ContractAddress, receipt, nil
+}
+
+// WaitConfirmations waits for the mined transaction tx to be confirmations
+// blocks deep and returns its receipt, failing with ErrReorged if the receipt
+// disappears meanwhile. The depth is counted from the block the transaction
+// is mined in, see minedBlock. The receipts of the pinned go-ethereum don't
+// record their block, so without an RPC client to look it up the depth is
+// counted from the head block when the wait starts, which may add a few
+// blocks to the wait but never removes any. The backend must report its head
+// block, like ethclient.
+func WaitConfirmations(ctx context.Context, backend bind.DeployBackend, tx *types.Transaction, confirmations uint64) (*types.Receipt, error) {
+	reader, ok := backend.(headerReader)
+	if !ok {
+		return nil, fmt.Errorf("backend can't report its head block to count confirmations")
+	}
+
+	// head block when the wait starts, for backends without block lookup
+	var startHead *big.Int
+	ticker := time.NewTicker(ConfirmationsPollInterval)
+	defer ticker.Stop()
+	for {
+		receipt, err := backend.TransactionReceipt(ctx, tx.Hash())
+		if err == ethereum.NotFound || (err == nil && receipt == nil) {
+			return nil, fmt.Errorf("transaction %s: %w", tx.Hash().Hex(), ErrReorged)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get receipt of %s: %v", tx.Hash().Hex(), err)
+		}
+
+		// looked up again on every poll as a reorg may mine tx in another block
+		minedBy, err := minedBlock(ctx, backend, tx.Hash())
+		if err != nil {
+			return nil, err
+		}
+		head, err := reader.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get head block: %v", err)
+		}
+		if minedBy == nil {
+			if startHead == nil {
+				startHead = head.Number
+			}
+			minedBy = startHead
+		}
+		if new(big.Int).Sub(head.Number, minedBy).Cmp(new(big.Int).SetUint64(confirmations)) >= 0 {
+			return receipt, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// minedBlock returns the number of the block the mined transaction txHash is
+// in when backend can look it up, see rpcClientBackend, or else nil
+func minedBlock(ctx context.Context, backend interface{}, txHash common.Hash) (*big.Int, error) {
+	client, ok := backend.(rpcClientBackend)
+	if !ok {
+		return nil, nil
+	}
+	blockNumberStr, _, err := utils.BlockNumberByTransactionHash(ctx, client.RPCClient(), txHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction %s: %v", txHash.Hex(), err)
+	}
+	if blockNumberStr == nil {
+		return nil, fmt.Errorf("transaction %s is pending", txHash.Hex())
+	}
+	blockNumber, ok := new(big.Int).SetString(strings.TrimPrefix(*blockNumberStr, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid block number %s", *blockNumberStr)
+	}
+	return blockNumber, nil
+}
+
+// revertReason replays tx as a call against the block it was mined in, see
+// minedBlock, and decodes the reason it reverted with
+func revertReason(ctx context.Context, backend bind.ContractCaller, tx *types.Transaction) (string, error) {
+	var signer types.Signer = types.HomesteadSigner{}
+	if tx.Protected() {
+		signer = types.NewEIP155Signer(tx.ChainId())
+	}
+	from, err := types.Sender(signer, tx)
+	if err != nil {
+		return "", fmt.Errorf("failed to recover transaction sender: %v", err)
+	}
+
+	block, err := minedBlock(ctx, backend, tx.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	output, err := backend.CallContract(ctx, ethereum.CallMsg{
+		From:     from,
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}, block)
+	if err != nil {
+		return "", fmt.Errorf("failed to replay transaction: %v", err)
+	}
+	return decodeRevertReason(output)
+}
+
+// decodeRevertReason decodes the ABI encoded Error(string) payload of a revert,
+// returning an empty reason for a revert without one
+func decodeRevertReason(output []byte) (string, error) {
+	if len(output) < len(revertSelector) || !bytes.Equal(output[:len(revertSelector)], revertSelector) {
+		return "", nil
+	}
+
+	stringType, err := abi.NewType("string")
+	if err != nil {
+		return "", err
+	}
+	var reason string
+	if err := (abi.Arguments{{Type: stringType}}).Unpack(&reason, output[len(revertSelector):]); err != nil {
+		return "", fmt.Errorf("failed to decode revert reason: %v", err)
+	}
+	return reason, nil
+}
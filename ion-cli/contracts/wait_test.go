@@ -0,0 +1,310 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func Test_DecodeRevertReason(t *testing.T) {
+	stringType, _ := abi.NewType("string")
+	encoded, err := (abi.Arguments{{Type: stringType}}).Pack("Chain already exists")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reason, err := decodeRevertReason(append(revertSelector, encoded...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != "Chain already exists" {
+		t.Fatalf("ERROR unexpected revert reason %q", reason)
+	}
+
+	if reason, err := decodeRevertReason(nil); err != nil || reason != "" {
+		t.Fatal("ERROR expected no reason for an empty revert")
+	}
+}
+
+func Test_WaitMinedRevert(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	alloc := make(core.GenesisAlloc)
+	alloc[crypto.PubkeyToAddress(userKey.PublicKey)] = core.GenesisAccount{Balance: big.NewInt(1000000000)}
+	blockchain := backends.NewSimulatedBackend(alloc)
+
+	chainID := crypto.Keccak256Hash([]byte("test argument"))
+	contractChan, errChan := CompileAndDeployIon(ctx, blockchain, userKey, chainID, nil, nil)
+	blockchain.Commit()
+	<-contractChan
+	blockchain.Commit()
+	ionContractInstance, ok := <-contractChan
+	if !ok {
+		t.Fatal("ERROR deploying Ion", <-errChan)
+	}
+
+	// Ion refuses to register its own chain id
+	tx, err := ionContractInstance.WithBackend(blockchain).Transact(ctx, userKey, nil, nil, "addChain", chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockchain.Commit()
+
+	receipt, err := WaitMined(ctx, blockchain, tx)
+	if receipt == nil {
+		t.Fatal("ERROR expected the receipt of the reverted transaction")
+	}
+	revertErr, ok := err.(RevertError)
+	if !ok {
+		t.Fatalf("ERROR expected a RevertError, got %v", err)
+	}
+	if revertErr.Reason != "Cannot add this chain id to chain register" {
+		t.Fatalf("ERROR unexpected revert reason %q", revertErr.Reason)
+	}
+}
+
+func Test_WaitDeployedWithReceipt(t *testing.T) {
+	ctx := context.Background()
+
+	userKey, _ := crypto.GenerateKey()
+	alloc := make(core.GenesisAlloc)
+	alloc[crypto.PubkeyToAddress(userKey.PublicKey)] = core.GenesisAccount{Balance: big.NewInt(1000000000)}
+	blockchain := backends.NewSimulatedBackend(alloc)
+	opts := &TxOptions{GasLimit: 100000}
+
+	// returns the single byte runtime code 0xfe
+	tx, err := DeployContract(ctx, blockchain, userKey, "60fe60005360016000f3", "[]", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockchain.Commit()
+
+	addr, receipt, err := WaitDeployedWithReceipt(ctx, blockchain, tx)
+	if err != nil {
+		t.Fatal("ERROR waiting for deployment: ", err)
+	}
+	if receipt == nil || receipt.Status != types.ReceiptStatusSuccessful || receipt.GasUsed == 0 {
+		t.Fatal("ERROR expected the successful deployment receipt")
+	}
+	if addr != receipt.ContractAddress {
+		t.Fatalf("ERROR unexpected contract address %x", addr)
+	}
+
+	// reverts in the constructor
+	tx, err = DeployContract(ctx, blockchain, userKey, "60006000fd", "[]", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockchain.Commit()
+
+	addr, receipt, err = WaitDeployedWithReceipt(ctx, blockchain, tx)
+	var revertErr RevertError
+	if !errors.As(err, &revertErr) {
+		t.Fatalf("ERROR expected a RevertError, got %v", err)
+	}
+	if receipt == nil || receipt.Status != types.ReceiptStatusFailed {
+		t.Fatal("ERROR expected the failed deployment receipt")
+	}
+	if addr != (common.Address{}) {
+		t.Fatalf("ERROR expected no address for a reverted deployment, got %x", addr)
+	}
+}
+
+func Test_RevertReasonOfSuccess(t *testing.T) {
+	ctx := context.Background()
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	blockchain := backends.NewSimulatedBackend(core.GenesisAlloc{})
+
+	if _, err := RevertReason(ctx, blockchain, tx, nil); err == nil {
+		t.Fatal("ERROR expected an error without a receipt")
+	}
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful}
+	if _, err := RevertReason(ctx, blockchain, tx, receipt); err == nil {
+		t.Fatal("ERROR expected an error for a successful transaction")
+	}
+}
+
+func Test_DeployTimeout(t *testing.T) {
+	userKey, _ := crypto.GenerateKey()
+	blockchain, _, err := NewSimulatedBackend(SimAccount{Key: userKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the deployment is never committed, so waiting for it times out
+	opts := &TxOptions{Timeout: 50 * time.Millisecond, Logger: NopLogger{}}
+	contractChan, errChan := DeployPrecompiled(context.Background(), blockchain, userKey, "[]", "60fe60005360016000f3", opts)
+
+	if _, ok := <-contractChan; ok {
+		t.Fatal("ERROR expected no contract instance after the timeout")
+	}
+	err = <-errChan
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ERROR expected a deadline error, got %v", err)
+	}
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Stage != StageWait {
+		t.Fatalf("ERROR expected the wait stage to time out, got %v", err)
+	}
+}
+
+// reorgBackend mines a block on every head request and drops the receipt
+// once the head reaches dropAt, when set. With failHead the head requests
+// fail after the first one.
+type reorgBackend struct {
+	head     int64
+	dropAt   int64
+	failHead bool
+}
+
+func (b *reorgBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	if b.dropAt > 0 && b.head >= b.dropAt {
+		return nil, ethereum.NotFound
+	}
+	return &types.Receipt{Status: types.ReceiptStatusSuccessful, ContractAddress: common.HexToAddress("0x01")}, nil
+}
+
+func (b *reorgBackend) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return []byte{0x60}, nil
+}
+
+func (b *reorgBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if b.failHead && b.head > 0 {
+		return nil, errors.New("connection reset by peer")
+	}
+	b.head++
+	return &types.Header{Number: big.NewInt(b.head)}, nil
+}
+
+func Test_WaitConfirmations(t *testing.T) {
+	defer func(interval time.Duration) { ConfirmationsPollInterval = interval }(ConfirmationsPollInterval)
+	ConfirmationsPollInterval = time.Millisecond
+
+	tx := types.NewContractCreation(0, nil, 100000, big.NewInt(1), nil)
+	opts := &TxOptions{Confirmations: 3, Logger: NopLogger{}}
+
+	backend := &reorgBackend{}
+	if _, _, err := waitDeployed(context.Background(), backend, tx, "contract", opts); err != nil {
+		t.Fatal(err)
+	}
+	if backend.head < 4 {
+		t.Fatalf("ERROR expected 3 blocks on top of the deployment, head at %d", backend.head)
+	}
+
+	backend = &reorgBackend{dropAt: 2}
+	if _, _, err := waitDeployed(context.Background(), backend, tx, "contract", opts); !errors.Is(err, ErrReorged) {
+		t.Fatalf("ERROR expected the deployment to be reorged, got %v", err)
+	}
+
+	if _, err := WaitConfirmations(context.Background(), &flakyDeployBackend{}, tx, 1); err == nil {
+		t.Fatal("ERROR expected a backend without head blocks to be rejected")
+	}
+
+	// failing to count confirmations is not retried
+	backend = &reorgBackend{failHead: true}
+	opts.Retry = &RetryOptions{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	if _, _, err := waitDeployed(context.Background(), backend, tx, "contract", opts); err == nil || backend.head != 1 {
+		t.Fatalf("ERROR expected the confirmations to fail once, got head %d: %v", backend.head, err)
+	}
+}
+
+func Test_WaitConfirmationsFromMinedBlock(t *testing.T) {
+	defer func(interval time.Duration) { ConfirmationsPollInterval = interval }(ConfirmationsPollInterval)
+	ConfirmationsPollInterval = time.Millisecond
+
+	key, _ := crypto.GenerateKey()
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", &MinedTxService{tx: tx, block: 5}); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	// mined in block 5 with the head already at 6, so 3 blocks deep at 8
+	blocks := &reorgBackend{head: 6}
+	backend := struct {
+		*reorgBackend
+		rpcBackend
+	}{blocks, rpcBackend{client}}
+	if _, err := WaitConfirmations(context.Background(), backend, tx, 3); err != nil {
+		t.Fatal(err)
+	}
+	if blocks.head != 8 {
+		t.Fatalf("ERROR expected the confirmations counted from block 5, head at %d", blocks.head)
+	}
+}
+
+// MinedTxService answers eth_getTransactionByHash with tx mined in block
+type MinedTxService struct {
+	tx    *types.Transaction
+	block uint64
+}
+
+func (s *MinedTxService) GetTransactionByHash(hash common.Hash) (map[string]interface{}, error) {
+	if hash != s.tx.Hash() {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(s.tx)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	fields["blockNumber"] = hexutil.EncodeUint64(s.block)
+	return fields, nil
+}
+
+// rpcBackend is a backend keeping the RPC client of a MinedTxService
+type rpcBackend struct {
+	client *rpc.Client
+}
+
+func (b rpcBackend) RPCClient() *rpc.Client {
+	return b.client
+}
+
+func Test_MinedBlock(t *testing.T) {
+	ctx := context.Background()
+	key, _ := crypto.GenerateKey()
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", &MinedTxService{tx: tx, block: 42}); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	block, err := minedBlock(ctx, rpcBackend{client}, tx.Hash())
+	if err != nil || block == nil || block.Uint64() != 42 {
+		t.Fatalf("ERROR expected block 42, got %v: %v", block, err)
+	}
+	if _, err := minedBlock(ctx, rpcBackend{client}, common.HexToHash("0x01")); err == nil {
+		t.Fatal("ERROR expected an unknown transaction to fail")
+	}
+	// backends without an RPC client replay against the latest state
+	if block, err := minedBlock(ctx, struct{}{}, tx.Hash()); err != nil || block != nil {
+		t.Fatalf("ERROR expected no block without an RPC client, got %v: %v", block, err)
+	}
+}
@@ -0,0 +1,402 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// triggeredTopic is the topic of the Triggered(address) event of Trigger.sol
+var triggeredTopic = crypto.Keccak256Hash([]byte("Triggered(address)"))
+
+// TriggerEvent is a Triggered event emitted by a trigger contract, the event
+// a proof is built for
+type TriggerEvent struct {
+	// BlockNumber of the block including the trigger transaction
+	BlockNumber uint64
+	// BlockHash of the block including the trigger transaction
+	BlockHash common.Hash
+	// TxHash of the trigger transaction
+	TxHash common.Hash
+	// Caller of the trigger contract
+	Caller common.Address
+	// Args of the event by name, decoded against the ABI given to
+	// WatchTriggerEvents. Nil for the events of GetTriggerEvents.
+	Args map[string]interface{}
+	// Log of the event
+	Log types.Log
+}
+
+// logKey identifies a log in the chain
+type logKey struct {
+	txHash common.Hash
+	index  uint
+}
+
+// TriggerEventsPollInterval is the interval WatchTriggerEvents polls for logs
+// at when the backend doesn't support subscriptions, e.g. over HTTP
+var TriggerEventsPollInterval = 4 * time.Second
+
+// WatchTriggerEvents streams the Triggered events of the trigger contract at
+// triggerAddr as they are mined, with their arguments decoded against
+// triggerABI. A triggerABI without a Triggered event watches the
+// Triggered(address) event of Trigger.sol, leaving Args nil. When the
+// subscription drops it subscribes again, DefaultRetryAttempts times at most,
+// and catches up from the last block seen so no event is missed. Backends
+// without subscriptions are polled every TriggerEventsPollInterval instead,
+// from their head block when the watch starts, and must report it.
+// The error channel gets the error that stopped the watch. Both channels are
+// closed when ctx is done or the watch fails.
+func WatchTriggerEvents(
+	ctx context.Context,
+	client bind.ContractFilterer,
+	triggerAddr common.Address,
+	triggerABI abi.ABI,
+) (<-chan TriggerEvent, <-chan error) {
+	resChan := make(chan TriggerEvent)
+	errChan := make(chan error, 1)
+	logger := packageLogger
+	decoder := newTriggerDecoder(triggerABI)
+
+	query := ethereum.FilterQuery{
+		Addresses: []common.Address{triggerAddr},
+		Topics:    [][]common.Hash{{decoder.topic}},
+	}
+
+	go func() {
+		defer close(errChan)
+		defer close(resChan)
+
+		// logs of the last block seen, to skip them when catching up
+		var lastBlock *uint64
+		seen := make(map[logKey]bool)
+
+		emit := func(vlog types.Log) bool {
+			if vlog.Removed || seen[logKey{vlog.TxHash, vlog.Index}] {
+				return true
+			}
+			event, err := decoder.decode(vlog)
+			if err != nil {
+				errChan <- err
+				return false
+			}
+			if lastBlock == nil || vlog.BlockNumber > *lastBlock {
+				blockNumber := vlog.BlockNumber
+				lastBlock = &blockNumber
+				seen = make(map[logKey]bool)
+			}
+			seen[logKey{vlog.TxHash, vlog.Index}] = true
+
+			select {
+			case resChan <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		newBackoff := func() *backoff {
+			return &backoff{attempts: DefaultRetryAttempts, delay: DefaultRetryDelay}
+		}
+		retry := newBackoff()
+		for attempt := 1; ; attempt++ {
+			logs := make(chan types.Log)
+			sub, err := client.SubscribeFilterLogs(ctx, query, logs)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if isNotificationsUnsupported(err) {
+					logger.Info("Backend doesn't support subscriptions, polling trigger events", "interval", TriggerEventsPollInterval)
+					if err := pollLogs(ctx, client, query, lastBlock, emit); err != nil {
+						errChan <- err
+					}
+					return
+				}
+				if attempt >= retry.attempts {
+					errChan <- fmt.Errorf("failed to subscribe to trigger events: %v", err)
+					return
+				}
+				logger.Warn("Subscribing to trigger events failed, retrying", "delay", retry.delay, "err", err)
+				if retry.wait(ctx) != nil {
+					return
+				}
+				continue
+			}
+
+			// catch up with the events emitted while the subscription was down
+			if lastBlock != nil {
+				catchUp := query
+				catchUp.FromBlock = new(big.Int).SetUint64(*lastBlock)
+				missed, err := client.FilterLogs(ctx, catchUp)
+				if err != nil {
+					sub.Unsubscribe()
+					if ctx.Err() == nil {
+						errChan <- fmt.Errorf("failed to get missed trigger events: %v", err)
+					}
+					return
+				}
+				for _, vlog := range missed {
+					if !emit(vlog) {
+						sub.Unsubscribe()
+						return
+					}
+				}
+			}
+
+			dropped := watchLogs(ctx, sub, logs, emit)
+			sub.Unsubscribe()
+			if dropped == nil {
+				return
+			}
+			retry, attempt = newBackoff(), 0
+			logger.Warn("Trigger events subscription dropped, subscribing again", "delay", retry.delay, "err", dropped)
+			if retry.wait(ctx) != nil {
+				return
+			}
+		}
+	}()
+
+	return resChan, errChan
+}
+
+// TriggerEventsChunkSize is the number of blocks GetTriggerEvents asks logs
+// for at once, staying under the result limits of the RPC providers
+const TriggerEventsChunkSize = 2000
+
+// headerReader is implemented by clients which can tell the latest block
+type headerReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// GetTriggerEvents returns the Triggered events of the trigger contract at
+// triggerAddr emitted between fromBlock and toBlock, both included. fromBlock
+// defaults to the genesis and toBlock to the latest block. The logs are
+// filtered TriggerEventsChunkSize blocks at a time, and the events of the
+// chunks already filtered are returned along with the error of a failed one.
+func GetTriggerEvents(
+	ctx context.Context,
+	client bind.ContractFilterer,
+	triggerAddr common.Address,
+	fromBlock *big.Int,
+	toBlock *big.Int,
+) ([]TriggerEvent, error) {
+	from := new(big.Int)
+	if fromBlock != nil {
+		from.Set(fromBlock)
+	}
+	to := toBlock
+	if to == nil {
+		reader, ok := client.(headerReader)
+		if !ok {
+			// the range can't be split without knowing where it ends
+			return filterTriggerEvents(ctx, client, triggerAddr, from, nil)
+		}
+		header, err := reader.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest block: %v", err)
+		}
+		to = header.Number
+	}
+
+	var events []TriggerEvent
+	chunk := big.NewInt(TriggerEventsChunkSize)
+	for from.Cmp(to) <= 0 {
+		if err := ctx.Err(); err != nil {
+			return events, err
+		}
+		end := new(big.Int).Add(from, chunk)
+		end.Sub(end, big.NewInt(1))
+		if end.Cmp(to) > 0 {
+			end.Set(to)
+		}
+
+		chunkEvents, err := filterTriggerEvents(ctx, client, triggerAddr, from, end)
+		if err != nil {
+			return events, fmt.Errorf("blocks %v to %v: %v", from, end, err)
+		}
+		events = append(events, chunkEvents...)
+		from = end.Add(end, big.NewInt(1))
+	}
+	return events, nil
+}
+
+// filterTriggerEvents returns the trigger events between from and to
+func filterTriggerEvents(ctx context.Context, client bind.ContractFilterer, triggerAddr common.Address, from, to *big.Int) ([]TriggerEvent, error) {
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: from,
+		ToBlock:   to,
+		Addresses: []common.Address{triggerAddr},
+		Topics:    [][]common.Hash{{triggeredTopic}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter trigger events: %v", err)
+	}
+
+	events := make([]TriggerEvent, 0, len(logs))
+	for _, vlog := range logs {
+		if vlog.Removed {
+			continue
+		}
+		event, err := decodeTriggerEvent(vlog)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// pollLogs passes the logs matching query to emit every
+// TriggerEventsPollInterval until emit fails or ctx is done, which return nil.
+// Logs are asked from lastBlock when set, or else from the head block when
+// polling starts like a subscription would, TriggerEventsChunkSize blocks at
+// most at once. The client must report its head block, like ethclient.
+func pollLogs(ctx context.Context, client bind.ContractFilterer, query ethereum.FilterQuery, lastBlock *uint64, emit func(types.Log) bool) error {
+	reader, ok := client.(headerReader)
+	if !ok {
+		return fmt.Errorf("backend can't report its head block to poll trigger events")
+	}
+	var from *big.Int
+	if lastBlock != nil {
+		from = new(big.Int).SetUint64(*lastBlock)
+	}
+
+	ticker := time.NewTicker(TriggerEventsPollInterval)
+	defer ticker.Stop()
+	for {
+		header, err := reader.HeaderByNumber(ctx, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to get latest block: %v", err)
+		}
+		if from == nil {
+			from = header.Number
+		}
+
+		if from.Cmp(header.Number) <= 0 {
+			to := new(big.Int).Add(from, big.NewInt(TriggerEventsChunkSize-1))
+			if to.Cmp(header.Number) > 0 {
+				to = header.Number
+			}
+			poll := query
+			poll.FromBlock, poll.ToBlock = from, to
+
+			logs, err := client.FilterLogs(ctx, poll)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				if !isTransientError(err) {
+					return fmt.Errorf("failed to poll trigger events: %v", err)
+				}
+				packageLogger.Warn("Polling trigger events failed, retrying", "delay", TriggerEventsPollInterval, "err", err)
+				logs = nil
+			} else {
+				from = new(big.Int).Add(to, big.NewInt(1))
+			}
+			for _, vlog := range logs {
+				if !emit(vlog) {
+					return nil
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// isNotificationsUnsupported reports whether a subscription failed because the
+// backend has no notifications, like an HTTP endpoint
+func isNotificationsUnsupported(err error) bool {
+	return strings.Contains(err.Error(), "notifications not supported")
+}
+
+// watchLogs passes the logs of sub to emit until emit fails or ctx is done,
+// which return nil, or until the subscription drops, which returns its error
+func watchLogs(ctx context.Context, sub ethereum.Subscription, logs <-chan types.Log, emit func(types.Log) bool) error {
+	for {
+		select {
+		case vlog := <-logs:
+			if !emit(vlog) {
+				return nil
+			}
+		case err := <-sub.Err():
+			if err == nil {
+				err = fmt.Errorf("subscription closed")
+			}
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// triggerDecoder decodes the Triggered events of a trigger contract ABI
+type triggerDecoder struct {
+	topic common.Hash
+	event *abi.Event
+}
+
+// newTriggerDecoder returns the decoder of the Triggered event of triggerABI,
+// or of the Triggered(address) event of Trigger.sol when it has none
+func newTriggerDecoder(triggerABI abi.ABI) triggerDecoder {
+	if event, ok := triggerABI.Events["Triggered"]; ok && !event.Anonymous {
+		return triggerDecoder{topic: event.Id(), event: &event}
+	}
+	return triggerDecoder{topic: triggeredTopic}
+}
+
+// decode decodes a trigger event log against the ABI of the decoder, or as a
+// Triggered(address) event when it has none. The Caller of events decoded
+// against an ABI is their caller argument, if they have one.
+func (d triggerDecoder) decode(vlog types.Log) (TriggerEvent, error) {
+	if d.event == nil {
+		return decodeTriggerEvent(vlog)
+	}
+	args, err := decodeEventArgs(*d.event, &vlog)
+	if err != nil {
+		return TriggerEvent{}, err
+	}
+	event := TriggerEvent{
+		BlockNumber: vlog.BlockNumber,
+		BlockHash:   vlog.BlockHash,
+		TxHash:      vlog.TxHash,
+		Args:        args,
+		Log:         vlog,
+	}
+	if caller, ok := args["caller"].(common.Address); ok {
+		event.Caller = caller
+	}
+	return event, nil
+}
+
+// decodeTriggerEvent decodes a Triggered(address) log
+func decodeTriggerEvent(vlog types.Log) (TriggerEvent, error) {
+	if len(vlog.Data) < common.HashLength {
+		return TriggerEvent{}, fmt.Errorf("trigger event in transaction %s has %d bytes of data", vlog.TxHash.Hex(), len(vlog.Data))
+	}
+	return TriggerEvent{
+		BlockNumber: vlog.BlockNumber,
+		BlockHash:   vlog.BlockHash,
+		TxHash:      vlog.TxHash,
+		Caller:      common.BytesToAddress(vlog.Data[:common.HashLength]),
+		Log:         vlog,
+	}, nil
+}
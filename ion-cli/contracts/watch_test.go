@@ -0,0 +1,236 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package contract
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// droppingSubscription fails as soon as it is created when err is set
+type droppingSubscription struct {
+	err chan error
+}
+
+func (s *droppingSubscription) Unsubscribe() {}
+
+func (s *droppingSubscription) Err() <-chan error {
+	return s.err
+}
+
+// droppingFilterer sends one log on a first subscription which then drops,
+// and has another log to catch up with when subscribing again
+type droppingFilterer struct {
+	subscriptions int
+	logs          []types.Log
+}
+
+func (f *droppingFilterer) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	for _, vlog := range f.logs {
+		if vlog.BlockNumber >= query.FromBlock.Uint64() {
+			logs = append(logs, vlog)
+		}
+	}
+	return logs, nil
+}
+
+func (f *droppingFilterer) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	f.subscriptions++
+	sub := &droppingSubscription{err: make(chan error, 1)}
+	if f.subscriptions == 1 {
+		go func() {
+			ch <- f.logs[0]
+			sub.err <- errors.New("connection reset by peer")
+		}()
+	}
+	return sub, nil
+}
+
+func triggerLog(blockNumber uint64, caller common.Address) types.Log {
+	return types.Log{
+		Topics:      []common.Hash{triggeredTopic},
+		Data:        common.LeftPadBytes(caller.Bytes(), 32),
+		BlockNumber: blockNumber,
+		TxHash:      common.BigToHash(new(big.Int).SetUint64(blockNumber)),
+	}
+}
+
+func Test_WatchTriggerEventsResubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	callers := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+	filterer := &droppingFilterer{logs: []types.Log{triggerLog(1, callers[0]), triggerLog(2, callers[1])}}
+
+	eventChan, errChan := WatchTriggerEvents(ctx, filterer, common.Address{}, abi.ABI{})
+	for _, caller := range callers {
+		event, ok := <-eventChan
+		if !ok {
+			t.Fatal("ERROR watching trigger events: ", <-errChan)
+		}
+		if event.Caller != caller {
+			t.Fatalf("ERROR expected caller %x, got %x", caller, event.Caller)
+		}
+	}
+	if filterer.subscriptions != 2 {
+		t.Fatalf("ERROR expected 2 subscriptions, got %d", filterer.subscriptions)
+	}
+
+	cancel()
+	if _, ok := <-eventChan; ok {
+		t.Fatal("ERROR expected the log already seen to be skipped")
+	}
+}
+
+// pollingFilterer has no subscriptions, like an HTTP endpoint. Its head moves
+// a block forward every time it is asked, up to the block of the last log.
+type pollingFilterer struct {
+	droppingFilterer
+	head uint64
+}
+
+func (f *pollingFilterer) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	header := &types.Header{Number: new(big.Int).SetUint64(f.head)}
+	if f.head < f.logs[len(f.logs)-1].BlockNumber {
+		f.head++
+	}
+	return header, nil
+}
+
+func (f *pollingFilterer) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	var logs []types.Log
+	for _, vlog := range f.logs {
+		if vlog.BlockNumber >= query.FromBlock.Uint64() && vlog.BlockNumber <= query.ToBlock.Uint64() {
+			logs = append(logs, vlog)
+		}
+	}
+	return logs, nil
+}
+
+func (f *pollingFilterer) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("notifications not supported")
+}
+
+func Test_WatchTriggerEventsPolling(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	defer func(interval time.Duration) { TriggerEventsPollInterval = interval }(TriggerEventsPollInterval)
+	TriggerEventsPollInterval = time.Millisecond
+
+	triggerABI, err := abi.JSON(strings.NewReader(`[{"anonymous":false,"inputs":[{"indexed":false,"name":"caller","type":"address"}],"name":"Triggered","type":"event"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the event of block 1 was emitted before the watch started at block 2
+	callers := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+	logs := []types.Log{triggerLog(1, common.HexToAddress("0x03")), triggerLog(2, callers[0]), triggerLog(3, callers[1])}
+	filterer := &pollingFilterer{droppingFilterer: droppingFilterer{logs: logs}, head: 2}
+
+	eventChan, errChan := WatchTriggerEvents(ctx, filterer, common.Address{}, triggerABI)
+	for _, caller := range callers {
+		event, ok := <-eventChan
+		if !ok {
+			t.Fatal("ERROR polling trigger events: ", <-errChan)
+		}
+		if event.Args["caller"] != caller || event.Caller != caller {
+			t.Fatalf("ERROR expected caller argument %x, got %v", caller, event.Args["caller"])
+		}
+	}
+
+	// later polls only return the events already seen
+	select {
+	case event := <-eventChan:
+		t.Fatalf("ERROR unexpected event of block %d", event.BlockNumber)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// chunkFilterer has one trigger log per block and fails on the blocks from failFrom
+type chunkFilterer struct {
+	droppingFilterer
+	queries  int
+	failFrom uint64
+}
+
+func (f *chunkFilterer) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	f.queries++
+	if f.failFrom != 0 && query.FromBlock.Uint64() >= f.failFrom {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	var logs []types.Log
+	for block := query.FromBlock.Uint64(); block <= query.ToBlock.Uint64(); block++ {
+		logs = append(logs, triggerLog(block, common.HexToAddress("0x01")))
+	}
+	return logs, nil
+}
+
+func Test_GetTriggerEvents(t *testing.T) {
+	ctx := context.Background()
+
+	filterer := &chunkFilterer{}
+	events, err := GetTriggerEvents(ctx, filterer, common.Address{}, big.NewInt(1), big.NewInt(2*TriggerEventsChunkSize+10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2*TriggerEventsChunkSize+10 || filterer.queries != 3 {
+		t.Fatalf("ERROR expected %d events in 3 queries, got %d in %d", 2*TriggerEventsChunkSize+10, len(events), filterer.queries)
+	}
+
+	// the events of the chunks before the failing one are returned
+	filterer = &chunkFilterer{failFrom: TriggerEventsChunkSize + 1}
+	events, err = GetTriggerEvents(ctx, filterer, common.Address{}, big.NewInt(1), big.NewInt(2*TriggerEventsChunkSize))
+	if err == nil {
+		t.Fatal("ERROR expected the failure of the second chunk")
+	}
+	if len(events) != TriggerEventsChunkSize {
+		t.Fatalf("ERROR expected the %d events of the first chunk, got %d", TriggerEventsChunkSize, len(events))
+	}
+}
+
+func Test_DialBackend(t *testing.T) {
+	ctx := context.Background()
+
+	backend, err := DialBackend(ctx, "http://127.0.0.1:8545")
+	if err != nil || backend == nil {
+		t.Fatalf("ERROR expected an http backend, got %v", err)
+	}
+
+	// a failed dial returns no backend rather than a nil client in an interface
+	backend, err = DialBackend(ctx, "unix:///nonexistent/geth.ipc")
+	if err == nil || backend != nil {
+		t.Fatal("ERROR expected dialing a missing socket to fail")
+	}
+	if _, err := DialBackend(ctx, "ftp://127.0.0.1:8545"); err == nil {
+		t.Fatal("ERROR expected an unsupported scheme to be rejected")
+	}
+}
+
+func Test_TriggerDecoderIndexedArgs(t *testing.T) {
+	triggerABI, err := abi.JSON(strings.NewReader(`[{"anonymous":false,"inputs":[{"indexed":true,"name":"caller","type":"address"}],"name":"Triggered","type":"event"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := newTriggerDecoder(triggerABI)
+
+	// the caller is in a topic and the log has no data
+	caller := common.HexToAddress("0x01")
+	vlog := types.Log{Topics: []common.Hash{decoder.topic, common.BytesToHash(caller.Bytes())}}
+	event, err := decoder.decode(vlog)
+	if err != nil {
+		t.Fatal("ERROR decoding indexed trigger event: ", err)
+	}
+	if event.Caller != caller {
+		t.Fatalf("ERROR expected caller %x, got %x", caller, event.Caller)
+	}
+}
@@ -25,9 +25,21 @@ func main() {
 		clientFrom := utils.ClientRPC(setup.AddrFrom)
 
 		// Compile contracts to use in sending transactions
-		Validation := contract.CompileContract("Validation")
-		Function := contract.CompileContract("Function")
-		Trigger := contract.CompileContract("Trigger")
+		Validation, err := contract.CompileContract("Validation", nil)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(3)
+		}
+		Function, err := contract.CompileContract("Function", nil)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(3)
+		}
+		Trigger, err := contract.CompileContract("Trigger", nil)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(3)
+		}
 		printInfo(setup)
 
 		// Launch the CLI
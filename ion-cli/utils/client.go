@@ -6,9 +6,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/big"
+	"net/url"
+	"time"
 
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -25,6 +29,50 @@ func Client(url string) *ethclient.Client {
 	return client
 }
 
+// Dial connects to the node at rpcURL, choosing the transport from its scheme:
+// HTTP for http:// and https://, websockets for ws:// and wss://, and IPC for
+// a plain path to the node socket, or an ipc:// or unix:// URL of it
+func Dial(ctx context.Context, rpcURL string) (*ethclient.Client, error) {
+	c, err := DialRPC(ctx, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(c), nil
+}
+
+// DialRPC connects to the node at rpcURL like Dial, returning the RPC client
+// for the calls ethclient lacks, e.g. BlockNumberByTransactionHash
+func DialRPC(ctx context.Context, rpcURL string) (*rpc.Client, error) {
+	u, err := url.Parse(rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rpc url %q: %v", rpcURL, err)
+	}
+
+	var c *rpc.Client
+	switch u.Scheme {
+	case "http", "https":
+		c, err = rpc.DialHTTP(rpcURL)
+	case "ws", "wss":
+		c, err = rpc.DialWebsocket(ctx, rpcURL, "")
+	case "", "ipc", "unix":
+		c, err = rpc.DialIPC(ctx, u.Path)
+	default:
+		return nil, fmt.Errorf("unsupported rpc url scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", rpcURL, err)
+	}
+	return c, nil
+}
+
+// DialWithTimeout connects to the node at rpcURL like Dial, failing when the
+// connection isn't established within timeout
+func DialWithTimeout(ctx context.Context, rpcURL string, timeout time.Duration) (*ethclient.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return Dial(ctx, rpcURL)
+}
+
 // GetBlockTxReceipts get the receipts for all the transactions in a block
 func GetBlockTxReceipts(ec *ethclient.Client, block *types.Block) []*types.Receipt {
 	var receiptsArr []*types.Receipt
@@ -38,6 +86,17 @@ func GetBlockTxReceipts(ec *ethclient.Client, block *types.Block) []*types.Recei
 	return receiptsArr
 }
 
+// DetectChainID returns the chain id the node of c reports with eth_chainId,
+// along with its bytes32 encoding as used for Ion chain ids
+func DetectChainID(ctx context.Context, c *rpc.Client) (*big.Int, common.Hash, error) {
+	var result hexutil.Big
+	if err := c.CallContext(ctx, &result, "eth_chainId"); err != nil {
+		return nil, common.Hash{}, fmt.Errorf("failed to get chain id: %v", err)
+	}
+	chainID := (*big.Int)(&result)
+	return chainID, common.BigToHash(chainID), nil
+}
+
 // -------
 // Since you can't get a block by giving it the transaction hash in go-ethereum
 // the only solution was to replicate their code and add that feature to it
@@ -5,8 +5,13 @@ import (
 	"context"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
 )
 
 const URL = "https://mainnet.infura.io"
@@ -69,3 +74,38 @@ func TestBlockNumberByTransactionHash(t *testing.T) {
 		t.Errorf("Blocknumber retrieved by transaction hash is not right. It expected %s but got %s\n", blockNumber.String(), bNumberInt.String())
 	}
 }
+
+type ChainIDService struct{}
+
+func (ChainIDService) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(4))
+}
+
+func TestDetectChainID(t *testing.T) {
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", ChainIDService{}); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	chainID, chainIDHash, err := utils.DetectChainID(context.Background(), client)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(4), chainID)
+	assert.Equal(t, common.BigToHash(big.NewInt(4)), chainIDHash)
+}
+
+func TestDial(t *testing.T) {
+	ctx := context.Background()
+
+	client, err := utils.Dial(ctx, "http://127.0.0.1:8545")
+	assert.Nil(t, err)
+	assert.NotNil(t, client)
+
+	_, err = utils.Dial(ctx, "ftp://127.0.0.1:8545")
+	assert.NotNil(t, err)
+
+	_, err = utils.DialWithTimeout(ctx, "/nonexistent/geth.ipc", time.Second)
+	assert.NotNil(t, err)
+}
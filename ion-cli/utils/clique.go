@@ -0,0 +1,61 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// CliqueVanityLength is the length of the vanity prefix of clique extra data
+const CliqueVanityLength = 32
+
+// RecoverCliqueSigner recovers the address of the signer that sealed a clique
+// header from the signature at the end of its extra data
+func RecoverCliqueSigner(header *types.Header) (common.Address, error) {
+	signer, _, _, err := ExtractCliqueSignature(header)
+	return signer, err
+}
+
+// ExtractCliqueSignature splits a clique header into what the Ion clique
+// validation contract takes: the signer recovered from the seal, the RLP
+// encoding of the header without the seal, and the 65 byte seal itself
+func ExtractCliqueSignature(header *types.Header) (signer common.Address, unsignedRLP []byte, signature []byte, err error) {
+	if len(header.Extra) < CliqueVanityLength+CliqueSealLength {
+		return common.Address{}, nil, nil, fmt.Errorf("extra data of %d bytes is too short for clique", len(header.Extra))
+	}
+	signature = common.CopyBytes(header.Extra[len(header.Extra)-CliqueSealLength:])
+
+	unsignedRLP, err = EncodeBlockHeader(header, Clique)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+
+	pubkey, err := crypto.Ecrecover(crypto.Keccak256(unsignedRLP), signature)
+	if err != nil {
+		return common.Address{}, nil, nil, fmt.Errorf("failed recovering clique signer: %v", err)
+	}
+	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return signer, unsignedRLP, signature, nil
+}
+
+// ParseCliqueValidators returns the authorized signers listed in the extra data
+// of a clique header, between the vanity and the seal. Only epoch (checkpoint)
+// headers list the signers, other headers return an empty list.
+func ParseCliqueValidators(header *types.Header) ([]common.Address, error) {
+	if len(header.Extra) < CliqueVanityLength+CliqueSealLength {
+		return nil, fmt.Errorf("extra data of %d bytes is too short for clique", len(header.Extra))
+	}
+	signersBytes := header.Extra[CliqueVanityLength : len(header.Extra)-CliqueSealLength]
+	if len(signersBytes)%common.AddressLength != 0 {
+		return nil, fmt.Errorf("clique signers list of %d bytes is not a multiple of %d", len(signersBytes), common.AddressLength)
+	}
+
+	validators := make([]common.Address, len(signersBytes)/common.AddressLength)
+	for i := range validators {
+		copy(validators[i][:], signersBytes[i*common.AddressLength:])
+	}
+	return validators, nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CliqueSignerAndValidators(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	validators := []common.Address{signer, common.HexToAddress("0x42eb768f2244c8811c63729a21a3569731535f06")}
+
+	// epoch header: vanity, signers and an empty seal to sign over
+	extra := bytes.Repeat([]byte{0x01}, utils.CliqueVanityLength)
+	for _, validator := range validators {
+		extra = append(extra, validator.Bytes()...)
+	}
+	extra = append(extra, make([]byte, utils.CliqueSealLength)...)
+	header := &types.Header{
+		Difficulty: big.NewInt(2),
+		Number:     big.NewInt(30000),
+		GasLimit:   4712388,
+		Time:       big.NewInt(1492010458),
+		Extra:      extra,
+	}
+
+	signingHash, err := utils.CliqueSigningHash(header)
+	assert.Nil(t, err)
+	seal, err := crypto.Sign(signingHash.Bytes(), key)
+	assert.Nil(t, err)
+	copy(header.Extra[len(header.Extra)-utils.CliqueSealLength:], seal)
+
+	recovered, err := utils.RecoverCliqueSigner(header)
+	assert.Nil(t, err)
+	assert.Equal(t, signer, recovered)
+
+	extracted, unsignedRLP, signature, err := utils.ExtractCliqueSignature(header)
+	assert.Nil(t, err)
+	assert.Equal(t, signer, extracted)
+	assert.Equal(t, seal, signature)
+	assert.Equal(t, signingHash, crypto.Keccak256Hash(unsignedRLP))
+
+	parsed, err := utils.ParseCliqueValidators(header)
+	assert.Nil(t, err)
+	assert.Equal(t, validators, parsed)
+
+	// non epoch headers carry no signers
+	header.Extra = append(header.Extra[:utils.CliqueVanityLength], seal...)
+	parsed, err = utils.ParseCliqueValidators(header)
+	assert.Nil(t, err)
+	assert.Empty(t, parsed)
+
+	header.Extra = append(header.Extra[:utils.CliqueVanityLength], append([]byte{0x01}, seal...)...)
+	_, err = utils.ParseCliqueValidators(header)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// EthashSeal holds the inputs of the validation of an ethash header that don't
+// depend on the DAG
+type EthashSeal struct {
+	// HeaderRLP is the full RLP encoded header, its keccak256 is the block hash
+	HeaderRLP []byte
+	// SealHash is the hash of the header without the mix digest and nonce,
+	// the hash the proof of work is computed over
+	SealHash common.Hash
+	// MixDigest of the proof of work
+	MixDigest common.Hash
+	// Nonce of the proof of work
+	Nonce types.BlockNonce
+	// Difficulty the proof of work meets
+	Difficulty *big.Int
+}
+
+// ExtractEthashSeal returns the proof of work inputs of an ethash header,
+// checking its seal is well formed. It doesn't verify the proof of work.
+func ExtractEthashSeal(header *types.Header) (*EthashSeal, error) {
+	if len(header.Extra) >= CliqueVanityLength+CliqueSealLength && header.MixDigest == (common.Hash{}) {
+		return nil, fmt.Errorf("header %v looks like a clique header, use ExtractCliqueSignature", header.Number)
+	}
+	if uint64(len(header.Extra)) > params.MaximumExtraDataSize {
+		return nil, fmt.Errorf("extra data of %d bytes is too long for ethash", len(header.Extra))
+	}
+	if header.Difficulty == nil || header.Difficulty.Sign() <= 0 {
+		return nil, fmt.Errorf("header %v has no difficulty", header.Number)
+	}
+	if header.MixDigest == (common.Hash{}) {
+		return nil, fmt.Errorf("header %v has no mix digest", header.Number)
+	}
+
+	headerRLP, err := EncodeBlockHeader(header, Ethash)
+	if err != nil {
+		return nil, err
+	}
+	sealRLP, err := rlp.EncodeToBytes([]interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding header seal fields: %v", err)
+	}
+
+	return &EthashSeal{
+		HeaderRLP:  headerRLP,
+		SealHash:   crypto.Keccak256Hash(sealRLP),
+		MixDigest:  header.MixDigest,
+		Nonce:      header.Nonce,
+		Difficulty: new(big.Int).Set(header.Difficulty),
+	}, nil
+}
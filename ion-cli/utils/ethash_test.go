@@ -0,0 +1,51 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractEthashSeal(t *testing.T) {
+	header := &types.Header{
+		Difficulty: big.NewInt(131072),
+		Number:     big.NewInt(1),
+		GasLimit:   5000,
+		Time:       big.NewInt(1438269988),
+		Extra:      []byte("Geth/v1.0.0"),
+		MixDigest:  common.HexToHash("0x969b900de27b6ac6a67742365dd65f55a0526c41fd18e1b16f1a1215c2e66f59"),
+		Nonce:      types.EncodeNonce(0x539bd4979fef1ec4),
+	}
+
+	seal, err := utils.ExtractEthashSeal(header)
+	assert.Nil(t, err)
+	assert.Equal(t, header.Hash(), crypto.Keccak256Hash(seal.HeaderRLP))
+	assert.Equal(t, header.MixDigest, seal.MixDigest)
+	assert.Equal(t, header.Nonce, seal.Nonce)
+	assert.Equal(t, header.Difficulty, seal.Difficulty)
+
+	// the seal hash doesn't cover the proof of work
+	sealed := types.CopyHeader(header)
+	sealed.Nonce = types.EncodeNonce(1)
+	resealed, err := utils.ExtractEthashSeal(sealed)
+	assert.Nil(t, err)
+	assert.Equal(t, seal.SealHash, resealed.SealHash)
+
+	clique := types.CopyHeader(header)
+	clique.Extra = bytes.Repeat([]byte{0x01}, utils.CliqueVanityLength+utils.CliqueSealLength)
+	clique.MixDigest = common.Hash{}
+	_, err = utils.ExtractEthashSeal(clique)
+	assert.NotNil(t, err)
+
+	noDifficulty := types.CopyHeader(header)
+	noDifficulty.Difficulty = big.NewInt(0)
+	_, err = utils.ExtractEthashSeal(noDifficulty)
+	assert.NotNil(t, err)
+}
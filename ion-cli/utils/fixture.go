@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ProofFixture holds the proofs of a transaction as the test vectors of the
+// Ion tests, like TEST_PATH, TEST_TX_VALUE and TEST_TX_NODES, so they can be
+// regenerated against a real chain rather than maintained by hand
+type ProofFixture struct {
+	TxHash      common.Hash
+	BlockHash   common.Hash
+	BlockNumber *big.Int
+	Proof       MerkleProof
+}
+
+// fixtureValue is a named test vector
+type fixtureValue struct {
+	name  string
+	value []byte
+}
+
+// GenerateTestVectors generates the proofs of the transaction txHash like
+// GenerateProof and returns them as a fixture. The proofs are always checked
+// against the block header, so a bad vector never makes it into a test.
+func GenerateTestVectors(ctx context.Context, client *rpc.Client, txHash common.Hash) (ProofFixture, error) {
+	block, idx, err := transactionBlock(ctx, client, txHash)
+	if err != nil {
+		return ProofFixture{}, err
+	}
+	receipts, format, err := blockReceipts(ctx, client, block)
+	if err != nil {
+		return ProofFixture{}, err
+	}
+	proof, err := NewMerkleProofWithFormat(block.Transactions(), receipts, idx, format)
+	if err != nil {
+		return ProofFixture{}, err
+	}
+	if err := proof.Validate(block.Header()); err != nil {
+		return ProofFixture{}, err
+	}
+
+	return ProofFixture{
+		TxHash:      txHash,
+		BlockHash:   block.Hash(),
+		BlockNumber: block.Number(),
+		Proof:       *proof,
+	}, nil
+}
+
+// values returns the test vectors of the fixture in the order of the tests
+func (f ProofFixture) values() []fixtureValue {
+	return []fixtureValue{
+		{"TEST_PATH", f.Proof.TxPath},
+		{"TEST_TX_VALUE", f.Proof.TxValue},
+		{"TEST_TX_NODES", f.Proof.TxNodes},
+		{"TEST_RECEIPT_VALUE", f.Proof.ReceiptValue},
+		{"TEST_RECEIPT_NODES", f.Proof.ReceiptNodes},
+	}
+}
+
+// comment describes the transaction the vectors were generated from
+func (f ProofFixture) comment() string {
+	return fmt.Sprintf("// proofs of transaction %s in block %v (%s)\n", f.TxHash.Hex(), f.BlockNumber, f.BlockHash.Hex())
+}
+
+// GoHex returns the vectors as Go hex string variables, like in proof_test.go
+func (f ProofFixture) GoHex() string {
+	var b strings.Builder
+	b.WriteString(f.comment())
+	for _, v := range f.values() {
+		fmt.Fprintf(&b, "var %s = %q\n", v.name, hex.EncodeToString(v.value))
+	}
+	return b.String()
+}
+
+// GoBytes returns the vectors as Go byte slice variables
+func (f ProofFixture) GoBytes() string {
+	var b strings.Builder
+	b.WriteString(f.comment())
+	for _, v := range f.values() {
+		fmt.Fprintf(&b, "var %s = []byte{", v.name)
+		for i, c := range v.value {
+			if i%16 == 0 {
+				b.WriteString("\n\t")
+			} else {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(&b, "0x%02x,", c)
+		}
+		b.WriteString("\n}\n")
+	}
+	return b.String()
+}
+
+// Solidity returns the vectors as Solidity bytes constants
+func (f ProofFixture) Solidity() string {
+	var b strings.Builder
+	b.WriteString(f.comment())
+	for _, v := range f.values() {
+		fmt.Fprintf(&b, "bytes constant %s = hex\"%s\";\n", v.name, hex.EncodeToString(v.value))
+	}
+	return b.String()
+}
+
+// JS returns the vectors as the 0x prefixed hex string constants of the
+// Truffle tests, like in test/ion.js
+func (f ProofFixture) JS() string {
+	var b strings.Builder
+	b.WriteString(f.comment())
+	for _, v := range f.values() {
+		fmt.Fprintf(&b, "const %s = \"0x%s\"\n", v.name, hex.EncodeToString(v.value))
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils_test
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ProofFixture(t *testing.T) {
+	txValue, _ := hex.DecodeString(TEST_TX_VALUE)
+	fixture := utils.ProofFixture{
+		TxHash:      common.HexToHash("0xafc3ab60059ed38e71c7f6bea036822abe16b2c02fcf770a4f4b5fffcbfe6e7e"),
+		BlockNumber: big.NewInt(2657422),
+		Proof: utils.MerkleProof{
+			TxPath:  []byte{0x13},
+			TxValue: txValue,
+		},
+	}
+
+	goHex := fixture.GoHex()
+	assert.Contains(t, goHex, fixture.TxHash.Hex())
+	assert.Contains(t, goHex, `var TEST_PATH = "13"`)
+	assert.Contains(t, goHex, `var TEST_TX_VALUE = "`+TEST_TX_VALUE+`"`)
+
+	goBytes := fixture.GoBytes()
+	assert.Contains(t, goBytes, "var TEST_PATH = []byte{\n\t0x13,\n}")
+	assert.Contains(t, goBytes, "var TEST_TX_VALUE = []byte{\n\t0xf8, 0x67, 0x07,")
+
+	assert.Contains(t, fixture.Solidity(), `bytes constant TEST_PATH = hex"13";`)
+	assert.Contains(t, fixture.JS(), `const TEST_TX_VALUE = "0x`+TEST_TX_VALUE+`"`)
+}
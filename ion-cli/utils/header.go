@@ -0,0 +1,170 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ConsensusType is the consensus engine of the chain a block header comes from
+type ConsensusType int
+
+const (
+	// Ethash proof of work headers
+	Ethash ConsensusType = iota
+	// Clique proof of authority headers, sealed with a signature in the extra data
+	Clique
+	// IBFT (Istanbul) headers of Quorum, sealed by the proposer and committed
+	// by the validators in the RLP encoded extra data
+	IBFT
+)
+
+// CliqueSealLength is the length of the signature at the end of clique extra data
+const CliqueSealLength = 65
+
+func (c ConsensusType) String() string {
+	switch c {
+	case Ethash:
+		return "ethash"
+	case Clique:
+		return "clique"
+	case IBFT:
+		return "ibft"
+	default:
+		return fmt.Sprintf("ConsensusType(%d)", int(c))
+	}
+}
+
+// EncodeBlockHeader RLP encodes a header the way the validation contract of
+// the consensus engine expects it in SubmitBlock.
+// Ethash headers are encoded in full, so their keccak256 is the block hash.
+// Clique headers are encoded with the seal stripped from the extra data, so
+// their keccak256 is the signing hash the signer is recovered from, not the
+// block hash. IBFT headers are encoded with every seal stripped, see
+// ExtractIBFTSeals for the other encodings IBFT validation takes.
+func EncodeBlockHeader(header *types.Header, engine ConsensusType) ([]byte, error) {
+	switch engine {
+	case Ethash:
+		encoded, err := rlp.EncodeToBytes(header)
+		if err != nil {
+			return nil, fmt.Errorf("failed encoding header: %v", err)
+		}
+		return encoded, nil
+	case Clique:
+		if len(header.Extra) < CliqueSealLength {
+			return nil, fmt.Errorf("extra data of %d bytes is too short for a clique seal", len(header.Extra))
+		}
+		unsigned := types.CopyHeader(header)
+		unsigned.Extra = unsigned.Extra[:len(unsigned.Extra)-CliqueSealLength]
+		encoded, err := rlp.EncodeToBytes(unsigned)
+		if err != nil {
+			return nil, fmt.Errorf("failed encoding unsigned header: %v", err)
+		}
+		return encoded, nil
+	case IBFT:
+		extra, err := DecodeIstanbulExtra(header)
+		if err != nil {
+			return nil, err
+		}
+		return encodeIBFTHeader(header, extra, false)
+	default:
+		return nil, fmt.Errorf("unsupported consensus type %v", engine)
+	}
+}
+
+// HeaderVariant is the field layout of a block header, which changed with the
+// hard forks adding header fields
+type HeaderVariant int
+
+const (
+	// HeaderLegacy is the 15 field layout of the headers before London
+	HeaderLegacy HeaderVariant = iota
+	// HeaderEIP1559 appends the base fee of London headers
+	HeaderEIP1559
+	// HeaderShanghai appends the withdrawals root of Shanghai headers
+	HeaderShanghai
+)
+
+func (v HeaderVariant) String() string {
+	switch v {
+	case HeaderLegacy:
+		return "legacy"
+	case HeaderEIP1559:
+		return "eip1559"
+	case HeaderShanghai:
+		return "shanghai"
+	default:
+		return fmt.Sprintf("HeaderVariant(%d)", int(v))
+	}
+}
+
+// HeaderForkFields are the header fields appended by the hard forks, which
+// the headers of the go-ethereum release pinned in Gopkg.toml don't have
+type HeaderForkFields struct {
+	// BaseFee of the London headers
+	BaseFee *big.Int
+	// WithdrawalsRoot of the Shanghai headers
+	WithdrawalsRoot *common.Hash
+}
+
+// EncodeHeaderForIon RLP encodes header in the layout of variant, the
+// encoding whose keccak256 is the block hash Ion stores. The field list is
+// encoded by hand, the fields added by the forks are taken from fork, which
+// must hold the base fee of HeaderEIP1559 and HeaderShanghai headers and the
+// withdrawals root of HeaderShanghai headers.
+func EncodeHeaderForIon(header *types.Header, variant HeaderVariant, fork *HeaderForkFields) ([]byte, error) {
+	fields := []interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra,
+		header.MixDigest,
+		header.Nonce,
+	}
+
+	switch variant {
+	case HeaderLegacy:
+	case HeaderEIP1559, HeaderShanghai:
+		if fork == nil || fork.BaseFee == nil {
+			return nil, fmt.Errorf("%v header needs a base fee", variant)
+		}
+		fields = append(fields, fork.BaseFee)
+		if variant == HeaderShanghai {
+			if fork.WithdrawalsRoot == nil {
+				return nil, fmt.Errorf("%v header needs a withdrawals root", variant)
+			}
+			fields = append(fields, *fork.WithdrawalsRoot)
+		}
+	default:
+		return nil, fmt.Errorf("unknown header variant %v", variant)
+	}
+
+	encoded, err := rlp.EncodeToBytes(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %v header: %v", variant, err)
+	}
+	return encoded, nil
+}
+
+// CliqueSigningHash returns the hash a clique header seal signs
+func CliqueSigningHash(header *types.Header) (common.Hash, error) {
+	encoded, err := EncodeBlockHeader(header, Clique)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCliqueHeader() *types.Header {
+	return &types.Header{
+		Difficulty: big.NewInt(2),
+		Number:     big.NewInt(1),
+		GasLimit:   4712388,
+		Time:       big.NewInt(1492010458),
+		Extra:      append(bytes.Repeat([]byte{0x01}, 32), bytes.Repeat([]byte{0x02}, utils.CliqueSealLength)...),
+	}
+}
+
+func Test_EncodeBlockHeader(t *testing.T) {
+	header := testCliqueHeader()
+
+	// the full encoding hashes to the block hash
+	signed, err := utils.EncodeBlockHeader(header, utils.Ethash)
+	assert.Nil(t, err)
+	assert.Equal(t, header.Hash(), crypto.Keccak256Hash(signed))
+
+	// the clique encoding drops the seal and hashes to the signing hash
+	unsigned, err := utils.EncodeBlockHeader(header, utils.Clique)
+	assert.Nil(t, err)
+	assert.NotEqual(t, header.Hash(), crypto.Keccak256Hash(unsigned))
+	assert.Equal(t, len(header.Extra), 32+utils.CliqueSealLength, "header must not be modified")
+
+	unsealed := types.CopyHeader(header)
+	unsealed.Extra = unsealed.Extra[:32]
+	assert.Equal(t, unsealed.Hash(), crypto.Keccak256Hash(unsigned))
+
+	signingHash, err := utils.CliqueSigningHash(header)
+	assert.Nil(t, err)
+	assert.Equal(t, unsealed.Hash(), signingHash)
+
+	header.Extra = header.Extra[:10]
+	_, err = utils.EncodeBlockHeader(header, utils.Clique)
+	assert.NotNil(t, err)
+}
+
+func Test_EncodeHeaderForIon(t *testing.T) {
+	header := testCliqueHeader()
+
+	encoded, err := utils.EncodeHeaderForIon(header, utils.HeaderLegacy, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, header.Hash(), crypto.Keccak256Hash(encoded))
+
+	// the fork fields are appended to the legacy field list
+	baseFee := big.NewInt(7)
+	withdrawalsRoot := common.HexToHash("0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+	fork := &utils.HeaderForkFields{BaseFee: baseFee, WithdrawalsRoot: &withdrawalsRoot}
+
+	encoded, err = utils.EncodeHeaderForIon(header, utils.HeaderEIP1559, fork)
+	assert.Nil(t, err)
+	var london []rlp.RawValue
+	assert.Nil(t, rlp.DecodeBytes(encoded, &london))
+	assert.Equal(t, 16, len(london))
+	assert.Equal(t, []byte{0x07}, []byte(london[15]))
+
+	encoded, err = utils.EncodeHeaderForIon(header, utils.HeaderShanghai, fork)
+	assert.Nil(t, err)
+	var shanghai []rlp.RawValue
+	assert.Nil(t, rlp.DecodeBytes(encoded, &shanghai))
+	assert.Equal(t, 17, len(shanghai))
+	assert.Equal(t, london, shanghai[:16])
+	root, _ := rlp.EncodeToBytes(withdrawalsRoot)
+	assert.Equal(t, root, []byte(shanghai[16]))
+
+	// the fork fields of the variant are required
+	_, err = utils.EncodeHeaderForIon(header, utils.HeaderEIP1559, nil)
+	assert.NotNil(t, err)
+	_, err = utils.EncodeHeaderForIon(header, utils.HeaderShanghai, &utils.HeaderForkFields{BaseFee: baseFee})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// IstanbulVanityLength is the length of the vanity prefix of IBFT extra data
+const IstanbulVanityLength = 32
+
+// IstanbulExtra is the RLP encoded part of the extra data of IBFT (Istanbul)
+// headers, following the vanity
+type IstanbulExtra struct {
+	// Validators of the block
+	Validators []common.Address
+	// Seal of the proposer, signing the header without seals
+	Seal []byte
+	// CommittedSeal of every validator committing to the block hash
+	CommittedSeal [][]byte
+}
+
+// DecodeIstanbulExtra decodes the Istanbul extra data of an IBFT header
+func DecodeIstanbulExtra(header *types.Header) (*IstanbulExtra, error) {
+	if len(header.Extra) < IstanbulVanityLength {
+		return nil, fmt.Errorf("extra data of %d bytes is too short for IBFT", len(header.Extra))
+	}
+	var extra IstanbulExtra
+	if err := rlp.DecodeBytes(header.Extra[IstanbulVanityLength:], &extra); err != nil {
+		return nil, fmt.Errorf("invalid IBFT extra data: %v", err)
+	}
+	return &extra, nil
+}
+
+// encodeIBFTHeader RLP encodes header with the committed seals stripped from
+// its extra data, and the proposer seal too unless keepSeal
+func encodeIBFTHeader(header *types.Header, extra *IstanbulExtra, keepSeal bool) ([]byte, error) {
+	filtered := IstanbulExtra{
+		Validators:    extra.Validators,
+		Seal:          []byte{},
+		CommittedSeal: [][]byte{},
+	}
+	if keepSeal {
+		filtered.Seal = extra.Seal
+	}
+	payload, err := rlp.EncodeToBytes(&filtered)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding IBFT extra data: %v", err)
+	}
+
+	h := types.CopyHeader(header)
+	h.Extra = append(common.CopyBytes(header.Extra[:IstanbulVanityLength]), payload...)
+	encoded, err := rlp.EncodeToBytes(h)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding IBFT header: %v", err)
+	}
+	return encoded, nil
+}
+
+// ExtractIBFTSeals splits an IBFT header into what an Ion IBFT validation
+// contract takes: the RLP encoding of the header without any seal, whose
+// keccak256 the proposer signed, the RLP encoding of the header with the
+// proposer seal but without the committed seals, whose keccak256 is the block
+// hash, and the RLP list of the committed seals. The proposer is recovered
+// from its seal.
+func ExtractIBFTSeals(header *types.Header) (proposer common.Address, unsignedRLP []byte, signedRLP []byte, committedSeals []byte, err error) {
+	extra, err := DecodeIstanbulExtra(header)
+	if err != nil {
+		return common.Address{}, nil, nil, nil, err
+	}
+	if unsignedRLP, err = encodeIBFTHeader(header, extra, false); err != nil {
+		return common.Address{}, nil, nil, nil, err
+	}
+	if signedRLP, err = encodeIBFTHeader(header, extra, true); err != nil {
+		return common.Address{}, nil, nil, nil, err
+	}
+	if committedSeals, err = rlp.EncodeToBytes(extra.CommittedSeal); err != nil {
+		return common.Address{}, nil, nil, nil, fmt.Errorf("failed encoding committed seals: %v", err)
+	}
+
+	pubkey, err := crypto.Ecrecover(crypto.Keccak256(unsignedRLP), extra.Seal)
+	if err != nil {
+		return common.Address{}, nil, nil, nil, fmt.Errorf("failed recovering IBFT proposer: %v", err)
+	}
+	copy(proposer[:], crypto.Keccak256(pubkey[1:])[12:])
+	return proposer, unsignedRLP, signedRLP, committedSeals, nil
+}
+
+// IBFTBlockHash returns the hash of an IBFT header, the keccak256 of its RLP
+// encoding without the committed seals. The Hash of the pinned go-ethereum
+// headers doesn't strip them.
+func IBFTBlockHash(header *types.Header) (common.Hash, error) {
+	extra, err := DecodeIstanbulExtra(header)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	encoded, err := encodeIBFTHeader(header, extra, true)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
+
+// IBFTSigningHash returns the hash the proposer seal of an IBFT header signs
+func IBFTSigningHash(header *types.Header) (common.Hash, error) {
+	encoded, err := EncodeBlockHeader(header, IBFT)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils_test
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractIBFTSeals(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	proposer := crypto.PubkeyToAddress(key.PublicKey)
+	validators := []common.Address{proposer, common.HexToAddress("0x42eb768f2244c8811c63729a21a3569731535f06")}
+
+	vanity := bytes.Repeat([]byte{0x01}, utils.IstanbulVanityLength)
+	setExtra := func(header *types.Header, extra *utils.IstanbulExtra) {
+		payload, err := rlp.EncodeToBytes(extra)
+		assert.Nil(t, err)
+		header.Extra = append(append([]byte{}, vanity...), payload...)
+	}
+
+	header := &types.Header{
+		Difficulty: big.NewInt(1),
+		Number:     big.NewInt(100),
+		GasLimit:   4712388,
+		Time:       big.NewInt(1492010458),
+	}
+	extra := &utils.IstanbulExtra{Validators: validators, Seal: []byte{}, CommittedSeal: [][]byte{}}
+	setExtra(header, extra)
+
+	signingHash, err := utils.IBFTSigningHash(header)
+	assert.Nil(t, err)
+	extra.Seal, err = crypto.Sign(signingHash.Bytes(), key)
+	assert.Nil(t, err)
+	extra.CommittedSeal = [][]byte{bytes.Repeat([]byte{0x02}, 65), bytes.Repeat([]byte{0x03}, 65)}
+	setExtra(header, extra)
+
+	recovered, unsignedRLP, signedRLP, committedSeals, err := utils.ExtractIBFTSeals(header)
+	assert.Nil(t, err)
+	assert.Equal(t, proposer, recovered)
+	assert.Equal(t, signingHash, crypto.Keccak256Hash(unsignedRLP))
+
+	// the signed header keeps the proposer seal only
+	var signed types.Header
+	assert.Nil(t, rlp.DecodeBytes(signedRLP, &signed))
+	signedExtra, err := utils.DecodeIstanbulExtra(&signed)
+	assert.Nil(t, err)
+	assert.Equal(t, extra.Seal, signedExtra.Seal)
+	assert.Empty(t, signedExtra.CommittedSeal)
+	assert.Equal(t, validators, signedExtra.Validators)
+
+	var seals [][]byte
+	assert.Nil(t, rlp.DecodeBytes(committedSeals, &seals))
+	assert.Equal(t, extra.CommittedSeal, seals)
+
+	_, _, _, _, err = utils.ExtractIBFTSeals(&types.Header{Extra: vanity[:10]})
+	assert.NotNil(t, err)
+}
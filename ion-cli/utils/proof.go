@@ -7,46 +7,260 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
-func GenerateProof(ctx context.Context, client *rpc.Client, txHash common.Hash) (txTriggerPath []byte, txTriggerRLP []byte, txTriggerProofArr []byte, receiptTrigger []byte, receiptTriggerProofArr []byte) {
-	blockNumberStr, txTrigger, err := BlockNumberByTransactionHash(ctx, client, txHash)
+// ProofOptions tune GenerateProof, GenerateTxProof, GenerateReceiptProof and
+// GenerateAccountProof. A nil *ProofOptions uses the defaults.
+type ProofOptions struct {
+	// SelfCheck verifies the generated proofs against the roots of the block
+	// header, so a bad proof fails at generation rather than on chain
+	SelfCheck bool
+}
+
+func (opts *ProofOptions) selfCheck() bool {
+	return opts != nil && opts.SelfCheck
+}
+
+// MerkleProof holds the inclusion proofs of a transaction and its receipt in
+// the transaction and receipt tries of their block. The fields map directly to
+// the byte arguments of the Ion verifyAndExecute function.
+type MerkleProof struct {
+	// TxPath is the RLP encoded index of the transaction in the block (txTriggerPath)
+	TxPath []byte
+	// TxValue is the RLP encoded transaction (txTriggerRLP)
+	TxValue []byte
+	// TxNodes is the RLP encoded array of transaction trie nodes (txTriggerProofArr)
+	TxNodes []byte
+	// ReceiptValue is the RLP encoded receipt (receiptTrigger)
+	ReceiptValue []byte
+	// ReceiptNodes is the RLP encoded array of receipt trie nodes (receiptTriggerProofArr)
+	ReceiptNodes []byte
+}
+
+// GenerateProof fetches the block of the transaction txHash from the source
+// chain, rebuilds its transaction and receipt tries and returns the proofs of
+// the transaction. The receipts are encoded in the format of the block, see
+// DetectReceiptFormat.
+func GenerateProof(ctx context.Context, sourceClient *rpc.Client, txHash common.Hash, opts *ProofOptions) (*MerkleProof, error) {
+	block, idx, err := transactionBlock(ctx, sourceClient, txHash)
 	if err != nil {
-		fmt.Printf("Error: couldn't find block by tx hash: %s\n", err)
+		return nil, err
 	}
 
-	// Convert returned blocknumber
-	var blockNumber big.Int
-	blockNumber.SetString((*blockNumberStr)[2:], 16)
+	receipts, format, err := blockReceipts(ctx, sourceClient, block)
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := NewMerkleProofWithFormat(block.Transactions(), receipts, idx, format)
+	if err != nil {
+		return nil, err
+	}
+	if opts.selfCheck() {
+		if err := proof.Validate(block.Header()); err != nil {
+			return nil, err
+		}
+	}
+	return proof, nil
+}
+
+// GenerateTxProof fetches the block of the transaction txHash, rebuilds its
+// transaction trie and returns the txTriggerPath, txTriggerRLP and
+// txTriggerProofArr arguments of verifyAndExecute. Both pre and post EIP-155
+// signed transactions are RLP encoded as they are in the block, so their trie
+// matches the transactions root of the header.
+func GenerateTxProof(ctx context.Context, sourceClient *rpc.Client, txHash common.Hash, opts *ProofOptions) (path []byte, txRLP []byte, proof []byte, err error) {
+	block, idx, err := transactionBlock(ctx, sourceClient, txHash)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	txs := block.Transactions()
+
+	path, err = encodeTrieIndex(idx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	txRLP, err = rlp.EncodeToBytes(txs[idx])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed encoding transaction: %v", err)
+	}
+	proof = Proof(TxTrie(txs), path)
+	if opts.selfCheck() {
+		if err := VerifyTxProof(block.Header(), path, txRLP, proof); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return path, txRLP, proof, nil
+}
 
-	clientETH := ethclient.NewClient(client)
-	eventTxBlockNumber := blockNumber
-	block, err := clientETH.BlockByNumber(ctx, &eventTxBlockNumber)
+// GenerateReceiptProof fetches all the receipts of the block of the
+// transaction txHash, rebuilds the receipt trie and returns the receiptTrigger
+// and receiptTriggerProofArr arguments of verifyAndExecute. The receipts are
+// encoded in the format whose trie matches the receipts root of the block, see
+// DetectReceiptFormat, so receipts with a status (post-Byzantium) or an
+// intermediate state root are both encoded correctly.
+func GenerateReceiptProof(ctx context.Context, sourceClient *rpc.Client, txHash common.Hash, opts *ProofOptions) (receiptRLP []byte, proof []byte, err error) {
+	block, idx, err := transactionBlock(ctx, sourceClient, txHash)
 	if err != nil {
-		fmt.Printf("Error: retrieving block: %s\n", err)
+		return nil, nil, err
 	}
 
-	var idx byte
-	tx := block.Transactions()
-	txTrie := TxTrie(tx)
-	blockReceipts := GetBlockTxReceipts(clientETH, block)
-	receiptTrie := ReceiptTrie(blockReceipts)
+	receipts, format, err := blockReceipts(ctx, sourceClient, block)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Calculate transaction index)
-	for i := 0; i < len(tx); i++ {
-		if txHash == tx[i].Hash() {
-			idx = byte(i)
+	path, err := encodeTrieIndex(idx)
+	if err != nil {
+		return nil, nil, err
+	}
+	receiptRLP, err = EncodeReceipt(receipts[idx], format)
+	if err != nil {
+		return nil, nil, err
+	}
+	receiptTrie, err := ReceiptTrieWithFormat(receipts, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof = Proof(receiptTrie, path)
+	if opts.selfCheck() {
+		if err := VerifyReceiptProof(block.Header(), path, receiptRLP, proof); err != nil {
+			return nil, nil, err
 		}
 	}
+	return receiptRLP, proof, nil
+}
 
-	txTriggerPath = append(txTriggerPath, idx)
-	txTriggerRLP, _ = rlp.EncodeToBytes(txTrigger)
-	txTriggerProofArr = Proof(txTrie, txTriggerPath[:])
-	receiptTrigger, _ = rlp.EncodeToBytes(blockReceipts[txTriggerPath[0]])
-	receiptTriggerProofArr = Proof(receiptTrie, txTriggerPath[:])
+// transactionBlock returns the block including txHash and the index of txHash
+// among its transactions. Blocks with typed transactions are rejected with
+// ErrTypedTransactions.
+func transactionBlock(ctx context.Context, client *rpc.Client, txHash common.Hash) (*types.Block, int, error) {
+	blockNumberStr, _, err := BlockNumberByTransactionHash(ctx, client, txHash)
+	if err != nil {
+		return nil, 0, fmt.Errorf("couldn't find block by tx hash: %v", err)
+	}
+	if blockNumberStr == nil {
+		return nil, 0, fmt.Errorf("transaction %s is pending", txHash.Hex())
+	}
 
-	return
+	// Convert returned blocknumber
+	blockNumber, ok := new(big.Int).SetString((*blockNumberStr)[2:], 16)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid block number %s", *blockNumberStr)
+	}
+
+	if err := checkUntypedTransactions(ctx, client, blockNumber); err != nil {
+		return nil, 0, err
+	}
+	block, err := ethclient.NewClient(client).BlockByNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed retrieving block %v: %v", blockNumber, err)
+	}
+
+	for i, tx := range block.Transactions() {
+		if tx.Hash() == txHash {
+			return block, i, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("transaction %s not found in block %v", txHash.Hex(), blockNumber)
+}
+
+// encodeTrieIndex returns the key of the transaction or receipt at idx. The
+// tries are keyed by the RLP encoded index, so the first one is at 0x80
+// rather than 0x00.
+func encodeTrieIndex(idx int) ([]byte, error) {
+	path, err := rlp.EncodeToBytes(uint(idx))
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding transaction index: %v", err)
+	}
+	return path, nil
+}
+
+// NewMerkleProof returns the proofs of the transaction at idx given all the
+// transactions of its block and their receipts. The receipts are encoded
+// pre-Byzantium when they hold an intermediate state root, see
+// NewMerkleProofWithFormat to pick the format.
+func NewMerkleProof(txs types.Transactions, receipts []*types.Receipt, idx int) (*MerkleProof, error) {
+	return NewMerkleProofWithFormat(txs, receipts, idx, receiptFormatOf(receipts))
+}
+
+// NewMerkleProofWithFormat builds the proofs of the transaction at idx like
+// NewMerkleProof, with the receipts encoded in format, e.g. the format
+// DetectReceiptFormat or ReceiptFormatAt gives for their block
+func NewMerkleProofWithFormat(txs types.Transactions, receipts []*types.Receipt, idx int, format ReceiptFormat) (*MerkleProof, error) {
+	if len(txs) != len(receipts) {
+		return nil, fmt.Errorf("%d transactions but %d receipts", len(txs), len(receipts))
+	}
+	if idx < 0 || idx >= len(txs) {
+		return nil, fmt.Errorf("transaction index %d out of range for %d transactions", idx, len(txs))
+	}
+
+	path, err := encodeTrieIndex(idx)
+	if err != nil {
+		return nil, err
+	}
+	txValue, err := rlp.EncodeToBytes(txs[idx])
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding transaction: %v", err)
+	}
+	receiptValue, err := EncodeReceipt(receipts[idx], format)
+	if err != nil {
+		return nil, err
+	}
+	receiptTrie, err := ReceiptTrieWithFormat(receipts, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MerkleProof{
+		TxPath:       path,
+		TxValue:      txValue,
+		TxNodes:      Proof(TxTrie(txs), path),
+		ReceiptValue: receiptValue,
+		ReceiptNodes: Proof(receiptTrie, path),
+	}, nil
+}
+
+// blockReceipts gets the receipts of all the transactions in a block, see
+// fetchBlockReceipts, and the format they are encoded in by the receipts root
+// of the block, see DetectReceiptFormat
+func blockReceipts(ctx context.Context, client *rpc.Client, block *types.Block) ([]*types.Receipt, ReceiptFormat, error) {
+	receipts, err := fetchBlockReceipts(ctx, client, block)
+	if err != nil {
+		return nil, 0, err
+	}
+	format, err := DetectReceiptFormat(receipts, block.ReceiptHash())
+	if err != nil {
+		return nil, 0, fmt.Errorf("block %v: %v", block.Number(), err)
+	}
+	return receipts, format, nil
+}
+
+// fetchBlockReceipts gets the receipts of all the transactions in a block,
+// with eth_getBlockReceipts when the node has it or else one transaction at a
+// time
+func fetchBlockReceipts(ctx context.Context, client *rpc.Client, block *types.Block) ([]*types.Receipt, error) {
+	var receipts []*types.Receipt
+	blockErr := client.CallContext(ctx, &receipts, "eth_getBlockReceipts", hexutil.EncodeBig(block.Number()))
+	if blockErr != nil {
+		clientETH := ethclient.NewClient(client)
+		receipts = make([]*types.Receipt, len(block.Transactions()))
+		for i, tx := range block.Transactions() {
+			receipt, err := clientETH.TransactionReceipt(ctx, tx.Hash())
+			if err != nil {
+				return nil, fmt.Errorf("failed retrieving receipts of block %v, eth_getBlockReceipts: %v, eth_getTransactionReceipt: %v", block.Number(), blockErr, err)
+			}
+			receipts[i] = receipt
+		}
+	}
+
+	if len(receipts) != len(block.Transactions()) {
+		return nil, fmt.Errorf("got %d receipts for %d transactions in block %v", len(receipts), len(block.Transactions()), block.Number())
+	}
+	return receipts, nil
 }
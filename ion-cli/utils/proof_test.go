@@ -4,9 +4,14 @@ package utils_test
 import (
 	"context"
 	"encoding/hex"
+	"math/big"
 	"testing"
 
 	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,6 +21,24 @@ var TEST_TX_NODES = "f90235f871a0804f9c841a6a1d3361d79980581c84e5b4d3e4c9bf33951
 var TEST_RECEIPT_VALUE = "f901640183252867b9010000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000080000000000000000000000000000000000000000010000000000000000000000000400000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000800000000000f85af8589461621bcf02914668f8404c1f860e92fc1893f74ce1a027a9902e06885f7c187501d61990eae923b37634a8d6dda55a04dc7078395340a0000000000000000000000000279884e133f9346f2fad9cc158222068221b613e"
 var TEST_RECEIPT_NODES = "f90335f871a012d378fe6800bc18f22e715a31971ef7e73ac5d1d85384f4b66ac32036ae43dea004d6e2678656a957ac776dbef512a04d266c1af3e2c5587fd233261a3d423213808080808080a05fac317a4d6d78181319fbc7e2cae4a9260f1a6afb5c6fea066e2308eed416818080808080808080f90151a03da235c6dd0fbdaf208c60cbdca0d609dee2ba107495aa7adaa658362616c8aaa09ebf378a9064aa4da0512c55c790a5e007ac79d2713e4533771cd2c95be47a4da0c06fed36ffe1f2ec164ba88f73b353960448d2decbb65355c5298a33555de742a0e057afe423ee17e5499c570a56880b0f5b5c1884b90ff9b9b5baa827f72fc816a093e06093cd2fdb67e0f87cfcc35ded2f445cc1309a0ff178e59f932aeadb6d73a0193e4e939fbc5d34a570bea3fff7c6d54adcb1c3ab7ef07510e7bd5fcef2d4b3a0a17a0c71c0118092367220f65b67f2ba2eb9068ff5270baeabe8184a01a37f14a03479a38e63123d497588ad5c31d781276ec8c11352dd3895c8add34f9a2b786ba042254728bb9ab94b58adeb75d2238da6f30382969c00c65e55d4cc4aa474c0a6a03c088484aa1c73b8fb291354f80e9557ab75a01c65d046c2471d19bd7f2543d880808080808080f9016b20b90167f901640183252867b9010000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000080000000000000000000000000000000000000000010000000000000000000000000400000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000100000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000800000000000f85af8589461621bcf02914668f8404c1f860e92fc1893f74ce1a027a9902e06885f7c187501d61990eae923b37634a8d6dda55a04dc7078395340a0000000000000000000000000279884e133f9346f2fad9cc158222068221b613e"
 
+// testTxsAndReceipts signs n transfers and builds their receipts, for the
+// tries of a block
+func testTxsAndReceipts(t *testing.T, n int) (types.Transactions, []*types.Receipt) {
+	key, _ := crypto.GenerateKey()
+	signer := types.HomesteadSigner{}
+
+	var txs types.Transactions
+	var receipts []*types.Receipt
+	for i := 0; i < n; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		signedTx, err := types.SignTx(tx, signer, key)
+		assert.Nil(t, err)
+		txs = append(txs, signedTx)
+		receipts = append(receipts, types.NewReceipt(nil, false, uint64(21000*(i+1))))
+	}
+	return txs, receipts
+}
+
 func Test_GenerateProof(t *testing.T) {
 	ctx := context.Background()
 
@@ -26,10 +49,137 @@ func Test_GenerateProof(t *testing.T) {
 	client := utils.ClientRPC("https://rinkeby.infura.io")
 	defer client.Close()
 
-	PATH, TX_VALUE, TX_NODES, RECEIPT_VALUE, RECEIPT_NODES := utils.GenerateProof(ctx, client, TXHASH)
-	assert.Equal(t, TEST_PATH, hex.EncodeToString(PATH))
-	assert.Equal(t, TEST_TX_VALUE, hex.EncodeToString(TX_VALUE))
-	assert.Equal(t, TEST_TX_NODES, hex.EncodeToString(TX_NODES))
-	assert.Equal(t, TEST_RECEIPT_VALUE, hex.EncodeToString(RECEIPT_VALUE))
-	assert.Equal(t, TEST_RECEIPT_NODES, hex.EncodeToString(RECEIPT_NODES))
+	proof, err := utils.GenerateProof(ctx, client, TXHASH, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, TEST_PATH, hex.EncodeToString(proof.TxPath))
+	assert.Equal(t, TEST_TX_VALUE, hex.EncodeToString(proof.TxValue))
+	assert.Equal(t, TEST_TX_NODES, hex.EncodeToString(proof.TxNodes))
+	assert.Equal(t, TEST_RECEIPT_VALUE, hex.EncodeToString(proof.ReceiptValue))
+	assert.Equal(t, TEST_RECEIPT_NODES, hex.EncodeToString(proof.ReceiptNodes))
+}
+
+func Test_NewMerkleProof(t *testing.T) {
+	txs, receipts := testTxsAndReceipts(t, 3)
+
+	checkProof := func(txs types.Transactions, receipts []*types.Receipt, idx int) {
+		proof, err := utils.NewMerkleProof(txs, receipts, idx)
+		assert.Nil(t, err)
+
+		path, _ := rlp.EncodeToBytes(uint(idx))
+		assert.Equal(t, path, proof.TxPath)
+
+		txValue, _ := rlp.EncodeToBytes(txs[idx])
+		assert.Equal(t, txValue, proof.TxValue)
+		receiptValue, _ := rlp.EncodeToBytes(receipts[idx])
+		assert.Equal(t, receiptValue, proof.ReceiptValue)
+
+		// the first node of each proof is the root of the trie
+		var txNodes, receiptNodes []rlp.RawValue
+		assert.Nil(t, rlp.DecodeBytes(proof.TxNodes, &txNodes))
+		assert.Nil(t, rlp.DecodeBytes(proof.ReceiptNodes, &receiptNodes))
+		assert.Equal(t, utils.TxTrie(txs).Hash(), crypto.Keccak256Hash(txNodes[0]))
+		assert.Equal(t, utils.ReceiptTrie(receipts).Hash(), crypto.Keccak256Hash(receiptNodes[0]))
+
+		header := &types.Header{TxHash: utils.TxTrie(txs).Hash(), ReceiptHash: utils.ReceiptTrie(receipts).Hash()}
+		assert.Nil(t, proof.Validate(header))
+	}
+
+	checkProof(txs, receipts, 0)          // first transaction
+	checkProof(txs, receipts, len(txs)-1) // last transaction
+	checkProof(txs[:1], receipts[:1], 0)  // single transaction block
+
+	_, err := utils.NewMerkleProof(txs, receipts, len(txs))
+	assert.NotNil(t, err)
+}
+
+func Test_ValidateProof(t *testing.T) {
+	txs, receipts := testTxsAndReceipts(t, 20)
+	root := utils.TxTrie(txs).Hash()
+
+	for _, idx := range []int{0, 1, 15, 19} {
+		proof, err := utils.NewMerkleProof(txs, receipts, idx)
+		assert.Nil(t, err)
+		assert.Nil(t, utils.ValidateProof(root, proof.TxPath, proof.TxValue, proof.TxNodes))
+	}
+
+	proof, err := utils.NewMerkleProof(txs, receipts, 3)
+	assert.Nil(t, err)
+
+	// another root, value or path
+	assert.NotNil(t, utils.ValidateProof(common.Hash{}, proof.TxPath, proof.TxValue, proof.TxNodes))
+	otherValue, _ := rlp.EncodeToBytes(txs[4])
+	assert.NotNil(t, utils.ValidateProof(root, proof.TxPath, otherValue, proof.TxNodes))
+	otherPath, _ := rlp.EncodeToBytes(uint(4))
+	assert.NotNil(t, utils.ValidateProof(root, otherPath, proof.TxValue, proof.TxNodes))
+
+	// a truncated proof
+	var nodes []rlp.RawValue
+	assert.Nil(t, rlp.DecodeBytes(proof.TxNodes, &nodes))
+	truncated, _ := rlp.EncodeToBytes(nodes[:len(nodes)-1])
+	assert.NotNil(t, utils.ValidateProof(root, proof.TxPath, proof.TxValue, truncated))
+}
+
+func Test_VerifyProofAgainstHeader(t *testing.T) {
+	txs, receipts := testTxsAndReceipts(t, 5)
+	header := &types.Header{
+		Number:      big.NewInt(1),
+		TxHash:      utils.TxTrie(txs).Hash(),
+		ReceiptHash: utils.ReceiptTrie(receipts).Hash(),
+	}
+
+	proof, err := utils.NewMerkleProof(txs, receipts, 2)
+	assert.Nil(t, err)
+	assert.Nil(t, utils.VerifyTxProof(header, proof.TxPath, proof.TxValue, proof.TxNodes))
+	assert.Nil(t, utils.VerifyReceiptProof(header, proof.TxPath, proof.ReceiptValue, proof.ReceiptNodes))
+
+	// the receipt proof doesn't lead to the transactions root and vice versa
+	assert.NotNil(t, utils.VerifyTxProof(header, proof.TxPath, proof.ReceiptValue, proof.ReceiptNodes))
+	err = utils.VerifyReceiptProof(header, proof.TxPath, proof.TxValue, proof.TxNodes)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), header.ReceiptHash.Hex())
+}
+
+func Test_VerifyProofLocally(t *testing.T) {
+	txs, receipts := testTxsAndReceipts(t, 20)
+	root := utils.ReceiptTrie(receipts).Hash()
+
+	proof, err := utils.NewMerkleProof(txs, receipts, 7)
+	assert.Nil(t, err)
+	var rawNodes []rlp.RawValue
+	assert.Nil(t, rlp.DecodeBytes(proof.ReceiptNodes, &rawNodes))
+	var nodes [][]byte
+	for _, node := range rawNodes {
+		nodes = append(nodes, node)
+	}
+	assert.Nil(t, utils.VerifyProofLocally(root, proof.TxPath, proof.ReceiptValue, nodes))
+
+	assert.NotNil(t, utils.VerifyProofLocally(root, proof.TxPath, proof.TxValue, nodes))
+	assert.NotNil(t, utils.VerifyProofLocally(root, proof.TxPath, proof.ReceiptValue, nodes[:1]))
+}
+
+func Test_DescribeProof(t *testing.T) {
+	txs, receipts := testTxsAndReceipts(t, 5)
+	proof, err := utils.NewMerkleProof(txs, receipts, 2)
+	assert.Nil(t, err)
+
+	description, err := utils.DescribeProof(proof.TxNodes)
+	assert.Nil(t, err)
+	assert.Contains(t, description, "node 0 "+hex.EncodeToString(utils.TxTrie(txs).Hash().Bytes())+": branch")
+	assert.Contains(t, description, hex.EncodeToString(proof.TxValue))
+	assert.NotContains(t, description, "not referenced")
+
+	// the proof breaks once its nodes are out of order
+	var nodes []rlp.RawValue
+	assert.Nil(t, rlp.DecodeBytes(proof.TxNodes, &nodes))
+	reversed := make([]rlp.RawValue, len(nodes))
+	for i, node := range nodes {
+		reversed[len(nodes)-1-i] = node
+	}
+	reversedNodes, _ := rlp.EncodeToBytes(reversed)
+	description, err = utils.DescribeProof(reversedNodes)
+	assert.Nil(t, err)
+	assert.Contains(t, description, "<-- not referenced by node 0")
+
+	_, err = utils.DescribeProof([]byte{0x01})
+	assert.NotNil(t, err)
 }
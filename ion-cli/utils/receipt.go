@@ -0,0 +1,201 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// ReceiptFormat is the RLP layout of the receipts of a block, which changed
+// with the hard forks. The envelopes of typed receipts are not supported, see
+// ErrTypedTransactions.
+type ReceiptFormat int
+
+const (
+	// ReceiptPreByzantium receipts start with the intermediate state root
+	ReceiptPreByzantium ReceiptFormat = iota
+	// ReceiptPostByzantium receipts start with the status of the transaction
+	ReceiptPostByzantium
+)
+
+func (f ReceiptFormat) String() string {
+	switch f {
+	case ReceiptPreByzantium:
+		return "pre-byzantium"
+	case ReceiptPostByzantium:
+		return "post-byzantium"
+	default:
+		return fmt.Sprintf("ReceiptFormat(%d)", int(f))
+	}
+}
+
+// ErrTypedTransactions is returned for the proofs of blocks holding EIP-2718
+// typed transactions, e.g. access-list or dynamic-fee ones. The go-ethereum
+// release pinned in Gopkg.toml predates them, so neither those transactions
+// nor the envelopes of their receipts can be encoded into the block tries.
+var ErrTypedTransactions = errors.New("typed transactions are not supported by the pinned go-ethereum version")
+
+// checkUntypedTransactions fails with ErrTypedTransactions when block number
+// holds typed transactions, which the nodes report with a non-zero type
+func checkUntypedTransactions(ctx context.Context, client *rpc.Client, number *big.Int) error {
+	var block struct {
+		Transactions []struct {
+			Hash common.Hash     `json:"hash"`
+			Type *hexutil.Uint64 `json:"type"`
+		} `json:"transactions"`
+	}
+	if err := client.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.EncodeBig(number), true); err != nil {
+		return fmt.Errorf("failed retrieving block %v: %v", number, err)
+	}
+	for _, tx := range block.Transactions {
+		if tx.Type != nil && *tx.Type != 0 {
+			return fmt.Errorf("transaction %s of block %v has type %d: %w", tx.Hash.Hex(), number, uint64(*tx.Type), ErrTypedTransactions)
+		}
+	}
+	return nil
+}
+
+// ReceiptFormatAt returns the format of the receipts of block number of the
+// chain configured by config
+func ReceiptFormatAt(config *params.ChainConfig, number *big.Int) ReceiptFormat {
+	if config.IsByzantium(number) {
+		return ReceiptPostByzantium
+	}
+	return ReceiptPreByzantium
+}
+
+// receiptFormatOf returns the format receipts are likely encoded in, the
+// pre-Byzantium one when they hold an intermediate state root
+func receiptFormatOf(receipts []*types.Receipt) ReceiptFormat {
+	if len(receipts) > 0 && len(receipts[0].PostState) > 0 {
+		return ReceiptPreByzantium
+	}
+	return ReceiptPostByzantium
+}
+
+// DetectReceiptFormat returns the format of receipts whose trie has the root
+// receiptHash, e.g. the receipts root of their block. Some nodes report both
+// the state root and the status of post-Byzantium receipts, so the format
+// their fields suggest is tried before the other one.
+func DetectReceiptFormat(receipts []*types.Receipt, receiptHash common.Hash) (ReceiptFormat, error) {
+	likely := receiptFormatOf(receipts)
+	formats := []ReceiptFormat{likely, ReceiptPreByzantium}
+	if likely == ReceiptPreByzantium {
+		formats[1] = ReceiptPostByzantium
+	}
+	for _, format := range formats {
+		receiptTrie, err := ReceiptTrieWithFormat(receipts, format)
+		if err != nil {
+			// e.g. pre-Byzantium receipts without a state root
+			continue
+		}
+		if receiptTrie.Hash() == receiptHash {
+			return format, nil
+		}
+	}
+	return 0, fmt.Errorf("receipts root %s matches neither %v nor %v receipts", receiptHash.Hex(), formats[0], formats[1])
+}
+
+// consensusReceipt is the consensus encoding of a receipt, starting with either its
+// intermediate state root or its status
+type consensusReceipt struct {
+	PostStateOrStatus []byte
+	CumulativeGasUsed uint64
+	Bloom             types.Bloom
+	Logs              []*types.Log
+}
+
+// EncodeReceipt RLP encodes receipt in format, the encoding the receipt trie
+// of its block holds
+func EncodeReceipt(receipt *types.Receipt, format ReceiptFormat) ([]byte, error) {
+	enc := consensusReceipt{
+		CumulativeGasUsed: receipt.CumulativeGasUsed,
+		Bloom:             receipt.Bloom,
+		Logs:              receipt.Logs,
+	}
+	switch format {
+	case ReceiptPreByzantium:
+		if len(receipt.PostState) == 0 {
+			return nil, fmt.Errorf("receipt of %s has no intermediate state root", receipt.TxHash.Hex())
+		}
+		enc.PostStateOrStatus = receipt.PostState
+	case ReceiptPostByzantium:
+		// a failed status is encoded as an empty string
+		if receipt.Status == types.ReceiptStatusSuccessful {
+			enc.PostStateOrStatus = []byte{0x01}
+		}
+	default:
+		return nil, fmt.Errorf("unknown receipt format %v", format)
+	}
+
+	encoded, err := rlp.EncodeToBytes(enc)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding receipt: %v", err)
+	}
+	return encoded, nil
+}
+
+// ReceiptTrieWithFormat builds the receipt trie of receipts encoded in format
+func ReceiptTrieWithFormat(receipts []*types.Receipt, format ReceiptFormat) (*trie.Trie, error) {
+	paths := make([][]byte, len(receipts))
+	values := make([][]byte, len(receipts))
+	for i, receipt := range receipts {
+		path, err := encodeTrieIndex(i)
+		if err != nil {
+			return nil, err
+		}
+		value, err := EncodeReceipt(receipt, format)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = path
+		values[i] = value
+	}
+	return generateTrie(paths, values), nil
+}
+
+// GenerateReceiptProofWithFormat returns the receiptTrigger and
+// receiptTriggerProofArr arguments of verifyAndExecute like
+// GenerateReceiptProof, with the receipts encoded in format rather than the one
+// detected from the receipts root, e.g. the format ReceiptFormatAt gives for
+// the source chain. The rebuilt trie is checked against the receipts root of
+// the block.
+func GenerateReceiptProofWithFormat(ctx context.Context, sourceClient *rpc.Client, txHash common.Hash, format ReceiptFormat) (receiptRLP []byte, proof []byte, err error) {
+	block, idx, err := transactionBlock(ctx, sourceClient, txHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the receipts are checked once encoded in format
+	receipts, err := fetchBlockReceipts(ctx, sourceClient, block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	receiptTrie, err := ReceiptTrieWithFormat(receipts, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	if root := receiptTrie.Hash(); root != block.ReceiptHash() {
+		return nil, nil, fmt.Errorf("%v receipts root %s does not match block %v receipts root %s", format, root.Hex(), block.Number(), block.ReceiptHash().Hex())
+	}
+
+	path, err := encodeTrieIndex(idx)
+	if err != nil {
+		return nil, nil, err
+	}
+	receiptRLP, err = EncodeReceipt(receipts[idx], format)
+	if err != nil {
+		return nil, nil, err
+	}
+	return receiptRLP, Proof(receiptTrie, path), nil
+}
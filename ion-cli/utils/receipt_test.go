@@ -0,0 +1,119 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_EncodeReceipt(t *testing.T) {
+	logs := []*types.Log{{Address: common.HexToAddress("0x01"), Topics: []common.Hash{common.HexToHash("0x02")}, Data: []byte{0x03}}}
+
+	preByzantium := types.NewReceipt(common.HexToHash("0x04").Bytes(), false, 21000)
+	preByzantium.Logs = logs
+	encoded, err := utils.EncodeReceipt(preByzantium, utils.ReceiptPreByzantium)
+	assert.Nil(t, err)
+	expected, _ := rlp.EncodeToBytes(preByzantium)
+	assert.Equal(t, expected, encoded)
+
+	// the status replaces the state root, even when the node reported both
+	for _, failed := range []bool{false, true} {
+		postByzantium := types.NewReceipt(nil, failed, 21000)
+		postByzantium.Logs = logs
+		expected, _ := rlp.EncodeToBytes(postByzantium)
+		postByzantium.PostState = common.HexToHash("0x04").Bytes()
+		encoded, err := utils.EncodeReceipt(postByzantium, utils.ReceiptPostByzantium)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, encoded)
+	}
+
+	_, err = utils.EncodeReceipt(types.NewReceipt(nil, false, 21000), utils.ReceiptPreByzantium)
+	assert.NotNil(t, err)
+	_, err = utils.EncodeReceipt(preByzantium, utils.ReceiptFormat(-1))
+	assert.NotNil(t, err)
+
+	receipts := []*types.Receipt{preByzantium, preByzantium}
+	receiptTrie, err := utils.ReceiptTrieWithFormat(receipts, utils.ReceiptPreByzantium)
+	assert.Nil(t, err)
+	assert.Equal(t, utils.ReceiptTrie(receipts).Hash(), receiptTrie.Hash())
+}
+
+func Test_DetectReceiptFormat(t *testing.T) {
+	preByzantium := types.NewReceipt(common.HexToHash("0x04").Bytes(), false, 21000)
+	format, err := utils.DetectReceiptFormat([]*types.Receipt{preByzantium}, utils.ReceiptTrie([]*types.Receipt{preByzantium}).Hash())
+	assert.Nil(t, err)
+	assert.Equal(t, utils.ReceiptPreByzantium, format)
+
+	// a post-Byzantium receipt reported with a state root too
+	postByzantium := types.NewReceipt(nil, false, 21000)
+	root := utils.ReceiptTrie([]*types.Receipt{postByzantium}).Hash()
+	postByzantium.PostState = common.HexToHash("0x04").Bytes()
+	format, err = utils.DetectReceiptFormat([]*types.Receipt{postByzantium}, root)
+	assert.Nil(t, err)
+	assert.Equal(t, utils.ReceiptPostByzantium, format)
+
+	_, err = utils.DetectReceiptFormat([]*types.Receipt{postByzantium}, common.Hash{})
+	assert.NotNil(t, err)
+}
+
+func Test_ReceiptFormatAt(t *testing.T) {
+	assert.Equal(t, utils.ReceiptPreByzantium, utils.ReceiptFormatAt(params.MainnetChainConfig, big.NewInt(4369999)))
+	assert.Equal(t, utils.ReceiptPostByzantium, utils.ReceiptFormatAt(params.MainnetChainConfig, big.NewInt(4370000)))
+}
+
+// TypedBlockService serves a block holding tx along with a dynamic-fee
+// transaction
+type TypedBlockService struct {
+	tx *types.Transaction
+}
+
+func (s *TypedBlockService) GetTransactionByHash(hash common.Hash) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(s.tx)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+	fields["blockNumber"] = "0x1"
+	return fields, nil
+}
+
+func (s *TypedBlockService) GetBlockByNumber(number rpc.BlockNumber, full bool) map[string]interface{} {
+	return map[string]interface{}{"transactions": []map[string]interface{}{
+		{"hash": s.tx.Hash(), "type": hexutil.Uint64(0)},
+		{"hash": common.HexToHash("0x02"), "type": hexutil.Uint64(2)},
+	}}
+}
+
+func Test_TypedTransactionsRejected(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	tx, err := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+	require.Nil(t, err)
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	require.Nil(t, server.RegisterName("eth", &TypedBlockService{tx: tx}))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	_, err = utils.GenerateProof(context.Background(), client, tx.Hash(), nil)
+	assert.True(t, errors.Is(err, utils.ErrTypedTransactions), "got %v", err)
+	_, _, err = utils.GenerateReceiptProof(context.Background(), client, tx.Hash(), nil)
+	assert.True(t, errors.Is(err, utils.ErrTypedTransactions), "got %v", err)
+}
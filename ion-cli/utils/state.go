@@ -0,0 +1,185 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// AccountProof holds the proof of an account in the state trie of a block and
+// the proofs of some of its storage slots in the storage trie of the account.
+// The proofs are in the RLP encoded node array form of MerkleProof.
+type AccountProof struct {
+	Address     common.Address
+	BlockHash   common.Hash
+	BlockNumber *big.Int
+	// StateRoot of the block header the account proof leads from
+	StateRoot common.Hash
+	// Path is the key of the account in the state trie, keccak256(address)
+	Path []byte
+	// Value is the RLP encoded account, [nonce, balance, storageRoot, codeHash]
+	Value []byte
+	// Nodes is the RLP encoded array of state trie nodes
+	Nodes []byte
+	// StorageRoot of the account the storage proofs lead from
+	StorageRoot common.Hash
+	// Storage holds the proofs of the slots, in the order they were asked for
+	Storage []StorageProof
+}
+
+// StorageProof holds the proof of a storage slot in the storage trie of an
+// account
+type StorageProof struct {
+	Slot common.Hash
+	// Path is the key of the slot in the storage trie, keccak256(slot)
+	Path []byte
+	// Value is the RLP encoded slot value, with its leading zeros trimmed
+	Value []byte
+	// Nodes is the RLP encoded array of storage trie nodes
+	Nodes []byte
+}
+
+// zeroSlotValue is the RLP encoding of a zero slot value
+var zeroSlotValue = []byte{0x80}
+
+// stateAccount is the RLP layout of an account in the state trie
+type stateAccount struct {
+	Nonce       uint64
+	Balance     *big.Int
+	StorageRoot common.Hash
+	CodeHash    common.Hash
+}
+
+// getProofResult is the response of eth_getProof
+type getProofResult struct {
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []struct {
+		Value *hexutil.Big    `json:"value"`
+		Proof []hexutil.Bytes `json:"proof"`
+	} `json:"storageProof"`
+}
+
+// GenerateAccountProof gets the proofs of the account addr and its storage
+// slots at the block blockHash with eth_getProof. The proofs are asked for by
+// block number, which nodes without EIP-1898 need, and come with the state
+// root of the block header to validate them against, see Validate.
+func GenerateAccountProof(ctx context.Context, client *rpc.Client, addr common.Address, slots []common.Hash, blockHash common.Hash, opts *ProofOptions) (*AccountProof, error) {
+	header, err := ethclient.NewClient(client).HeaderByHash(ctx, blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed retrieving block %s: %v", blockHash.Hex(), err)
+	}
+
+	if slots == nil {
+		slots = []common.Hash{}
+	}
+	var result getProofResult
+	if err := client.CallContext(ctx, &result, "eth_getProof", addr, slots, hexutil.EncodeBig(header.Number)); err != nil {
+		return nil, fmt.Errorf("failed retrieving proof of %s at block %v: %v", addr.Hex(), header.Number, err)
+	}
+	if len(result.StorageProof) != len(slots) {
+		return nil, fmt.Errorf("got %d storage proofs for %d slots", len(result.StorageProof), len(slots))
+	}
+
+	balance := new(big.Int)
+	if result.Balance != nil {
+		balance = result.Balance.ToInt()
+	}
+	value, err := rlp.EncodeToBytes(stateAccount{
+		Nonce:       uint64(result.Nonce),
+		Balance:     balance,
+		StorageRoot: result.StorageHash,
+		CodeHash:    result.CodeHash,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding account: %v", err)
+	}
+	nodes, err := encodeProofNodes(result.AccountProof)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &AccountProof{
+		Address:     addr,
+		BlockHash:   blockHash,
+		BlockNumber: header.Number,
+		StateRoot:   header.Root,
+		Path:        crypto.Keccak256(addr.Bytes()),
+		Value:       value,
+		Nodes:       nodes,
+		StorageRoot: result.StorageHash,
+		Storage:     make([]StorageProof, len(slots)),
+	}
+	for i, slot := range slots {
+		slotValue := new(big.Int)
+		if result.StorageProof[i].Value != nil {
+			slotValue = result.StorageProof[i].Value.ToInt()
+		}
+		value, err := rlp.EncodeToBytes(slotValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed encoding value of slot %s: %v", slot.Hex(), err)
+		}
+		nodes, err := encodeProofNodes(result.StorageProof[i].Proof)
+		if err != nil {
+			return nil, err
+		}
+		proof.Storage[i] = StorageProof{
+			Slot:  slot,
+			Path:  crypto.Keccak256(slot.Bytes()),
+			Value: value,
+			Nodes: nodes,
+		}
+	}
+
+	if opts.selfCheck() {
+		if err := proof.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return proof, nil
+}
+
+// Validate checks the account proof leads from the state root to the account
+// and the storage proofs from its storage root to the slot values. Accounts
+// absent from the state trie have no value to prove and fail, while the proofs
+// of zero slots, absent from the storage trie, are skipped.
+func (p *AccountProof) Validate() error {
+	if err := ValidateProof(p.StateRoot, p.Path, p.Value, p.Nodes); err != nil {
+		return fmt.Errorf("invalid proof of account %s against state root %s of block %v: %v", p.Address.Hex(), p.StateRoot.Hex(), p.BlockNumber, err)
+	}
+	for _, storage := range p.Storage {
+		if bytes.Equal(storage.Value, zeroSlotValue) {
+			continue
+		}
+		if err := ValidateProof(p.StorageRoot, storage.Path, storage.Value, storage.Nodes); err != nil {
+			return fmt.Errorf("invalid proof of slot %s against storage root %s of account %s: %v", storage.Slot.Hex(), p.StorageRoot.Hex(), p.Address.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// encodeProofNodes RLP encodes the trie nodes of a proof into the node array
+// the Ion contracts take
+func encodeProofNodes(proof []hexutil.Bytes) ([]byte, error) {
+	nodes := make([]rlp.RawValue, len(proof))
+	for i, node := range proof {
+		nodes[i] = rlp.RawValue(node)
+	}
+	encoded, err := rlp.EncodeToBytes(nodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding proof nodes: %v", err)
+	}
+	return encoded, nil
+}
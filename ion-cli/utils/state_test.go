@@ -0,0 +1,109 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/clearmatics/ion/ion-cli/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ProofService answers eth_getBlockByHash and eth_getProof for one account
+type ProofService struct {
+	header *types.Header
+	state  *trie.Trie
+	store  *trie.Trie
+}
+
+func (s *ProofService) GetBlockByHash(hash common.Hash, full bool) *types.Header {
+	if hash != s.header.Hash() {
+		return nil
+	}
+	return s.header
+}
+
+func (s *ProofService) GetProof(addr common.Address, slots []common.Hash, block string) map[string]interface{} {
+	storageProofs := make([]map[string]interface{}, len(slots))
+	for i, slot := range slots {
+		var value []byte
+		if encoded, err := s.store.TryGet(crypto.Keccak256(slot.Bytes())); err == nil && encoded != nil {
+			rlp.DecodeBytes(encoded, &value)
+		}
+		storageProofs[i] = map[string]interface{}{
+			"key":   slot,
+			"value": (*hexutil.Big)(new(big.Int).SetBytes(value)),
+			"proof": proofNodes(s.store, crypto.Keccak256(slot.Bytes())),
+		}
+	}
+	return map[string]interface{}{
+		"address":      addr,
+		"accountProof": proofNodes(s.state, crypto.Keccak256(addr.Bytes())),
+		"balance":      (*hexutil.Big)(big.NewInt(100)),
+		"codeHash":     crypto.Keccak256Hash(nil),
+		"nonce":        hexutil.Uint64(1),
+		"storageHash":  s.store.Hash(),
+		"storageProof": storageProofs,
+	}
+}
+
+// proofNodes returns the trie nodes proving path in t, the way eth_getProof does
+func proofNodes(t *trie.Trie, path []byte) []hexutil.Bytes {
+	var nodes []rlp.RawValue
+	rlp.DecodeBytes(utils.Proof(t, path), &nodes)
+	hexNodes := make([]hexutil.Bytes, len(nodes))
+	for i, node := range nodes {
+		hexNodes[i] = hexutil.Bytes(node)
+	}
+	return hexNodes
+}
+
+func Test_GenerateAccountProof(t *testing.T) {
+	addr := common.HexToAddress("0x61621bcf02914668f8404c1f860e92fc1893f74c")
+	slot := common.HexToHash("0x01")
+	emptySlot := common.HexToHash("0x02")
+
+	store, _ := trie.New(common.Hash{}, trie.NewDatabase(ethdb.NewMemDatabase()))
+	slotValue, _ := rlp.EncodeToBytes(big.NewInt(42))
+	store.Update(crypto.Keccak256(slot.Bytes()), slotValue)
+	store.Update(crypto.Keccak256(common.HexToHash("0x03").Bytes()), slotValue)
+
+	account, _ := rlp.EncodeToBytes([]interface{}{uint64(1), big.NewInt(100), store.Hash(), crypto.Keccak256Hash(nil)})
+	state, _ := trie.New(common.Hash{}, trie.NewDatabase(ethdb.NewMemDatabase()))
+	state.Update(crypto.Keccak256(addr.Bytes()), account)
+	state.Update(crypto.Keccak256(common.HexToAddress("0x01").Bytes()), account)
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(1), Time: big.NewInt(1), Root: state.Hash()}
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	require.Nil(t, server.RegisterName("eth", &ProofService{header: header, state: state, store: store}))
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	proof, err := utils.GenerateAccountProof(context.Background(), client, addr, []common.Hash{slot, emptySlot}, header.Hash(), &utils.ProofOptions{SelfCheck: true})
+	require.Nil(t, err)
+	assert.Equal(t, header.Root, proof.StateRoot)
+	assert.Equal(t, account, proof.Value)
+	assert.Equal(t, store.Hash(), proof.StorageRoot)
+	require.Len(t, proof.Storage, 2)
+	assert.Equal(t, slotValue, proof.Storage[0].Value)
+	assert.Nil(t, proof.Validate())
+
+	// a proof of another account doesn't lead to the state root
+	proof.Path = crypto.Keccak256(common.HexToAddress("0x02").Bytes())
+	assert.NotNil(t, proof.Validate())
+
+	_, err = utils.GenerateAccountProof(context.Background(), client, addr, nil, common.HexToHash("0x01"), nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,278 @@
+// Copyright (c) 2018 Clearmatics Technologies Ltd
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Validate checks the transaction and receipt proofs against the roots of
+// header, the header of the block they were generated from
+func (p *MerkleProof) Validate(header *types.Header) error {
+	if err := VerifyTxProof(header, p.TxPath, p.TxValue, p.TxNodes); err != nil {
+		return err
+	}
+	return VerifyReceiptProof(header, p.TxPath, p.ReceiptValue, p.ReceiptNodes)
+}
+
+// VerifyTxProof checks the transaction proof leads from the transactions root
+// of header along path to the RLP encoded transaction value
+func VerifyTxProof(header *types.Header, path, value, proof []byte) error {
+	if err := ValidateProof(header.TxHash, path, value, proof); err != nil {
+		return fmt.Errorf("invalid transaction proof against transactions root %s of block %v: %v", header.TxHash.Hex(), header.Number, err)
+	}
+	return nil
+}
+
+// VerifyReceiptProof checks the receipt proof leads from the receipts root of
+// header along path to the RLP encoded receipt value
+func VerifyReceiptProof(header *types.Header, path, value, proof []byte) error {
+	if err := ValidateProof(header.ReceiptHash, path, value, proof); err != nil {
+		return fmt.Errorf("invalid receipt proof against receipts root %s of block %v: %v", header.ReceiptHash.Hex(), header.Number, err)
+	}
+	return nil
+}
+
+// ValidateProof walks the RLP encoded array of Patricia trie nodes proof from
+// root along path, the way the Ion contract does, and checks it leads to value.
+// The error names the node breaking the proof.
+func ValidateProof(root common.Hash, path, value, proof []byte) error {
+	var nodes []rlp.RawValue
+	if err := rlp.DecodeBytes(proof, &nodes); err != nil {
+		return fmt.Errorf("failed decoding proof nodes: %v", err)
+	}
+
+	key := keyNibbles(path)
+	ref := root.Bytes()
+	next := 0
+	for step := 0; ; step++ {
+		// nodes shorter than a hash are inlined in their parent
+		node := ref
+		if len(ref) == common.HashLength {
+			if next >= len(nodes) {
+				return fmt.Errorf("proof ends after %d nodes, before reaching the value", len(nodes))
+			}
+			node = nodes[next]
+			if hash := crypto.Keccak256(node); !bytes.Equal(hash, ref) {
+				return fmt.Errorf("node %d hashes to %x, its parent references %x", next, hash, ref)
+			}
+			next++
+		}
+
+		var elems []rlp.RawValue
+		if err := rlp.DecodeBytes(node, &elems); err != nil {
+			return fmt.Errorf("node %d at step %d is not a trie node: %v", next-1, step, err)
+		}
+
+		switch len(elems) {
+		case 17:
+			if len(key) == 0 {
+				return checkProofValue(elems[16], value, step)
+			}
+			ref = nodeRef(elems[key[0]])
+			key = key[1:]
+		case 2:
+			var encodedPath []byte
+			if err := rlp.DecodeBytes(elems[0], &encodedPath); err != nil || len(encodedPath) == 0 {
+				return fmt.Errorf("invalid path of short node at step %d", step)
+			}
+			nodePath, leaf := compactNibbles(encodedPath)
+			if len(nodePath) > len(key) || !bytes.Equal(nodePath, key[:len(nodePath)]) {
+				return fmt.Errorf("path of node at step %d diverges from the key", step)
+			}
+			key = key[len(nodePath):]
+			if leaf {
+				if len(key) != 0 {
+					return fmt.Errorf("leaf at step %d ends before the key", step)
+				}
+				return checkProofValue(elems[1], value, step)
+			}
+			ref = nodeRef(elems[1])
+		default:
+			return fmt.Errorf("node at step %d has %d elements", step, len(elems))
+		}
+
+		if len(ref) == 0 {
+			return fmt.Errorf("key is not in the trie, node at step %d has no child for it", step)
+		}
+	}
+}
+
+// VerifyProofLocally checks the proof, given as its separate trie nodes, leads
+// from rootHash along path to rlpValue like ValidateProof, so a bad proof can
+// be caught before paying for verifyAndExecute to reject it
+func VerifyProofLocally(rootHash common.Hash, path []byte, rlpValue []byte, proof [][]byte) error {
+	nodes := make([]rlp.RawValue, len(proof))
+	for i, node := range proof {
+		nodes[i] = node
+	}
+	encoded, err := rlp.EncodeToBytes(nodes)
+	if err != nil {
+		return fmt.Errorf("failed encoding proof nodes: %v", err)
+	}
+	return ValidateProof(rootHash, path, rlpValue, encoded)
+}
+
+// DescribeProof decodes the RLP encoded array of Patricia trie nodes proof
+// into a readable tree, one node per level, with the type of every node, the
+// nibble path of short nodes and the references to children. A node not
+// referenced by the node before it, where the proof breaks, is highlighted.
+func DescribeProof(proof []byte) (string, error) {
+	var nodes []rlp.RawValue
+	if err := rlp.DecodeBytes(proof, &nodes); err != nil {
+		return "", fmt.Errorf("failed decoding proof nodes: %v", err)
+	}
+
+	var b strings.Builder
+	var parent []rlp.RawValue
+	for i, node := range nodes {
+		indent := strings.Repeat("  ", i)
+		hash := crypto.Keccak256(node)
+
+		var elems []rlp.RawValue
+		if err := rlp.DecodeBytes(node, &elems); err != nil {
+			fmt.Fprintf(&b, "%snode %d %x: not a trie node: %v\n", indent, i, hash, err)
+			parent = nil
+			continue
+		}
+		fmt.Fprintf(&b, "%snode %d %x: %s", indent, i, hash, nodeKind(elems))
+		if i > 0 && !referencesNode(parent, hash) {
+			fmt.Fprintf(&b, "  <-- not referenced by node %d", i-1)
+		}
+		b.WriteString("\n")
+		describeNode(&b, indent+"  ", elems)
+		parent = elems
+	}
+	return b.String(), nil
+}
+
+// nodeKind names the type of the decoded trie node elems
+func nodeKind(elems []rlp.RawValue) string {
+	switch len(elems) {
+	case 17:
+		return "branch"
+	case 2:
+		var encodedPath []byte
+		if err := rlp.DecodeBytes(elems[0], &encodedPath); err != nil || len(encodedPath) == 0 {
+			return "short node with an invalid path"
+		}
+		if _, leaf := compactNibbles(encodedPath); leaf {
+			return "leaf"
+		}
+		return "extension"
+	default:
+		return fmt.Sprintf("invalid node of %d elements", len(elems))
+	}
+}
+
+// describeNode writes the children, path and value of the decoded trie node
+// elems
+func describeNode(b *strings.Builder, indent string, elems []rlp.RawValue) {
+	switch len(elems) {
+	case 17:
+		for i, elem := range elems[:16] {
+			if ref := nodeRef(elem); len(ref) != 0 {
+				fmt.Fprintf(b, "%s[%x] %s\n", indent, i, describeRef(ref))
+			}
+		}
+		var value []byte
+		if err := rlp.DecodeBytes(elems[16], &value); err == nil && len(value) != 0 {
+			fmt.Fprintf(b, "%svalue %x\n", indent, value)
+		}
+	case 2:
+		var encodedPath []byte
+		if err := rlp.DecodeBytes(elems[0], &encodedPath); err != nil || len(encodedPath) == 0 {
+			return
+		}
+		path, leaf := compactNibbles(encodedPath)
+		fmt.Fprintf(b, "%spath %s\n", indent, nibblesString(path))
+		if leaf {
+			var value []byte
+			if err := rlp.DecodeBytes(elems[1], &value); err == nil {
+				fmt.Fprintf(b, "%svalue %x\n", indent, value)
+			}
+		} else {
+			fmt.Fprintf(b, "%s-> %s\n", indent, describeRef(nodeRef(elems[1])))
+		}
+	}
+}
+
+// describeRef describes the reference to a child node, its hash or the child
+// itself when it is inlined
+func describeRef(ref []byte) string {
+	if len(ref) == common.HashLength {
+		return fmt.Sprintf("%x", ref)
+	}
+	return fmt.Sprintf("inline %x", ref)
+}
+
+// referencesNode reports whether one of the children of the decoded trie node
+// elems is the node hashing to hash
+func referencesNode(elems []rlp.RawValue, hash []byte) bool {
+	for _, elem := range elems {
+		if bytes.Equal(nodeRef(elem), hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// nibblesString formats nibbles as hex digits
+func nibblesString(nibbles []byte) string {
+	digits := make([]byte, len(nibbles))
+	for i, n := range nibbles {
+		digits[i] = "0123456789abcdef"[n]
+	}
+	return string(digits)
+}
+
+// checkProofValue compares the RLP string value of a trie node with value
+func checkProofValue(encoded rlp.RawValue, value []byte, step int) error {
+	var stored []byte
+	if err := rlp.DecodeBytes(encoded, &stored); err != nil {
+		return fmt.Errorf("invalid value in node at step %d: %v", step, err)
+	}
+	if !bytes.Equal(stored, value) {
+		return fmt.Errorf("value in node at step %d differs from the one proven", step)
+	}
+	return nil
+}
+
+// nodeRef returns the hash referencing a child node, or the child itself when
+// it is inlined. It is empty for a missing child.
+func nodeRef(encoded rlp.RawValue) []byte {
+	kind, content, _, err := rlp.Split(encoded)
+	if err != nil {
+		return nil
+	}
+	if kind == rlp.List {
+		return encoded
+	}
+	return content
+}
+
+// keyNibbles splits a trie key into nibbles
+func keyNibbles(key []byte) []byte {
+	nibbles := make([]byte, 0, len(key)*2)
+	for _, b := range key {
+		nibbles = append(nibbles, b>>4, b&0x0f)
+	}
+	return nibbles
+}
+
+// compactNibbles decodes the hex prefix encoded path of a short node, reporting
+// whether the node is a leaf
+func compactNibbles(encoded []byte) ([]byte, bool) {
+	flag := encoded[0] >> 4
+	nibbles := keyNibbles(encoded)[2:]
+	if flag&1 == 1 {
+		nibbles = keyNibbles(encoded)[1:]
+	}
+	return nibbles, flag&2 == 2
+}